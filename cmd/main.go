@@ -1,9 +1,16 @@
 package main
 
 import (
+	"os"
+
 	"VEEEKTOR_api/internal/app"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		app.Migrate(os.Args[2:])
+		return
+	}
+
 	app.Start()
 }
@@ -0,0 +1,239 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/models"
+)
+
+func TestAdminUsersSearch(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.token(t, api.addUser(t, "admin@uni.example", auth.RoleAdmin))
+	api.addUser(t, "teacher@uni.example", auth.RoleTeacher)
+	student := api.addUser(t, "Student@uni.example", auth.RoleStudent)
+	api.h.Users.SetActive(context.Background(), student.Id, false)
+
+	search := func(query string) models.UserPage {
+		t.Helper()
+		rec := api.do(http.MethodGet, "/admin/users?"+query, admin, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", query, rec.Code, rec.Body)
+		}
+		var page models.UserPage
+		json.Unmarshal(rec.Body.Bytes(), &page)
+		return page
+	}
+
+	for _, tc := range []struct {
+		query  string
+		emails []string
+	}{
+		{"", []string{"admin@uni.example", "teacher@uni.example",
+			"Student@uni.example"}},
+		{"q=STUDENT", []string{"Student@uni.example"}},
+		{"role_id=2", []string{"teacher@uni.example"}},
+		{"active=false", []string{"Student@uni.example"}},
+		{"limit=1&offset=1", []string{"teacher@uni.example"}},
+	} {
+		page := search(tc.query)
+		var emails []string
+		for _, user := range page.Users {
+			if user.Password != "" {
+				t.Errorf("%s: password of %s is returned", tc.query, user.Email)
+			}
+			emails = append(emails, user.Email)
+		}
+		if strings.Join(emails, " ") != strings.Join(tc.emails, " ") {
+			t.Errorf("%q: users %v, want %v", tc.query, emails, tc.emails)
+		}
+	}
+	if page := search("limit=1"); page.Total != 3 || page.Limit != 1 {
+		t.Errorf("page %+v, want total 3 and limit 1", page)
+	}
+
+	if rec := api.do(http.MethodGet, "/admin/users?limit=1000", admin,
+		nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("limit over maximum: status %d, want 400", rec.Code)
+	}
+}
+
+// Query matches letters of any case, Cyrillic included, like
+// ILIKE of database with UTF-8 ctype.
+func TestAdminUsersSearchFoldsCase(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.token(t, api.addUser(t, "admin@uni.example", auth.RoleAdmin))
+	user := models.User{Email: "petrov@uni.example", Password: "hashed",
+		GroupId: api.groupId, Name: "Пётр", Patronymic: "Петрович",
+		Surname: "Петров", RoleId: auth.RoleStudent, DepId: testDepId,
+		Active: true}
+	if err := api.h.Users.Insert(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"пЕТРОВ", "ПЁТР", "PETROV@"} {
+		rec := api.do(http.MethodGet, "/admin/users?"+url.Values{
+			"q": {query}}.Encode(), admin, nil)
+		var page models.UserPage
+		json.Unmarshal(rec.Body.Bytes(), &page)
+		if rec.Code != http.StatusOK || len(page.Users) != 1 ||
+			page.Users[0].Id != user.Id {
+			t.Errorf("%q: status %d, users %+v", query, rec.Code, page.Users)
+		}
+	}
+}
+
+// Body of unknown length is read in full, references are checked.
+func TestAdminUsersCreate(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.token(t, api.addUser(t, "admin@uni.example", auth.RoleAdmin))
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost,
+			apiPrefix+"/v1/admin/users", strings.NewReader(body))
+		req.ContentLength = -1
+		req.Header.Set("Authorization", "Bearer "+admin)
+		rec := httptest.NewRecorder()
+		api.mux.ServeHTTP(rec, req)
+		return rec
+	}
+	teacher := `{"email":"teacher@uni.example","password":"password-2",` +
+		`"group_id":` + strconv.Itoa(api.groupId) + `,"name":"Anna",` +
+		`"patronymic":"Olegovna","surname":"Sidorova","role_id":2,` +
+		`"dep_id":` + strconv.Itoa(testDepId) + `}`
+
+	rec := create(teacher)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var created struct{ Id int }
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	user, err := api.h.Users.GetById(context.Background(), created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.RoleId != auth.RoleTeacher || !user.Active ||
+		!user.EmailVerified || user.ComparePassword("password-2") != nil {
+		t.Errorf("created user %+v", user)
+	}
+
+	if rec = create(teacher); rec.Code != http.StatusConflict {
+		t.Errorf("same email: status %d, want 409", rec.Code)
+	}
+	if rec = create(strings.Replace(teacher, `"role_id":2`, `"role_id":9`,
+		1)); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unknown role: status %d, want 422", rec.Code)
+	}
+	if rec = create(`{"email":`); rec.Code != http.StatusBadRequest {
+		t.Errorf("broken body: status %d, want 400", rec.Code)
+	}
+}
+
+func TestAdminUsersUpdate(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.token(t, api.addUser(t, "admin@uni.example", auth.RoleAdmin))
+	student := api.addUser(t, "student@uni.example", auth.RoleStudent)
+
+	rec := api.do(http.MethodPatch, "/admin/users/"+strconv.Itoa(student.Id),
+		admin, models.UserAdminPatch{RoleId: auth.RoleTeacher})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var updated models.User
+	json.Unmarshal(rec.Body.Bytes(), &updated)
+	if updated.RoleId != auth.RoleTeacher || updated.GroupId != api.groupId ||
+		updated.Password != "" {
+		t.Errorf("updated user %+v", updated)
+	}
+
+	if rec = api.do(http.MethodPatch, "/admin/users/999", admin,
+		models.UserAdminPatch{RoleId: auth.RoleTeacher}); rec.Code !=
+		http.StatusNotFound {
+		t.Errorf("unknown user: status %d, want 404", rec.Code)
+	}
+}
+
+// Deactivated user can't sign in or refresh, force logout
+// revokes sessions of active user.
+func TestAdminUsersActivity(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.token(t, api.addUser(t, "admin@uni.example", auth.RoleAdmin))
+	student := api.addUser(t, "student@uni.example", auth.RoleStudent)
+	path := "/admin/users/" + strconv.Itoa(student.Id)
+
+	session := api.session(t, student.Email)
+	if rec := api.do(http.MethodPost, path+"/deactivate", admin,
+		nil); rec.Code != http.StatusOK {
+		t.Fatalf("deactivate status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := api.signIn(student.Email,
+		testPassword); rec.Code != http.StatusForbidden {
+		t.Errorf("sign in of deactivated user: status %d, want 403",
+			rec.Code)
+	}
+	if _, status := api.refresh(t,
+		session.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("refresh of deactivated user: status %d, want 401", status)
+	}
+
+	if rec := api.do(http.MethodPost, path+"/activate", admin,
+		nil); rec.Code != http.StatusOK {
+		t.Fatalf("activate status %d: %s", rec.Code, rec.Body)
+	}
+	session = api.session(t, student.Email)
+
+	if rec := api.do(http.MethodDelete, path+"/sessions", admin,
+		nil); rec.Code != http.StatusOK {
+		t.Fatalf("force logout status %d: %s", rec.Code, rec.Body)
+	}
+	if _, status := api.refresh(t,
+		session.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("refresh after force logout: status %d, want 401", status)
+	}
+
+	for _, route := range []string{"/999/deactivate", "/999/activate"} {
+		if rec := api.do(http.MethodPost, "/admin/users"+route, admin,
+			nil); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", route, rec.Code)
+		}
+	}
+	if rec := api.do(http.MethodDelete, "/admin/users/999/sessions", admin,
+		nil); rec.Code != http.StatusNotFound {
+		t.Errorf("sessions of unknown user: status %d, want 404", rec.Code)
+	}
+}
+
+func TestAdminUsersForbidden(t *testing.T) {
+	api := newTestAPI(t)
+	teacher := api.addUser(t, "teacher@uni.example", auth.RoleTeacher)
+	token := api.token(t, teacher)
+	path := "/admin/users/" + strconv.Itoa(teacher.Id)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/admin/users"},
+		{http.MethodPost, "/admin/users"},
+		{http.MethodPatch, path},
+		{http.MethodPost, path + "/deactivate"},
+		{http.MethodPost, path + "/activate"},
+		{http.MethodDelete, path + "/sessions"},
+	} {
+		if rec := api.do(route.method, route.path, token,
+			models.UserAdminPatch{RoleId: auth.RoleAdmin}); rec.Code !=
+			http.StatusForbidden {
+			t.Errorf("%s %s: status %d, want 403",
+				route.method, route.path, rec.Code)
+		}
+	}
+
+	stored, _ := api.h.Users.GetById(context.Background(), teacher.Id)
+	if stored.RoleId != auth.RoleTeacher {
+		t.Errorf("role changed by teacher to %d", stored.RoleId)
+	}
+}
@@ -1,66 +1,197 @@
 package app
 
 import (
+	"context"
+	"database/sql"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/config"
+	"VEEEKTOR_api/internal/repository/memory"
+	"VEEEKTOR_api/internal/repository/postgres"
 	"VEEEKTOR_api/internal/service"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	"VEEEKTOR_api/pkg/mail"
+	"VEEEKTOR_api/pkg/oidc"
 )
 
-var apiPrefix = "/api"
-
 func Start() {
 	log.Printf("VEEEKTOR_api is starting...")
 
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	mux := getMultiplexer()
-	server := &http.Server{Addr: ":8080", Handler: mux}
-	defer server.Close()
 
-	go func() {
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatal(err)
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("effective config:\n%s", cfg)
+
+	db, err := openDB(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	migrator, err := migrateUp(db)
+	if err != nil {
+		db.Close()
+		log.Fatal(err)
+	}
+
+	h := newPostgresHandler(db)
+	if cfg.Auth.LoginAttemptsStore == "memory" {
+		h.Login.Attempts = memory.NewLoginAttemptRepository(memory.NewStore())
+	}
+	h.Login.Account.LockoutAfter = cfg.Auth.LoginLockoutAfter
+	h.Login.Account.LockoutDuration = cfg.Auth.LoginLockoutDuration
+	h.Cookie = cfg.Cookie
+	h.Mailer = newMailer(cfg.Mail)
+	h.LinkBaseURL = cfg.Mail.LinkBaseURL
+	h.RequireVerifiedEmail = cfg.Auth.RequireVerifiedEmail
+	switch {
+	case !cfg.RateLimit.Enabled:
+		h.RateLimits = nil
+	case cfg.RateLimit.Store == "postgres":
+		h.RateLimits = postgres.NewRateLimitStore(db)
+	}
+	if cfg.OIDC.Enabled {
+		h.OIDC = &service.OIDC{
+			Provider: oidc.NewProvider(oidc.Config{
+				Issuer:       cfg.OIDC.Issuer,
+				ClientID:     cfg.OIDC.ClientID,
+				ClientSecret: cfg.OIDC.ClientSecret,
+				RedirectURL:  cfg.OIDC.RedirectURL,
+				Scopes:       cfg.OIDC.Scopes,
+			}),
+			GroupId: cfg.OIDC.DefaultGroupId,
+			DepId:   cfg.OIDC.DefaultDepId,
 		}
+	}
+
+	health := &Health{
+		DB:      db,
+		Schema:  migrator,
+		Timeout: cfg.Database.PingTimeout,
+	}
+	mux := NewMultiplexer(h, health)
+
+	// Outermost middleware goes last
+	handler := withCORS(mux, cfg.CORS.AllowedOrigins)
+	handler = withDeadline(handler, cfg.Server.RequestTimeout)
+	handler = withRecovery(handler)
+	handler = withLogging(handler)
+	handler = withRequestId(handler)
+
+	server := &http.Server{
+		Addr:         cfg.Server.Addr,
+		Handler:      handler,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
 	}()
+	health.SetReady(true)
+	log.Printf("listening on %s", cfg.Server.Addr)
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 
-	<-quit
+	select {
+	case sig := <-quit:
+		log.Printf("%s received, shutting down", sig)
+	case err := <-serveErr:
+		log.Printf("server stopped: %v", err)
+	}
+
+	shutdown(server, h, db, health, cfg.Server)
 }
 
-func getMultiplexer() *http.ServeMux {
-	mux := http.NewServeMux()
+// Only lifecycle owner: readiness is flipped first, so load balancer
+// stops routing, then in-flight requests and background letters
+// are drained and DB pool is closed after the last handler returned.
+func shutdown(server *http.Server, h *service.Handler, db *sql.DB,
+	health *Health, cfg config.Server) {
+	health.SetReady(false)
+	time.Sleep(cfg.ShutdownDelay)
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("drain timeout exceeded, closing connections: %v", err)
+		server.Close()
+	}
+	h.WaitLetters()
+
+	if err := db.Close(); err != nil {
+		log.Printf("failed to close database: %v", err)
+	}
+
+	log.Printf("VEEEKTOR_api stopped")
+}
 
-	// Auth
-	mux.HandleFunc(apiPrefix+"/auth/refresh", service.UpdateToken)
-	mux.HandleFunc(apiPrefix+"/auth/logout", service.Logout)
+// Config file path is taken from CONFIG_FILE, file is optional.
+// Auth package is configured on success.
+func loadConfig() (config.Config, error) {
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return config.Config{}, err
+	}
 
-	// Users
-	mux.HandleFunc(apiPrefix+"/users", service.GetUsersHandler)
-	mux.HandleFunc(apiPrefix+"/users/signin", service.UsersSignInHandler)
-	mux.HandleFunc(apiPrefix+"/users/signup", service.UsersSignUpHandler)
+	auth.Configure(cfg.Auth.JWTKey,
+		cfg.Auth.AccessTokenLifeTime, cfg.Auth.RefreshTokenLifeTime)
+	auth.ConfigureUserTokens(
+		cfg.Auth.VerifyTokenLifeTime, cfg.Auth.ResetTokenLifeTime)
 
-	// Educational envs
-	mux.HandleFunc(apiPrefix+"/educational_envs",
-		service.GetEducatinalEnvironmentsHandler)
+	return cfg, nil
+}
 
-	// Departments
-	mux.HandleFunc(apiPrefix+"/departments", service.GetDepartmentsHandler)
+func openDB(cfg config.Config) (*sql.DB, error) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(), cfg.Database.PingTimeout)
+	defer cancel()
+
+	return pgsql.Open(ctx, cfg.Database.URL, pgsql.PoolConfig{
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+	})
+}
 
-	// Groups
-	mux.HandleFunc(apiPrefix+"/groups", service.GetGroupsHandler)
-	mux.HandleFunc(apiPrefix+"/groups/link", service.LinkGroupWithCourse)
-	mux.HandleFunc(apiPrefix+"/groups/unlink", service.UnlinkGroupFromCourse)
+// Config must be validated.
+func newMailer(cfg config.Mail) mail.Mailer {
+	if cfg.Driver == "smtp" {
+		return mail.NewSMTPMailer(cfg.SMTP.Host, cfg.SMTP.Port,
+			cfg.SMTP.Username, cfg.SMTP.Password, cfg.From)
+	}
 
-	// Courses
-	mux.HandleFunc(apiPrefix+"/courses", service.GetCouresesHandler)
-	mux.HandleFunc(apiPrefix+"/courses/infos", service.GetNestedInfosHandler)
-	mux.HandleFunc(apiPrefix+"/courses/labs", service.GetNestedLabsHandler)
-	mux.HandleFunc(apiPrefix+"/courses/tests", service.GetNestedTestsHandler)
+	return mail.NewFileMailer(cfg.File, cfg.From)
+}
 
-	return mux
+// Wires service handlers with Postgres repositories.
+func newPostgresHandler(db *sql.DB) *service.Handler {
+	return service.NewHandler(
+		postgres.NewSessionRepository(db),
+		postgres.NewUserTokenRepository(db),
+		postgres.NewLoginAttemptRepository(db),
+		postgres.NewMFARepository(db),
+		postgres.NewUserRepository(db),
+		postgres.NewCourseRepository(db),
+		postgres.NewGroupRepository(db),
+		postgres.NewDepartmentRepository(db),
+		postgres.NewEducationalEnvRepository(db),
+		postgres.NewNestedInfoRepository(db),
+		postgres.NewNestedLabRepository(db),
+		postgres.NewNestedTestRepository(db),
+	)
 }
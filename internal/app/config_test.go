@@ -0,0 +1,24 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"VEEEKTOR_api/internal/config"
+)
+
+// File driver writes tokens to log, so it is never picked silently.
+func TestMailDriverRequired(t *testing.T) {
+	for driver, valid := range map[string]bool{
+		"": false, "file": true, "smtp": true, "sendmail": false} {
+		cfg := config.Default()
+		cfg.Mail.Driver = driver
+		cfg.Mail.SMTP.Host = "smtp.example.com"
+
+		err := cfg.Validate()
+		if failed := err != nil &&
+			strings.Contains(err.Error(), "mail driver"); failed == valid {
+			t.Errorf("driver %q: validation error %v", driver, err)
+		}
+	}
+}
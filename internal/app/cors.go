@@ -0,0 +1,53 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+// Allows browser requests from configured origins.
+// Preflight requests are answered without reaching handlers.
+// Credentials are allowed only for listed origins, origins matched
+// by "*" can't send refresh token cookie, otherwise any site could
+// refresh tokens of signed in user.
+func withCORS(next http.Handler, allowedOrigins []string) http.Handler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[origin] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || (!allowed[origin] && !allowed["*"]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		if allowed[origin] {
+			header.Set("Access-Control-Allow-Origin", origin)
+			// Refresh token is passed in cookie
+			header.Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			header.Set("Access-Control-Allow-Origin", "*")
+		}
+		header.Set("Access-Control-Expose-Headers", e.RequestIdHeader)
+
+		if r.Method == http.MethodOptions &&
+			r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", strings.Join(
+				[]string{http.MethodGet, http.MethodPost, http.MethodPut,
+					http.MethodPatch, http.MethodDelete}, ", "))
+			header.Set("Access-Control-Allow-Headers",
+				"Authorization, Content-Type")
+			header.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
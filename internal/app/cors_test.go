@@ -0,0 +1,36 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSCredentials(t *testing.T) {
+	handler := withCORS(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}),
+		[]string{"https://app.example.com", "*"})
+
+	for _, tc := range []struct {
+		origin      string
+		allowOrigin string
+		credentials string
+	}{
+		{"https://app.example.com", "https://app.example.com", "true"},
+		// Any site may read public answers, but not with user cookie
+		{"https://evil.example.com", "*", ""},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/courses", nil)
+		req.Header.Set("Origin", tc.origin)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		header := rec.Header()
+		if header.Get("Access-Control-Allow-Origin") != tc.allowOrigin ||
+			header.Get("Access-Control-Allow-Credentials") != tc.credentials {
+			t.Errorf("origin %s: allowed %q, credentials %q", tc.origin,
+				header.Get("Access-Control-Allow-Origin"),
+				header.Get("Access-Control-Allow-Credentials"))
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/internal/repository/postgres"
+	"VEEEKTOR_api/pkg/database/pgsql"
+)
+
+// Previous behaviour: every call prepares its statements again
+// and never closes them.
+type preparePerCallCourses struct {
+	models.CourseRepository
+	db *sql.DB
+}
+
+func (r preparePerCallCourses) GetAllByGroupId(ctx context.Context,
+	groupId int) ([]models.CourseMultipleExportDTO, error) {
+	return postgres.NewCourseRepository(r.db).GetAllByGroupId(ctx, groupId)
+}
+
+// Round trip of database on local network, paid by every
+// prepare and query of latencyDriver.
+const benchRoundTrip = 200 * time.Microsecond
+
+// Driver answering every query with ten courses after round trip,
+// prepares are counted.
+type latencyDriver struct{}
+
+var benchPrepares atomic.Int64
+
+func init() {
+	sql.Register("latency", latencyDriver{})
+}
+
+func (latencyDriver) Open(name string) (driver.Conn, error) {
+	return latencyConn{}, nil
+}
+
+type latencyConn struct{}
+
+func (latencyConn) Prepare(query string) (driver.Stmt, error) {
+	time.Sleep(benchRoundTrip)
+	benchPrepares.Add(1)
+	return latencyStmt{}, nil
+}
+
+func (latencyConn) Close() error { return nil }
+
+func (latencyConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type latencyStmt struct{}
+
+func (latencyStmt) Close() error { return nil }
+
+func (latencyStmt) NumInput() int { return -1 }
+
+func (latencyStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("exec is not supported")
+}
+
+func (latencyStmt) Query(args []driver.Value) (driver.Rows, error) {
+	time.Sleep(benchRoundTrip)
+	return &courseRows{left: 10}, nil
+}
+
+// Columns of courses list query.
+type courseRows struct {
+	left int
+}
+
+func (r *courseRows) Columns() []string {
+	return []string{"id", "name", "term", "dep", "name", "patronymic",
+		"surname", "dep", "modified_at"}
+}
+
+func (r *courseRows) Close() error { return nil }
+
+func (r *courseRows) Next(dest []driver.Value) error {
+	if r.left == 0 {
+		return io.EOF
+	}
+	r.left--
+	copy(dest, []driver.Value{int64(r.left + 1), "Algebra", int64(1),
+		"O7", "Ivan", "Ivanovich", "Ivanov", "O7", time.Now()})
+	return nil
+}
+
+// Lists courses of student group with and without statement cache.
+// Runs over latencyDriver, or over migrated database with dev seed
+// (make -C migrations seed) when TEST_DATABASE_URL is set:
+// TEST_DATABASE_URL=<url> go test -run ^$ -bench CoursesList ./internal/app
+func BenchmarkCoursesList(b *testing.B) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	db, err := sql.Open("latency", "")
+	if url != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if db, err = pgsql.Open(ctx, url,
+			pgsql.PoolConfig{MaxOpenConns: 4}); err == nil {
+			_, err = migrateUp(db)
+		}
+	}
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	auth.Configure("benchmark-key-benchmark-key-benchmark-key",
+		time.Hour, time.Hour)
+	// Seeded student of group 2
+	token, err := auth.GenerateAccessToken(3, auth.RoleStudent, 2, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// Rate limits would cut benchmark short
+	h := newPostgresHandler(db)
+	h.RateLimits = nil
+	cached := NewMultiplexer(h, &Health{})
+
+	uncachedHandler := newPostgresHandler(db)
+	uncachedHandler.RateLimits = nil
+	uncachedHandler.Courses = preparePerCallCourses{h.Courses, db}
+	uncached := NewMultiplexer(uncachedHandler, &Health{})
+
+	for _, bc := range []struct {
+		name string
+		mux  http.Handler
+	}{
+		{"prepare_per_call", uncached},
+		{"stmt_cache", cached},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			prepares := benchPrepares.Load()
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(
+					http.MethodGet, apiPrefix+"/v1/courses", nil)
+				req.Header.Set("Authorization", "Bearer "+token)
+				rec := httptest.NewRecorder()
+
+				bc.mux.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					b.Fatalf("status %d: %s", rec.Code, rec.Body)
+				}
+			}
+			// Prepares are counted by latencyDriver only
+			if url == "" {
+				b.ReportMetric(float64(benchPrepares.Load()-prepares)/
+					float64(b.N), "prepares/op")
+			}
+		})
+	}
+}
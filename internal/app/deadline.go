@@ -0,0 +1,19 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Bounds request time. Handlers pass request context down
+// to repositories, so running SQL is aborted on deadline
+// or when client disconnects.
+func withDeadline(next http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
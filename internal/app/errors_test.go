@@ -0,0 +1,54 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"VEEEKTOR_api/internal/auth"
+)
+
+// Envelope is served by v2 only, older clients get original body.
+func TestErrorBodyByVersion(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.token(t, api.addUser(t, "teacher@uni.example",
+		auth.RoleTeacher))
+
+	get := func(path string) map[string]json.RawMessage {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		withRecovery(api.mux).ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: status %d, want 404", path, rec.Code)
+		}
+
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return body
+	}
+
+	for _, path := range []string{apiPrefix + "/v1/courses/999",
+		apiPrefix + "/courses?id=999", apiPrefix + "/v1/unknown"} {
+		var message string
+		json.Unmarshal(get(path)["Error"], &message)
+		if message == "" {
+			t.Errorf("%s: legacy error body is not returned", path)
+		}
+	}
+
+	for _, path := range []string{apiPrefix + "/v2/courses/999",
+		apiPrefix + "/v2/unknown"} {
+		var apiErr struct {
+			Code   string `json:"code"`
+			Status int    `json:"status"`
+		}
+		json.Unmarshal(get(path)["error"], &apiErr)
+		if apiErr.Code == "" || apiErr.Status != http.StatusNotFound {
+			t.Errorf("%s: envelope %+v", path, apiErr)
+		}
+	}
+}
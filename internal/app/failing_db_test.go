@@ -0,0 +1,103 @@
+package app
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+)
+
+// Driver of database which is down, every connection fails.
+type failingDriver struct{}
+
+func (failingDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("connection refused")
+}
+
+func init() {
+	sql.Register("failing", failingDriver{})
+}
+
+// Every route is answered with error instead of panic
+// while database is down, probes keep answering.
+func TestFailingDatabase(t *testing.T) {
+	db, err := sql.Open("failing", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	auth.Configure("failing-db-key-failing-db-key-failing-db",
+		time.Minute, time.Hour)
+	token, err := auth.GenerateAccessToken(1, auth.RoleAdmin, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newPostgresHandler(db)
+	h.RateLimits = nil
+	health := &Health{DB: db, Timeout: time.Second}
+	health.SetReady(true)
+	mux := NewMultiplexer(h, health)
+
+	serve := func(method, path, body string) (rec *httptest.ResponseRecorder) {
+		defer func() {
+			if v := recover(); v != nil {
+				t.Errorf("%s %s panics: %v", method, path, v)
+			}
+		}()
+
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.AddCookie(&http.Cookie{Name: "refresh_token", Value: "token"})
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	check := func(prefix string, routes []route) {
+		for _, r := range routes {
+			path := prefix + strings.ReplaceAll(r.path, "{id}", "1")
+			for _, body := range []string{"", "{}",
+				`{"id":1,"email":"user@uni.example","password":"password-1"}`} {
+				serve(r.method, path, body)
+			}
+		}
+	}
+	check(apiPrefix+"/v1", apiRoutes(h))
+	check(apiPrefix, queryAliases(h))
+
+	// Failures are reported, not hidden behind empty answers
+	if rec := serve(http.MethodPost, apiPrefix+"/v1/users/signin",
+		`{"email":"user@uni.example","password":"password-1"}`); rec == nil ||
+		rec.Code != http.StatusInternalServerError {
+		t.Errorf("sign in without database: %v", rec)
+	}
+	if rec := serve(http.MethodPost, apiPrefix+"/v1/admin/users/1/deactivate",
+		""); rec == nil || rec.Code != http.StatusInternalServerError {
+		t.Errorf("deactivation without database: %v", rec)
+	}
+
+	if rec := serve(http.MethodGet, "/healthz", ""); rec == nil ||
+		rec.Code != http.StatusOK {
+		t.Errorf("healthz: %v", rec)
+	}
+
+	rec := serve(http.MethodGet, "/readyz", "")
+	if rec == nil {
+		return
+	}
+	var ready readinessResponse
+	json.Unmarshal(rec.Body.Bytes(), &ready)
+	if rec.Code != http.StatusServiceUnavailable ||
+		ready.Checks["database"] != "unreachable" {
+		t.Errorf("readyz status %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,362 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/internal/repository/memory"
+	"VEEEKTOR_api/internal/service"
+	"VEEEKTOR_api/pkg/mail"
+)
+
+const (
+	testDepId    = 5
+	testPassword = "password-1"
+)
+
+// Letters are kept instead of being sent.
+type mailbox struct {
+	mu      sync.Mutex
+	letters []mail.Message
+}
+
+func (m *mailbox) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.letters = append(m.letters, msg)
+	return nil
+}
+
+// Token of last letter to address. Without link base URL
+// token is the last line of letter.
+func (m *mailbox) token(t *testing.T, to string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.letters) - 1; i >= 0; i-- {
+		if m.letters[i].To == to {
+			lines := strings.Split(strings.TrimSpace(m.letters[i].Body), "\n")
+			return lines[len(lines)-1]
+		}
+	}
+	t.Fatalf("no letter to %s", to)
+	return ""
+}
+
+// Whole API over in-memory repositories with seeded roles,
+// department and group. Requests are not rate limited.
+type testAPI struct {
+	h       *service.Handler
+	store   *memory.Store
+	mux     http.Handler
+	mail    *mailbox
+	groupId int
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	auth.Configure("handler-test-key-handler-test-key-handler",
+		time.Minute, time.Hour)
+
+	s := memory.NewStore()
+	s.AddRole(auth.RoleStudent, "student")
+	s.AddRole(auth.RoleTeacher, "teacher")
+	s.AddRole(auth.RoleAdmin, "admin")
+	s.AddEducationalEnv(models.EducationalEnv{Id: 1})
+	s.AddDepartment(models.Department{Id: testDepId, EnvId: 1})
+	h := service.NewHandler(memory.NewSessionRepository(s),
+		memory.NewUserTokenRepository(s),
+		memory.NewLoginAttemptRepository(s),
+		memory.NewMFARepository(s),
+		memory.NewUserRepository(s),
+		memory.NewCourseRepository(s),
+		memory.NewGroupRepository(s),
+		memory.NewDepartmentRepository(s),
+		memory.NewEducationalEnvRepository(s),
+		memory.NewNestedInfoRepository(s),
+		memory.NewNestedLabRepository(s),
+		memory.NewNestedTestRepository(s))
+	h.RateLimits = nil
+	box := &mailbox{}
+	h.Mailer = box
+
+	group := models.Group{Name: "Default", DepId: testDepId}
+	if err := h.Groups.Insert(context.Background(), &group); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testAPI{h: h, store: s, mux: NewMultiplexer(h, &Health{}),
+		mail: box, groupId: group.Id}
+}
+
+// Sends request to v1 path with JSON body, token is put
+// into Authorization header unless empty. Body length is not
+// sent like in chunked requests, so handlers must read it in full.
+// Letters sent in background are waited for.
+func (api *testAPI) do(method, path, token string,
+	body any) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if s, ok := body.(string); ok {
+		reader = bytes.NewReader([]byte(s))
+	} else {
+		jsonBytes, _ := json.Marshal(body)
+		reader = bytes.NewReader(jsonBytes)
+	}
+
+	req := httptest.NewRequest(method, apiPrefix+"/v1"+path, reader)
+	req.ContentLength = -1
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	api.mux.ServeHTTP(rec, req)
+	api.h.WaitLetters()
+
+	return rec
+}
+
+// Active user with verified email and testPassword.
+func (api *testAPI) addUser(t *testing.T, email string, roleId int) models.User {
+	t.Helper()
+	hash, err := models.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.User{Email: email, Password: hash,
+		GroupId: api.groupId, Name: "Ivan", Patronymic: "Ivanovich",
+		Surname: "Ivanov", RoleId: roleId, DepId: testDepId,
+		Active: true, EmailVerified: true}
+	if err = api.h.Users.Insert(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+// Access token without session, enough for routes
+// which don't look sessions up.
+func (api *testAPI) token(t *testing.T, user models.User) string {
+	t.Helper()
+	token, err := auth.GenerateAccessToken(
+		user.Id, user.RoleId, user.GroupId, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func (api *testAPI) signIn(email, password string) *httptest.ResponseRecorder {
+	return api.do(http.MethodPost, "/users/signin", "",
+		models.SignInInput{Email: email, Password: password})
+}
+
+func accessToken(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var tokens auth.TokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	return tokens.AccessToken
+}
+
+func TestSignUpAndSignIn(t *testing.T) {
+	api := newTestAPI(t)
+	signUp := map[string]any{"email": "student@uni.example",
+		"password": testPassword, "name": "Petr", "patronymic": "Petrovich",
+		"surname": "Petrov", "group_id": api.groupId, "dep_id": testDepId}
+
+	rec := api.do(http.MethodPost, "/users/signup", "", signUp)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("sign up status %d: %s", rec.Code, rec.Body)
+	}
+	if rec = api.do(http.MethodPost, "/users/signup", "",
+		signUp); rec.Code != http.StatusBadRequest {
+		t.Errorf("repeated sign up: status %d, want 400", rec.Code)
+	}
+
+	invalid := map[string]any{"email": "not email", "password": "short",
+		"group_id": 999, "dep_id": testDepId}
+	if rec = api.do(http.MethodPost, "/users/signup", "",
+		invalid); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid sign up: status %d, want 422", rec.Code)
+	}
+	if rec = api.do(http.MethodPost, "/users/signup", "",
+		"{"); rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed body: status %d, want 400", rec.Code)
+	}
+
+	// Email must be verified first
+	if rec = api.signIn("student@uni.example",
+		testPassword); rec.Code != http.StatusForbidden {
+		t.Errorf("unverified sign in: status %d, want 403", rec.Code)
+	}
+	rec = api.do(http.MethodPost, "/users/verify", "", models.UserTokenInput{
+		Token: api.mail.token(t, "student@uni.example")})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verify status %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec = api.signIn("student@uni.example",
+		"wrong-password"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: status %d, want 401", rec.Code)
+	}
+	if rec = api.signIn("nobody@uni.example",
+		testPassword); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown email: status %d, want 401", rec.Code)
+	}
+
+	rec = api.signIn("student@uni.example", testPassword)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("sign in status %d: %s", rec.Code, rec.Body)
+	}
+	token := accessToken(t, rec)
+
+	rec = api.do(http.MethodGet, "/users", token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get user status %d: %s", rec.Code, rec.Body)
+	}
+	var user models.User
+	json.Unmarshal(rec.Body.Bytes(), &user)
+	if user.Email != "student@uni.example" || user.Password != "" ||
+		user.RoleId != auth.RoleStudent || !user.EmailVerified {
+		t.Errorf("signed in user %+v", user)
+	}
+}
+
+func TestCourseCRUD(t *testing.T) {
+	api := newTestAPI(t)
+	teacher := api.addUser(t, "teacher@uni.example", auth.RoleTeacher)
+	other := api.addUser(t, "other@uni.example", auth.RoleTeacher)
+	student := api.addUser(t, "student@uni.example", auth.RoleStudent)
+	token := api.token(t, teacher)
+
+	course := models.Course{Name: "Algebra", Term: 1,
+		TeacherId: teacher.Id, DepId: testDepId}
+	if rec := api.do(http.MethodPost, "/courses", api.token(t, student),
+		course); rec.Code != http.StatusForbidden {
+		t.Errorf("student creates course: status %d, want 403", rec.Code)
+	}
+	rec := api.do(http.MethodPost, "/courses", token, course)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status %d: %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		Id int `json:"id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	path := "/courses/" + strconv.Itoa(created.Id)
+
+	course.Name, course.Markdown = "Linear algebra", "# Plan"
+	if rec = api.do(http.MethodPut, path, token,
+		course); rec.Code != http.StatusOK {
+		t.Fatalf("update status %d: %s", rec.Code, rec.Body)
+	}
+	if rec = api.do(http.MethodPut, path, api.token(t, other),
+		course); rec.Code != http.StatusForbidden {
+		t.Errorf("update by other teacher: status %d, want 403", rec.Code)
+	}
+
+	rec = api.do(http.MethodGet, path, token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get status %d: %s", rec.Code, rec.Body)
+	}
+	var got models.Course
+	json.Unmarshal(rec.Body.Bytes(), &got)
+	if got.Id != created.Id || got.Name != "Linear algebra" ||
+		got.Markdown != "# Plan" || got.TeacherId != teacher.Id {
+		t.Errorf("stored course %+v", got)
+	}
+
+	// Course is hidden from students of groups it is not linked with
+	if rec = api.do(http.MethodGet, path, api.token(t, student),
+		nil); rec.Code != http.StatusForbidden {
+		t.Errorf("get by student: status %d, want 403", rec.Code)
+	}
+	if rec = api.do(http.MethodGet, "/courses/999", token,
+		nil); rec.Code != http.StatusNotFound {
+		t.Errorf("missing course: status %d, want 404", rec.Code)
+	}
+
+	rec = api.do(http.MethodGet, "/courses", token, nil)
+	var list []models.CourseMultipleExportDTO
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if rec.Code != http.StatusOK || len(list) != 1 ||
+		list[0].Id != created.Id {
+		t.Errorf("teacher courses: status %d, %s", rec.Code, rec.Body)
+	}
+}
+
+// Lab of another teacher can't be changed, neither in place
+// nor by moving it into own course.
+func TestLabUpdateAccess(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	owner := api.addUser(t, "owner@uni.example", auth.RoleTeacher)
+	intruder := api.addUser(t, "intruder@uni.example", auth.RoleTeacher)
+	api.store.AddLocation(100, "Room 1")
+
+	courses := map[int]int{}
+	for _, teacher := range []models.User{owner, intruder} {
+		course := models.Course{Name: "Course", Term: 1,
+			TeacherId: teacher.Id, DepId: testDepId}
+		id, err := api.h.Courses.Insert(ctx, &course)
+		if err != nil {
+			t.Fatal(err)
+		}
+		courses[teacher.Id] = id
+	}
+
+	opens := time.Now().UTC().Truncate(time.Second)
+	lab := models.NestedLab{CourseId: courses[owner.Id], Opens: opens,
+		Closes: opens.Add(time.Hour), Topic: "Matrices",
+		LocationId: 100, Attempts: 1}
+	if err := api.h.Labs.Insert(ctx, &lab); err != nil {
+		t.Fatal(err)
+	}
+	path := "/labs/" + strconv.Itoa(lab.Id)
+
+	changed := lab
+	changed.Topic = "Taken over"
+	for _, courseId := range []int{courses[owner.Id], courses[intruder.Id]} {
+		changed.CourseId = courseId
+		if rec := api.do(http.MethodPut, path, api.token(t, intruder),
+			changed); rec.Code != http.StatusForbidden {
+			t.Errorf("course %d in body: status %d, want 403",
+				courseId, rec.Code)
+		}
+	}
+	if stored, _ := api.h.Labs.GetById(ctx, lab.Id); stored != lab {
+		t.Errorf("lab is changed by another teacher: %+v", stored)
+	}
+
+	// Owner can't move lab into course of another teacher either
+	if rec := api.do(http.MethodPut, path, api.token(t, owner),
+		changed); rec.Code != http.StatusForbidden {
+		t.Errorf("move to foreign course: status %d, want 403", rec.Code)
+	}
+
+	changed.CourseId = courses[owner.Id]
+	if rec := api.do(http.MethodPut, path, api.token(t, owner),
+		changed); rec.Code != http.StatusOK {
+		t.Errorf("owner update: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := api.do(http.MethodPut, "/labs/999", api.token(t, owner),
+		changed); rec.Code != http.StatusNotFound {
+		t.Errorf("missing lab: status %d, want 404", rec.Code)
+	}
+}
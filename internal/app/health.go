@@ -0,0 +1,120 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"VEEEKTOR_api/internal/version"
+)
+
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+type SchemaVersioner interface {
+	// Latest applied migration version
+	Version(ctx context.Context) (int, error)
+	// Version binary expects
+	Latest() int
+}
+
+// Health answers probes of compose, Kubernetes and uptime monitors.
+// Probes are not authorized.
+// Nil DB or Schema skips corresponding check (e.g. in-memory storage).
+type Health struct {
+	ready   atomic.Bool
+	DB      Pinger
+	Schema  SchemaVersioner
+	Timeout time.Duration
+}
+
+// Instance is ready while it accepts new requests.
+func (hl *Health) SetReady(ready bool) {
+	hl.ready.Store(ready)
+}
+
+type readinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Liveness probe, process is alive while it answers.
+// Response codes:
+// 200.
+func (hl *Health) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// Readiness probe for load balancer.
+// Response:
+// status : ready or not ready;
+// checks : result of every check (lifecycle, database, migrations).
+// Response codes:
+// 200 - ready, 503 - starting, shutting down or dependency failed.
+func (hl *Health) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	resp := readinessResponse{
+		Status: "ready",
+		Checks: make(map[string]string),
+	}
+	fail := func(check, msg string) {
+		resp.Status = "not ready"
+		resp.Checks[check] = msg
+	}
+
+	if hl.ready.Load() {
+		resp.Checks["lifecycle"] = "ok"
+	} else {
+		fail("lifecycle", "starting or shutting down")
+	}
+
+	timeout := hl.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	if hl.DB != nil {
+		if err := hl.DB.PingContext(ctx); err != nil {
+			fail("database", "unreachable")
+		} else {
+			resp.Checks["database"] = "ok"
+		}
+	}
+
+	if hl.Schema != nil {
+		if v, err := hl.Schema.Version(ctx); err != nil {
+			fail("migrations", "unable to read schema version")
+		} else if v != hl.Schema.Latest() {
+			fail("migrations", fmt.Sprintf(
+				"schema version %d, expected %d", v, hl.Schema.Latest()))
+		} else {
+			resp.Checks["migrations"] = "ok"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	jsonBytes, _ := json.Marshal(resp)
+	w.Write(jsonBytes)
+}
+
+// Build information.
+// Response:
+// commit : git commit binary was built from;
+// build_time : build time;
+// go_version : Go version binary was built with.
+// Response codes:
+// 200.
+func (hl *Health) VersionHandler(w http.ResponseWriter, r *http.Request) {
+	jsonBytes, _ := json.Marshal(version.Get())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonBytes)
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"net/http"
+
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+// Unversioned routes, including query string aliases of REST
+// routes, are scheduled for removal. Their responses carry
+// Deprecation header and Sunset header with removal date.
+func deprecated(next http.Handler) http.Handler {
+	sunset := legacySunset.Format(http.TimeFormat)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Sunset", sunset)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Url value of deprecated route and REST handler serving it.
+type queryAlias struct {
+	key     string
+	handler http.HandlerFunc
+}
+
+// Serves query string route by REST handler: first url value
+// present in query is moved into path wildcard {id}.
+// Request without any of url values goes to fallback,
+// without fallback 400 is answered.
+func fromQuery(fallback http.HandlerFunc,
+	aliases ...queryAlias) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		for _, alias := range aliases {
+			if query.Has(alias.key) {
+				r.SetPathValue("id", query.Get(alias.key))
+				alias.handler(w, r)
+				return
+			}
+		}
+
+		if fallback == nil {
+			e.ResponseWithError(
+				w, r, http.StatusBadRequest, e.ErrUrlValueMissing)
+			return
+		}
+		fallback(w, r)
+	}
+}
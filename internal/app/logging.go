@@ -0,0 +1,47 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+// Remembers status code written by handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+// Logs method, path, status, duration and request id of every request.
+// Must be inside withRequestId.
+func withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(sr, r)
+
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+		log.Printf("%s %s %d %s id=%s", r.Method, r.URL.Path, sr.status,
+			time.Since(start).Round(time.Microsecond),
+			w.Header().Get(e.RequestIdHeader))
+	})
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/pkg/totp"
+)
+
+// Codes of steps around current one are accepted once,
+// code of used or older step is rejected.
+func TestTOTPWindowAndReplay(t *testing.T) {
+	// Steps are fixed below, test must not cross step boundary
+	stepStart := time.Unix(
+		totp.Step(time.Now())*int64(totp.Period/time.Second), 0)
+	if left := totp.Period - time.Since(stepStart); left < 5*time.Second {
+		time.Sleep(left)
+	}
+
+	api := newTestAPI(t)
+	user := api.addUser(t, "student@uni.example", auth.RoleStudent)
+	token := api.token(t, user)
+
+	rec := api.do(http.MethodPost, "/users/mfa", token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("enroll status %d: %s", rec.Code, rec.Body)
+	}
+	var enrollment auth.MFAEnrollment
+	json.Unmarshal(rec.Body.Bytes(), &enrollment)
+
+	now := totp.Step(time.Now())
+	code := func(step int64) string {
+		c, err := totp.Code(enrollment.Secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// Previous step is within window
+	if rec = api.do(http.MethodPost, "/users/mfa/confirm", token,
+		models.MFACodeInput{Code: code(now - 1)}); rec.Code != http.StatusOK {
+		t.Fatalf("confirm status %d: %s", rec.Code, rec.Body)
+	}
+
+	signIn := func(code string) int {
+		t.Helper()
+		rec := api.signIn(user.Email, testPassword)
+		var challenge auth.MFAChallenge
+		json.Unmarshal(rec.Body.Bytes(), &challenge)
+		if !challenge.MFARequired {
+			t.Fatalf("sign in without second step: %s", rec.Body)
+		}
+
+		return api.do(http.MethodPost, "/users/signin/mfa", "",
+			models.MFASignInInput{Token: challenge.Token, Code: code}).Code
+	}
+
+	for _, tc := range []struct {
+		name   string
+		step   int64
+		status int
+	}{
+		{"outside window", now - 3, http.StatusUnauthorized},
+		{"step used by confirmation", now - 1, http.StatusUnauthorized},
+		{"current step", now, http.StatusOK},
+		{"replayed step", now, http.StatusUnauthorized},
+		{"next step", now + 1, http.StatusOK},
+		{"older step after newer one", now, http.StatusUnauthorized},
+	} {
+		if status := signIn(code(tc.step)); status != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, status, tc.status)
+		}
+	}
+}
+
+// Wrong codes of confirmation and disabling are counted as failed
+// sign in attempts, guessing is stopped with 429.
+func TestMFACodeGuessesLimited(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.addUser(t, "student@uni.example", auth.RoleStudent)
+	token := api.token(t, user)
+	free := auth.AccountLoginPolicy.FreeAttempts
+
+	rec := api.do(http.MethodPost, "/users/mfa", token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("enroll status %d: %s", rec.Code, rec.Body)
+	}
+	var enrollment auth.MFAEnrollment
+	json.Unmarshal(rec.Body.Bytes(), &enrollment)
+	code, err := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Code of other secret can't match
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	guess := func(method, path string) {
+		t.Helper()
+		for i := 0; i <= free; i++ {
+			if rec := api.do(method, path, token, models.MFACodeInput{
+				Code: wrong}); rec.Code != http.StatusForbidden {
+				t.Fatalf("%s %s guess %d: status %d, want 403",
+					method, path, i+1, rec.Code)
+			}
+		}
+		if rec := api.do(method, path, token, models.MFACodeInput{
+			Code: code}); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("%s %s after %d guesses: status %d, want 429",
+				method, path, free+1, rec.Code)
+		}
+	}
+
+	guess(http.MethodPost, "/users/mfa/confirm")
+
+	// Attempts of account are forgotten, confirmation succeeds
+	api.h.Login.Succeeded(context.Background(), user.Email)
+	if rec = api.do(http.MethodPost, "/users/mfa/confirm", token,
+		models.MFACodeInput{Code: code}); rec.Code != http.StatusOK {
+		t.Fatalf("confirm status %d: %s", rec.Code, rec.Body)
+	}
+
+	guess(http.MethodDelete, "/users/mfa")
+}
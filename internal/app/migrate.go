@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"VEEEKTOR_api/migrations"
+	"VEEEKTOR_api/pkg/database/migrate"
+)
+
+const migrateUsage = "usage: api migrate up|down|status"
+
+// Migrate handles migrate subcommand:
+// up - apply all pending migrations;
+// down - rollback latest applied migration;
+// status - list migrations and their state.
+func Migrate(args []string) {
+	if len(args) != 1 {
+		log.Fatal(migrateUsage)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		versions, err := m.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(versions) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		for _, v := range versions {
+			fmt.Printf("applied %d\n", v)
+		}
+	case "down":
+		version, err := m.Down(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("rolled back %d\n", version)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
+
+// Applies pending migrations on API start.
+// Replicas starting at once wait for each other on advisory lock.
+func migrateUp(db *sql.DB) (*migrate.Migrator, error) {
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := m.Up(context.Background())
+	for _, v := range versions {
+		log.Printf("migration %d applied", v)
+	}
+
+	return m, err
+}
@@ -0,0 +1,396 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/internal/service"
+	"VEEEKTOR_api/pkg/oidc"
+)
+
+const (
+	stubClientID    = "veeektor"
+	stubRedirectURL = "http://localhost/api/v1/auth/oidc/callback"
+)
+
+// Account signing in at stub provider.
+type stubIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// Nonce put into ID token instead of requested one
+	Nonce string
+	// Name claims are left out
+	NoNames bool
+}
+
+type stubGrant struct {
+	identity  stubIdentity
+	nonce     string
+	challenge string
+}
+
+// Local identity provider with discovery, token and keys endpoints.
+// Authorization page is skipped, tests grant codes directly.
+type stubIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]stubGrant
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &stubIdP{key: key, grants: map[string]stubGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration",
+		func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 idp.URL,
+				"authorization_endpoint": idp.URL + "/authorize",
+				"token_endpoint":         idp.URL + "/token",
+				"jwks_uri":               idp.URL + "/jwks",
+			})
+		})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "stub", "kty": "RSA", "alg": "RS256", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(
+				big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", idp.token)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+// Signs identity in on authorization URL, returns code
+// passed to callback.
+func (idp *stubIdP) authorize(t *testing.T, location string,
+	identity stubIdentity) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("client_id") != stubClientID ||
+		q.Get("redirect_uri") != stubRedirectURL ||
+		q.Get("code_challenge_method") != "S256" ||
+		q.Get("code_challenge") == "" || q.Get("nonce") == "" {
+		t.Fatalf("authorization request is not valid: %s", location)
+	}
+
+	code, _ := oidc.RandomString()
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.grants[code] = stubGrant{identity: identity,
+		nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+
+	return code
+}
+
+// Codes are single use and bound to PKCE challenge.
+func (idp *stubIdP) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	idp.mu.Lock()
+	grant, ok := idp.grants[r.PostForm.Get("code")]
+	delete(idp.grants, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+
+	if !ok || oidc.Challenge(r.PostForm.Get("code_verifier")) !=
+		grant.challenge || r.PostForm.Get("redirect_uri") != stubRedirectURL {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	nonce := grant.nonce
+	if grant.identity.Nonce != "" {
+		nonce = grant.identity.Nonce
+	}
+	claims := jwt.MapClaims{
+		"iss":            idp.URL,
+		"aud":            stubClientID,
+		"sub":            grant.identity.Subject,
+		"email":          grant.identity.Email,
+		"email_verified": grant.identity.EmailVerified,
+		"given_name":     "Ivan",
+		"family_name":    "Petrov",
+		"nonce":          nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	}
+	if grant.identity.NoNames {
+		delete(claims, "given_name")
+		delete(claims, "family_name")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "stub"
+	idToken, _ := token.SignedString(idp.key)
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "stub", "token_type": "Bearer", "id_token": idToken})
+}
+
+func newOIDCHandler(t *testing.T, idp *stubIdP) *service.Handler {
+	api := newTestAPI(t)
+	api.h.OIDC = &service.OIDC{
+		Provider: oidc.NewProvider(oidc.Config{
+			Issuer:      idp.URL,
+			ClientID:    stubClientID,
+			RedirectURL: stubRedirectURL,
+		}),
+		GroupId: api.groupId,
+		DepId:   testDepId,
+	}
+
+	return api.h
+}
+
+// Starts sign in, signs identity in at provider and
+// returns answer of callback.
+func signInOIDC(t *testing.T, mux http.Handler, idp *stubIdP,
+	identity stubIdentity) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		apiPrefix+"/v1/auth/oidc/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login status %d: %s", rec.Code, rec.Body)
+	}
+	location := rec.Header().Get("Location")
+	state, _ := url.Parse(location)
+
+	code := idp.authorize(t, location, identity)
+	req := httptest.NewRequest(http.MethodGet,
+		apiPrefix+"/v1/auth/oidc/callback?"+url.Values{
+			"code": {code}, "state": {state.Query().Get("state")},
+		}.Encode(), nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	idp := newStubIdP(t)
+	h := newOIDCHandler(t, idp)
+	mux := NewMultiplexer(h, &Health{})
+	identity := stubIdentity{Subject: "s-1",
+		Email: "student@uni.example", EmailVerified: true}
+
+	// First sign in creates student of default group and department
+	rec := signInOIDC(t, mux, idp, identity)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var tokens auth.TokenResponse
+	json.Unmarshal(rec.Body.Bytes(), &tokens)
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("token pair is not returned: %s", rec.Body)
+	}
+
+	user, err := h.Users.GetByEmail(context.Background(),
+		"student@uni.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.RoleId != auth.RoleStudent || user.GroupId != h.OIDC.GroupId ||
+		user.DepId != testDepId || !user.Active || !user.EmailVerified ||
+		user.Name != "Ivan" || user.Surname != "Petrov" {
+		t.Errorf("created user %+v", user)
+	}
+	if err = user.ValidateProfile(); err != nil {
+		t.Errorf("profile of created user: %v", err)
+	}
+
+	// Next sign in finds linked user
+	rec = signInOIDC(t, mux, idp, identity)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("second status %d: %s", rec.Code, rec.Body)
+	}
+	p, err := auth.ParsePrincipal(accessToken(t, rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.UserId != user.Id {
+		t.Errorf("second sign in as user %d, want %d",
+			p.UserId, user.Id)
+	}
+}
+
+func TestOIDCLinksExistingUser(t *testing.T) {
+	idp := newStubIdP(t)
+	h := newOIDCHandler(t, idp)
+	mux := NewMultiplexer(h, &Health{})
+
+	existing := models.User{Email: "teacher@uni.example",
+		Password: "hashed", GroupId: h.OIDC.GroupId, Name: "Anna",
+		Patronymic: "Olegovna", Surname: "Sidorova",
+		RoleId: auth.RoleStudent, DepId: testDepId, Active: true}
+	if err := h.Users.Insert(context.Background(), &existing); err != nil {
+		t.Fatal(err)
+	}
+
+	// Email not verified by provider does not take account over
+	rec := signInOIDC(t, mux, idp, stubIdentity{Subject: "s-2",
+		Email: existing.Email})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("unverified email: status %d, want 409", rec.Code)
+	}
+
+	rec = signInOIDC(t, mux, idp, stubIdentity{Subject: "s-2",
+		Email: existing.Email, EmailVerified: true})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	p, err := auth.ParsePrincipal(accessToken(t, rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.UserId != existing.Id {
+		t.Errorf("signed in as user %d, want %d", p.UserId, existing.Id)
+	}
+	linked, err := h.Users.GetByIdentity(context.Background(),
+		idp.URL, "s-2")
+	if err != nil || linked.Id != existing.Id || !linked.EmailVerified {
+		t.Errorf("linked user %+v, error %v", linked, err)
+	}
+}
+
+func TestOIDCRejects(t *testing.T) {
+	idp := newStubIdP(t)
+	h := newOIDCHandler(t, idp)
+	mux := NewMultiplexer(h, &Health{})
+
+	callback := func(query string, cookies []*http.Cookie) int {
+		req := httptest.NewRequest(http.MethodGet,
+			apiPrefix+"/v1/auth/oidc/callback?"+query, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	login := func() (url.Values, []*http.Cookie) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+			apiPrefix+"/v1/auth/oidc/login", nil))
+		u, _ := url.Parse(rec.Header().Get("Location"))
+		return u.Query(), rec.Result().Cookies()
+	}
+
+	q, cookies := login()
+	code := idp.authorize(t, "?"+q.Encode(), stubIdentity{Subject: "s-3",
+		Email: "s3@uni.example", EmailVerified: true})
+	if status := callback(url.Values{"code": {code},
+		"state": {"forged"}}.Encode(), cookies); status != http.StatusBadRequest {
+		t.Errorf("forged state: status %d, want 400", status)
+	}
+	if status := callback(url.Values{"code": {code},
+		"state": {q.Get("state")}}.Encode(), nil); status != http.StatusBadRequest {
+		t.Errorf("missing cookie: status %d, want 400", status)
+	}
+
+	q, cookies = login()
+	if status := callback(url.Values{"error": {"access_denied"},
+		"state": {q.Get("state")}}.Encode(), cookies); status != http.StatusUnauthorized {
+		t.Errorf("provider error: status %d, want 401", status)
+	}
+
+	// ID token of another sign in is rejected by nonce
+	rec := signInOIDC(t, mux, idp, stubIdentity{Subject: "s-3",
+		Email: "s3@uni.example", EmailVerified: true, Nonce: "replayed"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong nonce: status %d, want 401", rec.Code)
+	}
+
+	h.OIDC = nil
+	rec = httptest.NewRecorder()
+	NewMultiplexer(h, &Health{}).ServeHTTP(rec, httptest.NewRequest(
+		http.MethodGet, apiPrefix+"/v1/auth/oidc/login", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("disabled: status %d, want 404", rec.Code)
+	}
+}
+
+// Name parts provider didn't share are filled in, so profile
+// of created user can be updated without them.
+func TestOIDCUserWithoutNames(t *testing.T) {
+	idp := newStubIdP(t)
+	h := newOIDCHandler(t, idp)
+	mux := NewMultiplexer(h, &Health{})
+
+	rec := signInOIDC(t, mux, idp, stubIdentity{Subject: "s-4",
+		Email: "x@uni.example", EmailVerified: true, NoNames: true})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	user, err := h.Users.GetByEmail(context.Background(), "x@uni.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = user.ValidateProfile(); err != nil {
+		t.Errorf("profile of created user %+v: %v", user, err)
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, apiPrefix+"/v1/users",
+		strings.NewReader(`{"surname":"Petrov"}`))
+	req.Header.Set("Authorization", "Bearer "+accessToken(t, rec))
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("profile update status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+// Email of created user is checked like on sign up.
+func TestOIDCUserEmailValidated(t *testing.T) {
+	idp := newStubIdP(t)
+	h := newOIDCHandler(t, idp)
+	mux := NewMultiplexer(h, &Health{})
+
+	for i, email := range []string{
+		"not an email",
+		strings.Repeat("s", 60) + "@uni.example",
+	} {
+		rec := signInOIDC(t, mux, idp, stubIdentity{
+			Subject: "s-5-" + strconv.Itoa(i), Email: email,
+			EmailVerified: true})
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%q: status %d, want 422", email, rec.Code)
+		}
+		if _, err := h.Users.GetByEmail(context.Background(),
+			email); err == nil {
+			t.Errorf("%q: user is created", email)
+		}
+	}
+}
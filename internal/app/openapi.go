@@ -0,0 +1,467 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/internal/service"
+	"VEEEKTOR_api/internal/version"
+	e "VEEEKTOR_api/pkg/errors"
+	"VEEEKTOR_api/pkg/openapi"
+)
+
+// Description of route in API specification.
+// Body and response are values of DTO types, nil - no body.
+type operation struct {
+	summary  string
+	auth     bool
+	body     any
+	response any
+	// Response of v2 when it differs from v1
+	responseV2 any
+	// Success is answered with 302 instead of 200
+	redirect bool
+	// Error statuses besides 405, 429 of limited routes is added
+	errors []int
+	// Url values of query string, integers unless listed in queryTypes
+	query []string
+}
+
+// Struct schema put into components under given name.
+type named struct {
+	name string
+	v    any
+}
+
+type errorResponse struct {
+	Error e.APIError `json:"error"`
+}
+
+// Error body of v1 and unversioned routes.
+type legacyErrorResponse struct {
+	Error string `json:"Error"`
+}
+
+type idResponse struct {
+	Id int `json:"id"`
+}
+
+var (
+	errorSchema       = named{"Error", errorResponse{}}
+	legacyErrorSchema = named{"LegacyError", legacyErrorResponse{}}
+	idSchema          = named{"Id", idResponse{}}
+)
+
+// Every route of route tables must be described here,
+// paths are relative to API version prefix.
+var operations = map[string]operation{
+	// Probes and specification, paths are absolute
+	"GET /healthz": {summary: "Liveness probe"},
+	"GET /readyz": {summary: "Readiness probe",
+		response: named{"Readiness", readinessResponse{}},
+		errors:   []int{503}},
+	"GET /version": {summary: "Build info",
+		response: named{"Version", version.Info{}}},
+	"GET /api/openapi.json": {summary: "This specification"},
+
+	// Auth
+	"POST /auth/refresh": {summary: "Rotate refresh token",
+		body: auth.RefreshToken{}, response: auth.TokenResponse{},
+		errors: []int{400, 401, 500}},
+	"POST /auth/logout": {summary: "Delete session of refresh token",
+		body: auth.RefreshToken{}, errors: []int{400}},
+	"GET /auth/sessions": {summary: "Active sessions of user", auth: true,
+		response: []auth.SessionExportDTO{}, errors: []int{400, 401, 404}},
+	"DELETE /auth/sessions/{id}": {summary: "Revoke session", auth: true,
+		errors: []int{400, 401, 404}},
+	"DELETE /auth/sessions/others": {summary: "Revoke other sessions",
+		auth: true, errors: []int{400, 401, 500}},
+	"GET /auth/oidc/login": {
+		summary:  "Start single sign-on, redirects to identity provider",
+		redirect: true, errors: []int{404, 500}},
+	"GET /auth/oidc/callback": {
+		summary: "Finish single sign-on, challenge is returned " +
+			"when second step is needed",
+		query:    []string{"code", "state", "error"},
+		response: auth.TokenResponse{},
+		errors:   []int{400, 401, 403, 404, 409, 422, 500}},
+
+	// Users
+	"GET /users": {summary: "Current user", auth: true,
+		response: models.User{}, errors: []int{400, 401, 404, 500}},
+	"PUT /users": {summary: "Replace own profile", auth: true,
+		body: models.User{}, response: models.User{},
+		errors: []int{400, 401, 404, 409, 422}},
+	"PATCH /users": {summary: "Change fields of own profile", auth: true,
+		body: models.User{}, response: models.User{},
+		errors: []int{400, 401, 404, 409, 422}},
+	"PUT /users/password": {
+		summary: "Change password and revoke all sessions", auth: true,
+		body: models.PasswordChangeInput{}, response: auth.TokenResponse{},
+		errors: []int{400, 401, 403, 404, 422, 500}},
+	"POST /users/signin": {
+		summary: "Sign in, challenge is returned when second step is needed",
+		body:    models.SignInInput{}, response: auth.TokenResponse{},
+		errors: []int{400, 401, 403, 422, 429}},
+	"POST /users/signin/mfa": {summary: "Sign in with two-factor code",
+		body: models.MFASignInInput{}, response: auth.MFASignInResponse{},
+		errors: []int{400, 401, 403, 404, 422, 429, 500}},
+	"POST /users/signin/mfa/enroll": {
+		summary: "Enroll in two-factor authentication required by role",
+		body:    models.MFATokenInput{}, response: auth.MFAEnrollment{},
+		errors: []int{400, 403, 404, 409, 422, 500}},
+	"POST /users/signup": {summary: "Sign up student",
+		body: models.User{}, errors: []int{400, 409, 422}},
+	"POST /users/verify": {summary: "Verify email with mailed token",
+		body: models.UserTokenInput{}, errors: []int{400, 404, 422}},
+	"POST /users/verify/resend": {summary: "Mail verification token again",
+		body: models.EmailInput{}, errors: []int{400, 422}},
+	"POST /users/password/forgot": {summary: "Mail password reset token",
+		body: models.EmailInput{}, errors: []int{400, 422}},
+	"POST /users/password/reset": {
+		summary: "Set password with mailed token and revoke sessions",
+		body:    models.PasswordResetInput{},
+		errors:  []int{400, 403, 404, 422, 500}},
+
+	// Two-factor authentication
+	"GET /users/mfa": {summary: "Two-factor authentication status",
+		auth: true, response: auth.MFAStatus{},
+		errors: []int{400, 401, 404, 500}},
+	"POST /users/mfa": {summary: "Start two-factor enrollment", auth: true,
+		response: auth.MFAEnrollment{}, errors: []int{400, 401, 404, 409, 500}},
+	"POST /users/mfa/confirm": {
+		summary: "Confirm two-factor enrollment with code", auth: true,
+		body: models.MFACodeInput{}, response: auth.RecoveryCodes{},
+		errors: []int{400, 401, 403, 404, 409, 422, 429, 500}},
+	"DELETE /users/mfa": {summary: "Disable two-factor authentication",
+		auth: true, body: models.MFACodeInput{},
+		errors: []int{400, 401, 403, 404, 422, 429, 500}},
+
+	// User management
+	"GET /admin/users": {summary: "Search users", auth: true,
+		query: []string{"q", "role_id", "group_id", "dep_id",
+			"active", "limit", "offset"},
+		response: models.UserPage{}, errors: []int{400, 401, 403}},
+	"POST /admin/users": {summary: "Create user of any role", auth: true,
+		body: models.User{}, response: idSchema,
+		errors: []int{400, 401, 403, 409, 422}},
+	"GET /admin/users/{id}": {summary: "User", auth: true,
+		response: models.User{}, errors: []int{400, 401, 403, 404, 500}},
+	"PATCH /admin/users/{id}": {
+		summary: "Change role, group and department of user",
+		auth:    true, body: models.UserAdminPatch{}, response: models.User{},
+		errors: []int{400, 401, 403, 404, 422, 500}},
+	"POST /admin/users/{id}/deactivate": {
+		summary: "Deactivate user and revoke sessions", auth: true,
+		errors: []int{400, 401, 403, 404, 500}},
+	"POST /admin/users/{id}/activate": {summary: "Reactivate user",
+		auth: true, errors: []int{400, 401, 403, 404, 500}},
+	"DELETE /admin/users/{id}/sessions": {
+		summary: "Revoke all sessions of user", auth: true,
+		errors: []int{400, 401, 403, 404, 500}},
+	"DELETE /admin/users/{id}/mfa": {
+		summary: "Reset two-factor authentication of user", auth: true,
+		errors: []int{400, 401, 403, 404, 500}},
+	"PUT /admin/roles/{id}/mfa": {
+		summary: "Require two-factor authentication for role", auth: true,
+		body: models.RoleMFAInput{}, errors: []int{400, 401, 403, 404}},
+
+	// Educational envs
+	"GET /educational_envs": {summary: "Educational environments",
+		response: []models.EducationalEnv{}, errors: []int{404}},
+	"GET /educational_envs/{id}": {summary: "Educational environment",
+		response: models.EducationalEnv{}, errors: []int{400, 404}},
+	"GET /educational_envs/{id}/departments": {
+		summary:  "Departments of educational environment",
+		response: []models.Department{}, errors: []int{400, 404}},
+
+	// Departments
+	"GET /departments": {summary: "Departments",
+		response: []models.Department{}, errors: []int{404}},
+	"GET /departments/{id}": {summary: "Department",
+		response: models.Department{}, errors: []int{400, 404}},
+	"GET /departments/{id}/groups": {summary: "Groups of department",
+		response: []models.Group{}, errors: []int{400, 404}},
+
+	// Groups
+	"GET /groups/{id}": {summary: "Group",
+		response: models.Group{}, errors: []int{400, 404}},
+	"POST /groups/link": {summary: "Link group with course", auth: true,
+		body: models.GroupCourse{}, errors: []int{400, 401, 403, 422}},
+	"POST /groups/unlink": {summary: "Unlink group from course",
+		auth: true, body: models.GroupCourse{},
+		errors: []int{400, 401, 403}},
+
+	// Courses
+	"GET /courses": {summary: "Courses of group or teacher", auth: true,
+		response:   []models.CourseMultipleExportDTO{},
+		responseV2: []models.CourseMultipleExportV2DTO{},
+		errors:     []int{400, 401, 404}},
+	"POST /courses": {summary: "Create course", auth: true,
+		body: models.Course{}, response: idSchema,
+		errors: []int{400, 401, 403, 422}},
+	"GET /courses/{id}": {summary: "Course", auth: true,
+		response: models.Course{}, errors: []int{400, 401, 403, 404}},
+	"PUT /courses/{id}": {summary: "Update course", auth: true,
+		body: models.Course{}, errors: []int{400, 401, 403, 422}},
+
+	// Course infos, labs and tests
+	"GET /courses/{id}/infos": {summary: "Info pages of course",
+		auth: true, response: []models.NestedInfo{},
+		errors: []int{400, 401, 403, 404}},
+	"POST /courses/{id}/infos": {summary: "Create info page", auth: true,
+		body: models.NestedInfo{}, errors: []int{400, 401, 403, 422}},
+	"GET /infos/{id}": {summary: "Info page", auth: true,
+		response: models.NestedInfo{}, errors: []int{400, 401, 403, 404}},
+	"PUT /infos/{id}": {summary: "Update info page", auth: true,
+		body: models.NestedInfo{}, errors: []int{400, 401, 403, 404, 422}},
+	"DELETE /infos/{id}": {summary: "Delete info page", auth: true,
+		errors: []int{400, 401, 403, 404}},
+
+	"GET /courses/{id}/labs": {summary: "Labs of course", auth: true,
+		response: []models.NestedLab{}, errors: []int{400, 401, 403, 404}},
+	"POST /courses/{id}/labs": {summary: "Create lab", auth: true,
+		body: models.NestedLab{}, errors: []int{400, 401, 403, 422}},
+	"GET /labs/{id}": {summary: "Lab", auth: true,
+		response: models.NestedLab{}, errors: []int{400, 401, 403, 404}},
+	"PUT /labs/{id}": {summary: "Update lab", auth: true,
+		body: models.NestedLab{}, errors: []int{400, 401, 403, 404, 422}},
+	"DELETE /labs/{id}": {summary: "Delete lab", auth: true,
+		errors: []int{400, 401, 403, 404, 500}},
+
+	"GET /courses/{id}/tests": {summary: "Tests of course", auth: true,
+		response: []models.NestedTest{}, errors: []int{400, 401, 403, 404}},
+	"POST /courses/{id}/tests": {summary: "Create test", auth: true,
+		body: models.NestedTest{}, errors: []int{400, 401, 403, 422}},
+	"GET /tests/{id}": {summary: "Test", auth: true,
+		response: models.NestedTest{}, errors: []int{400, 401, 403, 404}},
+	"PUT /tests/{id}": {summary: "Update test", auth: true,
+		body: models.NestedTest{}, errors: []int{400, 401, 403, 404, 422}},
+	"DELETE /tests/{id}": {summary: "Delete test", auth: true,
+		errors: []int{400, 401, 403, 404}},
+}
+
+// Query string routes of unversioned API. Listings on the same
+// paths are described by url values they accept.
+var aliasOperations = map[string]operation{
+	"DELETE /auth/sessions": {summary: "Revoke session", auth: true,
+		query: []string{"id"}, errors: []int{400, 401, 404}},
+
+	"GET /educational_envs": {summary: "Educational environment(s)",
+		query: []string{"id"}, errors: []int{400, 404}},
+	"GET /departments": {summary: "Department(s)",
+		query: []string{"id", "env_id"}, errors: []int{400, 404}},
+	"GET /groups": {summary: "Group(s)",
+		query: []string{"id", "dep_id"}, errors: []int{400, 404}},
+
+	"GET /courses": {summary: "Course(s)", auth: true,
+		query: []string{"id"}, errors: []int{400, 401, 403, 404}},
+	"PUT /courses": {summary: "Update course", auth: true,
+		body: models.Course{}, errors: []int{400, 401, 403, 422}},
+
+	"GET /courses/infos": {summary: "Info page(s)", auth: true,
+		query:  []string{"id", "course_id"},
+		errors: []int{400, 401, 403, 404}},
+	"POST /courses/infos": {summary: "Create info page", auth: true,
+		body: models.NestedInfo{}, errors: []int{400, 401, 403, 422}},
+	"PUT /courses/infos": {summary: "Update info page", auth: true,
+		body: models.NestedInfo{}, errors: []int{400, 401, 403, 404, 422}},
+	"DELETE /courses/infos": {summary: "Delete info page", auth: true,
+		query: []string{"id"}, errors: []int{400, 401, 403, 404}},
+
+	"GET /courses/labs": {summary: "Lab(s)", auth: true,
+		query:  []string{"id", "course_id"},
+		errors: []int{400, 401, 403, 404}},
+	"POST /courses/labs": {summary: "Create lab", auth: true,
+		body: models.NestedLab{}, errors: []int{400, 401, 403, 422}},
+	"PUT /courses/labs": {summary: "Update lab", auth: true,
+		body: models.NestedLab{}, errors: []int{400, 401, 403, 404, 422}},
+	"DELETE /courses/labs": {summary: "Delete lab", auth: true,
+		query: []string{"id"}, errors: []int{400, 401, 403, 404, 500}},
+
+	"GET /courses/tests": {summary: "Test(s)", auth: true,
+		query:  []string{"id", "course_id"},
+		errors: []int{400, 401, 403, 404}},
+	"POST /courses/tests": {summary: "Create test", auth: true,
+		body: models.NestedTest{}, errors: []int{400, 401, 403, 422}},
+	"PUT /courses/tests": {summary: "Update test", auth: true,
+		body: models.NestedTest{}, errors: []int{400, 401, 403, 404, 422}},
+	"DELETE /courses/tests": {summary: "Delete test", auth: true,
+		query: []string{"id"}, errors: []int{400, 401, 403, 404}},
+}
+
+var wildcardRe = regexp.MustCompile(`\{(\w+)\}`)
+
+var queryTypes = map[string]string{
+	"q":      "string",
+	"active": "boolean",
+	"code":   "string",
+	"state":  "string",
+	"error":  "string",
+}
+
+// Serves specification built once on start.
+type apiSpec struct {
+	body []byte
+}
+
+func (s *apiSpec) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.body)
+}
+
+// Describes meta routes, version groups and unversioned routes.
+// Errors: routes without description are listed.
+func buildSpec(meta, routes, aliases []route) (*openapi.Document, error) {
+	doc := openapi.New("VEEEKTOR API", "2")
+	doc.Info.Description = "Electronical educational environment. " +
+		"Unversioned routes under /api serve v1 and are deprecated."
+	doc.Components.SecuritySchemes["bearer"] = openapi.SecurityScheme{
+		Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+
+	var missing []string
+	describe := func(ops map[string]operation, prefix string, r route,
+		apiVersion int, deprecated bool) {
+		key := r.method + " " + r.path
+		op, ok := ops[key]
+		if !ok {
+			missing = append(missing, r.method+" "+prefix+r.path)
+			return
+		}
+
+		if _, limited := rateLimits[key]; limited &&
+			!slices.Contains(op.errors, http.StatusTooManyRequests) {
+			op.errors = append(slices.Clone(op.errors),
+				http.StatusTooManyRequests)
+		}
+
+		doc.AddOperation(r.method, prefix+r.path,
+			newOperation(doc, r.path, op, apiVersion, deprecated))
+	}
+
+	for _, r := range meta {
+		describe(operations, "", r, service.V1, false)
+	}
+
+	for _, group := range []struct {
+		prefix  string
+		version int
+	}{
+		{apiPrefix + "/v1", service.V1},
+		{apiPrefix + "/v2", service.V2},
+	} {
+		for _, r := range routes {
+			describe(operations, group.prefix, r, group.version, false)
+		}
+	}
+
+	for _, r := range routes {
+		if _, ok := aliasOperations[r.method+" "+r.path]; !ok {
+			describe(operations, apiPrefix, r, service.V1, true)
+		}
+	}
+	for _, r := range aliases {
+		describe(aliasOperations, apiPrefix, r, service.V1, true)
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("openapi: routes are not described: %s",
+			strings.Join(missing, ", "))
+	}
+
+	return doc, nil
+}
+
+func newOperation(doc *openapi.Document, path string, op operation,
+	apiVersion int, deprecated bool) *openapi.Operation {
+	res := &openapi.Operation{
+		Summary:    op.summary,
+		Deprecated: deprecated,
+		Responses:  map[string]openapi.Response{},
+	}
+
+	// First path segment, e.g. courses
+	tag, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	res.Tags = []string{tag}
+
+	if op.auth {
+		res.Security = []map[string][]string{{"bearer": {}}}
+	}
+
+	for _, m := range wildcardRe.FindAllStringSubmatch(path, -1) {
+		res.Parameters = append(res.Parameters, openapi.Parameter{
+			Name: m[1], In: "path", Required: true,
+			Schema: &openapi.Schema{Type: "integer"}})
+	}
+	for _, key := range op.query {
+		typ, ok := queryTypes[key]
+		if !ok {
+			typ = "integer"
+		}
+		res.Parameters = append(res.Parameters, openapi.Parameter{
+			Name: key, In: "query", Schema: &openapi.Schema{Type: typ}})
+	}
+
+	if op.body != nil {
+		res.RequestBody = &openapi.RequestBody{
+			Required: true, Content: jsonContent(doc, op.body)}
+	}
+
+	ok := openapi.Response{Description: "OK"}
+	response := op.response
+	if apiVersion >= service.V2 && op.responseV2 != nil {
+		response = op.responseV2
+	}
+	if response != nil {
+		ok.Content = jsonContent(doc, response)
+	}
+	if op.redirect {
+		res.Responses["302"] = openapi.Response{Description: "Found"}
+	} else {
+		res.Responses["200"] = ok
+	}
+
+	for _, status := range op.errors {
+		res.Responses[strconv.Itoa(status)] =
+			errorResponseOf(doc, status, apiVersion)
+	}
+	res.Responses["405"] = errorResponseOf(
+		doc, http.StatusMethodNotAllowed, apiVersion)
+
+	return res
+}
+
+func jsonContent(doc *openapi.Document,
+	v any) map[string]openapi.MediaType {
+	var schema *openapi.Schema
+	if n, ok := v.(named); ok {
+		schema = doc.NamedSchemaOf(n.name, n.v)
+	} else {
+		schema = doc.SchemaOf(v)
+	}
+
+	return map[string]openapi.MediaType{
+		"application/json": {Schema: schema}}
+}
+
+// Errors are answered with envelope since v2.
+func errorResponseOf(doc *openapi.Document,
+	status, apiVersion int) openapi.Response {
+	schema := legacyErrorSchema
+	if apiVersion >= service.V2 {
+		schema = errorSchema
+	}
+
+	return openapi.Response{
+		Description: http.StatusText(status),
+		Content:     jsonContent(doc, schema),
+	}
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"VEEEKTOR_api/internal/service"
+	"VEEEKTOR_api/pkg/openapi"
+)
+
+// Every registered route must be described in specification.
+func TestSpecCoversRoutes(t *testing.T) {
+	h := &service.Handler{}
+	meta := metaRoutes(&Health{}, &apiSpec{})
+	routes, aliases := apiRoutes(h), queryAliases(h)
+
+	doc, err := buildSpec(meta, routes, aliases)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(prefix string, rs []route) {
+		for _, r := range rs {
+			if !doc.Has(r.method, prefix+r.path) {
+				t.Errorf("%s %s%s is missing from specification",
+					r.method, prefix, r.path)
+			}
+		}
+	}
+	check("", meta)
+	check(apiPrefix+"/v1", routes)
+	check(apiPrefix+"/v2", routes)
+	check(apiPrefix, routes)
+	check(apiPrefix, aliases)
+
+	// Route table without description is reported
+	undescribed := append(routes, route{"GET", "/undescribed", nil})
+	if _, err = buildSpec(meta, undescribed, aliases); err == nil {
+		t.Error("route without description is not reported")
+	}
+}
+
+func TestSpecServed(t *testing.T) {
+	mux := NewMultiplexer(&service.Handler{}, &Health{})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(
+		http.MethodGet, apiPrefix+"/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+
+	var doc openapi.Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != openapi.Version {
+		t.Errorf("openapi version %q", doc.OpenAPI)
+	}
+
+	// Constraints are taken from binding tags of DTO
+	user := doc.Components.Schemas["User"]
+	if user == nil {
+		t.Fatal("User schema is missing")
+	}
+	if user.Properties["email"].Format != "email" {
+		t.Error("email format is not taken from binding tag")
+	}
+	if max := user.Properties["name"].MaxLength; max == nil || *max != 30 {
+		t.Error("name max length is not taken from binding tag")
+	}
+}
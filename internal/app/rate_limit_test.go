@@ -0,0 +1,102 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"VEEEKTOR_api/pkg/ratelimit"
+)
+
+func TestTokenBucketRefill(t *testing.T) {
+	p := ratelimit.Policy{Name: "test", Limit: 2, Period: 2 * time.Second}
+	now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	var b ratelimit.Bucket
+	var res ratelimit.Result
+	for i := 0; i < 2; i++ {
+		if b, res = p.Take(b, now); !res.Allowed {
+			t.Fatalf("request %d of full bucket is limited", i+1)
+		}
+	}
+	b, res = p.Take(b, now)
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("empty bucket: %+v, want retry after 1s", res)
+	}
+
+	// One token per second is refilled
+	if b, res = p.Take(b, now.Add(500*time.Millisecond)); res.Allowed {
+		t.Errorf("half of token is taken: %+v", res)
+	}
+	if b, res = p.Take(b, now.Add(time.Second)); !res.Allowed {
+		t.Errorf("refilled token is not taken: %+v", res)
+	}
+
+	// Bucket is not refilled over limit
+	b, res = p.Take(b, now.Add(time.Hour))
+	if !res.Allowed || res.Remaining != p.Limit-1 {
+		t.Errorf("after long pause: %+v, want %d remaining",
+			res, p.Limit-1)
+	}
+}
+
+// Limits of rateLimits are applied by method and path, routes
+// with the same policy name and API versions share buckets.
+func TestRoutePolicies(t *testing.T) {
+	api := newTestAPI(t)
+	api.h.RateLimits = ratelimit.NewMemoryStore()
+	post := func(path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path,
+			strings.NewReader("{}"))
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		api.mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	signup := rateLimits["POST /users/signup"].policy
+	for i := 0; i < signup.Limit; i++ {
+		if rec := post(apiPrefix+"/v1/users/signup",
+			"192.0.2.1"); rec.Code == http.StatusTooManyRequests {
+			t.Fatalf("sign up %d is limited", i+1)
+		}
+	}
+	rec := post(apiPrefix+"/users/signup", "192.0.2.1")
+	if rec.Code != http.StatusTooManyRequests ||
+		rec.Header().Get("Retry-After") == "" {
+		t.Errorf("sign up over limit: status %d, Retry-After %q",
+			rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// Other routes and addresses have own buckets
+	if rec = post(apiPrefix+"/v1/users/signup",
+		"192.0.2.2"); rec.Code == http.StatusTooManyRequests {
+		t.Error("sign up of another address is limited")
+	}
+	if rec = post(apiPrefix+"/v1/users/signin",
+		"192.0.2.1"); rec.Code == http.StatusTooManyRequests {
+		t.Error("sign in is limited by sign up bucket")
+	}
+	if rec.Header().Get("RateLimit-Policy") != "30;w=60" {
+		t.Errorf("sign in policy header %q",
+			rec.Header().Get("RateLimit-Policy"))
+	}
+
+	// Second sign in step takes tokens of sign in bucket
+	for i := 1; i < rateLimits["POST /users/signin"].policy.Limit; i++ {
+		post(apiPrefix+"/v1/users/signin", "192.0.2.1")
+	}
+	if rec = post(apiPrefix+"/v2/users/signin/mfa",
+		"192.0.2.1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("two-factor step after sign in limit: status %d, want 429",
+			rec.Code)
+	}
+
+	// Routes without policy are not limited
+	if rec = post(apiPrefix+"/v1/auth/logout",
+		"192.0.2.1"); rec.Header().Get("RateLimit-Limit") != "" {
+		t.Error("route without policy is limited")
+	}
+}
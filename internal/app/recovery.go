@@ -0,0 +1,39 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+// Answers JSON 500 on handler panic instead of dropping connection.
+// http.ErrAbortHandler is re-panicked, server handles it itself.
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Version is put into context below, so body shape
+		// of v2 is chosen by path
+		if strings.HasPrefix(r.URL.Path, apiPrefix+"/v2/") {
+			r = r.WithContext(e.WithEnvelope(r.Context()))
+		}
+
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			log.Printf("panic: %v\n%s", rec, debug.Stack())
+			e.ResponseWithError(w, r, http.StatusInternalServerError,
+				e.Internal("recover", fmt.Errorf("%v", rec)))
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
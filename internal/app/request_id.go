@@ -0,0 +1,34 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+// Request id of proxy is kept when it looks sane.
+var requestIdRe = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// Sets request id on request and response,
+// so it is reported in error responses and logs.
+func withRequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(e.RequestIdHeader)
+		if !requestIdRe.MatchString(id) {
+			id = newRequestId()
+			r.Header.Set(e.RequestIdHeader, id)
+		}
+
+		w.Header().Set(e.RequestIdHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
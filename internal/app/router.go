@@ -0,0 +1,47 @@
+package app
+
+import (
+	"net/http"
+
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+// Catches status and headers written by ServeMux fallbacks.
+type headerRecorder struct {
+	header http.Header
+	status int
+}
+
+func (rec *headerRecorder) Header() http.Header { return rec.header }
+
+func (rec *headerRecorder) Write(b []byte) (int, error) { return len(b), nil }
+
+func (rec *headerRecorder) WriteHeader(status int) { rec.status = status }
+
+// ServeMux answers unknown routes and methods in plain text,
+// such answers are replaced by JSON error envelope.
+// Allow header of 405 is kept.
+func withRouteErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &headerRecorder{header: http.Header{}}
+		handler.ServeHTTP(rec, r)
+
+		switch rec.status {
+		case http.StatusMethodNotAllowed:
+			w.Header().Set("Allow", rec.header.Get("Allow"))
+			e.ResponseWithError(
+				w, r, http.StatusMethodNotAllowed, e.ErrMethodNotAllowed)
+		case http.StatusNotFound:
+			e.ResponseWithError(
+				w, r, http.StatusNotFound, e.ErrRouteNotFound)
+		default: // Redirect to cleaned path
+			mux.ServeHTTP(w, r)
+		}
+	})
+}
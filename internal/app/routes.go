@@ -0,0 +1,313 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/service"
+	"VEEEKTOR_api/pkg/ratelimit"
+)
+
+var apiPrefix = "/api"
+
+// Unversioned routes under apiPrefix serve v1 and are removed
+// after this date, clients should move to /api/v1.
+var legacySunset = time.Date(2027, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+// Course content can be changed only by teachers and admins.
+var teacherOrAdmin = service.RequireRole(auth.RoleTeacher, auth.RoleAdmin)
+
+var adminOnly = service.RequireRole(auth.RoleAdmin)
+
+type rateLimit struct {
+	policy ratelimit.Policy
+	key    service.RateKey
+}
+
+// Limited routes by method and path relative to API version prefix,
+// versions and unversioned routes share buckets.
+var rateLimits = map[string]rateLimit{
+	"POST /users/signup": {ratelimit.Policy{
+		Name: "signup", Limit: 5, Period: time.Hour}, service.ByIP},
+	"POST /users/signin": {ratelimit.Policy{
+		Name: "signin", Limit: 30, Period: time.Minute}, service.ByIP},
+	"POST /users/signin/mfa": {ratelimit.Policy{
+		Name: "signin", Limit: 30, Period: time.Minute}, service.ByIP},
+	"POST /users/signin/mfa/enroll": {ratelimit.Policy{
+		Name: "signin", Limit: 30, Period: time.Minute}, service.ByIP},
+	"POST /auth/refresh": {ratelimit.Policy{
+		Name: "refresh", Limit: 30, Period: time.Minute}, service.ByIP},
+	"GET /auth/oidc/login": {ratelimit.Policy{
+		Name: "signin", Limit: 30, Period: time.Minute}, service.ByIP},
+	"GET /auth/oidc/callback": {ratelimit.Policy{
+		Name: "signin", Limit: 30, Period: time.Minute}, service.ByIP},
+
+	// Letters and mailed tokens
+	"POST /users/verify/resend": {ratelimit.Policy{
+		Name: "mail", Limit: 5, Period: time.Hour}, service.ByIP},
+	"POST /users/password/forgot": {ratelimit.Policy{
+		Name: "mail", Limit: 5, Period: time.Hour}, service.ByIP},
+	"POST /users/verify": {ratelimit.Policy{
+		Name: "mail_token", Limit: 20, Period: time.Hour}, service.ByIP},
+	"POST /users/password/reset": {ratelimit.Policy{
+		Name: "mail_token", Limit: 20, Period: time.Hour}, service.ByIP},
+
+	// Heavy listings
+	"GET /courses": {ratelimit.Policy{
+		Name: "courses", Limit: 60, Period: time.Minute}, service.ByUser},
+	"GET /admin/users": {ratelimit.Policy{
+		Name: "admin_users", Limit: 60, Period: time.Minute}, service.ByUser},
+}
+
+// Path is relative to API version prefix,
+// paths of probes and specification are absolute.
+type route struct {
+	method  string
+	path    string
+	handler http.Handler
+}
+
+// Storage is taken from handler, so tests can serve
+// the whole API over in-memory repositories.
+// Version groups share handlers, responses are shaped by version.
+// Routes are matched by method and path, unknown methods are
+// answered with 405 and Allow header.
+func NewMultiplexer(h *service.Handler, health *Health) http.Handler {
+	mux := http.NewServeMux()
+
+	// Probes, build info and specification, not authorized
+	spec := &apiSpec{}
+	meta := metaRoutes(health, spec)
+	for _, r := range meta {
+		mux.Handle(r.method+" "+r.path, r.handler)
+	}
+
+	routes, aliases := apiRoutes(h), queryAliases(h)
+	doc, err := buildSpec(meta, routes, aliases)
+	if err != nil {
+		// Route was added without description
+		panic(err)
+	}
+	spec.body, _ = json.Marshal(doc)
+	routes, aliases = limited(h, routes), limited(h, aliases)
+
+	for _, group := range []struct {
+		prefix  string
+		version int
+	}{
+		{apiPrefix + "/v1", service.V1},
+		{apiPrefix + "/v2", service.V2},
+	} {
+		mux.Handle(group.prefix+"/", service.WithVersion(group.version)(
+			newAPIMux(group.prefix, routes)))
+	}
+
+	// Query aliases replace listings on the same paths
+	legacy := newAPIMux(apiPrefix, append(routes, aliases...))
+	mux.Handle(apiPrefix+"/", deprecated(legacy))
+
+	return withRouteErrors(mux)
+}
+
+// Later route replaces earlier one with the same method and path.
+func newAPIMux(prefix string, routes []route) http.Handler {
+	patterns := make(map[string]http.Handler, len(routes))
+	for _, r := range routes {
+		patterns[r.method+" "+prefix+r.path] = r.handler
+	}
+
+	mux := http.NewServeMux()
+	for pattern, handler := range patterns {
+		mux.Handle(pattern, handler)
+	}
+
+	return withRouteErrors(mux)
+}
+
+// Wraps routes listed in rateLimits, route table is not changed.
+func limited(h *service.Handler, routes []route) []route {
+	res := make([]route, len(routes))
+	for i, r := range routes {
+		if l, ok := rateLimits[r.method+" "+r.path]; ok {
+			r.handler = h.RateLimit(l.policy, l.key)(r.handler)
+		}
+		res[i] = r
+	}
+
+	return res
+}
+
+func metaRoutes(health *Health, spec *apiSpec) []route {
+	return []route{
+		{"GET", "/healthz", http.HandlerFunc(health.HealthzHandler)},
+		{"GET", "/readyz", http.HandlerFunc(health.ReadyzHandler)},
+		{"GET", "/version", http.HandlerFunc(health.VersionHandler)},
+		{"GET", apiPrefix + "/openapi.json", spec},
+	}
+}
+
+// Authenticated routes get principal from request context.
+func private(handler http.HandlerFunc,
+	middlewares ...service.Middleware) http.Handler {
+	var res http.Handler = handler
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		res = middlewares[i](res)
+	}
+	return service.Authenticate(res)
+}
+
+func write(handler http.HandlerFunc) http.Handler {
+	return private(handler, teacherOrAdmin)
+}
+
+func admin(handler http.HandlerFunc) http.Handler {
+	return private(handler, adminOnly)
+}
+
+func apiRoutes(h *service.Handler) []route {
+	return []route{
+		// Auth
+		{"POST", "/auth/refresh", http.HandlerFunc(h.UpdateToken)},
+		{"POST", "/auth/logout", http.HandlerFunc(h.Logout)},
+		{"GET", "/auth/sessions", private(h.SessionsGetHandler)},
+		{"DELETE", "/auth/sessions/{id}", private(h.SessionsDeleteHandler)},
+		{"DELETE", "/auth/sessions/others",
+			private(h.OtherSessionsDeleteHandler)},
+		{"GET", "/auth/oidc/login", http.HandlerFunc(h.OIDCLoginHandler)},
+		{"GET", "/auth/oidc/callback",
+			http.HandlerFunc(h.OIDCCallbackHandler)},
+
+		// Users
+		{"GET", "/users", private(h.UsersGetHandler)},
+		{"PUT", "/users", private(h.UsersUpdateHandler)},
+		{"PATCH", "/users", private(h.UsersUpdateHandler)},
+		{"PUT", "/users/password", private(h.UsersPasswordHandler)},
+		{"POST", "/users/signin", http.HandlerFunc(h.UsersSignInHandler)},
+		{"POST", "/users/signin/mfa",
+			http.HandlerFunc(h.UsersSignInMFAHandler)},
+		{"POST", "/users/signin/mfa/enroll",
+			http.HandlerFunc(h.UsersSignInMFAEnrollHandler)},
+		{"POST", "/users/signup", http.HandlerFunc(h.UsersSignUpHandler)},
+		{"POST", "/users/verify", http.HandlerFunc(h.UsersVerifyHandler)},
+		{"POST", "/users/verify/resend",
+			http.HandlerFunc(h.UsersVerifyResendHandler)},
+		{"POST", "/users/password/forgot",
+			http.HandlerFunc(h.PasswordForgotHandler)},
+		{"POST", "/users/password/reset",
+			http.HandlerFunc(h.PasswordResetHandler)},
+
+		// Two-factor authentication
+		{"GET", "/users/mfa", private(h.UsersMFAGetHandler)},
+		{"POST", "/users/mfa", private(h.UsersMFAEnrollHandler)},
+		{"POST", "/users/mfa/confirm", private(h.UsersMFAConfirmHandler)},
+		{"DELETE", "/users/mfa", private(h.UsersMFADeleteHandler)},
+
+		// User management
+		{"GET", "/admin/users", admin(h.AdminUsersGetHandler)},
+		{"POST", "/admin/users", admin(h.AdminUsersCreateHandler)},
+		{"GET", "/admin/users/{id}", admin(h.AdminUserGetHandler)},
+		{"PATCH", "/admin/users/{id}", admin(h.AdminUsersUpdateHandler)},
+		{"POST", "/admin/users/{id}/deactivate",
+			admin(h.AdminUsersDeactivateHandler)},
+		{"POST", "/admin/users/{id}/activate",
+			admin(h.AdminUsersActivateHandler)},
+		{"DELETE", "/admin/users/{id}/sessions",
+			admin(h.AdminUserSessionsDeleteHandler)},
+		{"DELETE", "/admin/users/{id}/mfa",
+			admin(h.AdminUserMFADeleteHandler)},
+		{"PUT", "/admin/roles/{id}/mfa", admin(h.AdminRoleMFAHandler)},
+
+		// Educational envs
+		{"GET", "/educational_envs",
+			http.HandlerFunc(h.EducationalEnvsGetHandler)},
+		{"GET", "/educational_envs/{id}",
+			http.HandlerFunc(h.EducationalEnvGetHandler)},
+		{"GET", "/educational_envs/{id}/departments",
+			http.HandlerFunc(h.EdEnvDepartmentsGetHandler)},
+
+		// Departments
+		{"GET", "/departments", http.HandlerFunc(h.DepartmentsGetHandler)},
+		{"GET", "/departments/{id}",
+			http.HandlerFunc(h.DepartmentGetHandler)},
+		{"GET", "/departments/{id}/groups",
+			http.HandlerFunc(h.DepartmentGroupsGetHandler)},
+
+		// Groups
+		{"GET", "/groups/{id}", http.HandlerFunc(h.GroupGetHandler)},
+		{"POST", "/groups/link", write(h.LinkGroupWithCourse)},
+		{"POST", "/groups/unlink", write(h.UnlinkGroupFromCourse)},
+
+		// Courses
+		{"GET", "/courses", private(h.CoursesGetHandler)},
+		{"POST", "/courses", write(h.CoursesCreateHandler)},
+		{"GET", "/courses/{id}", private(h.CourseGetHandler)},
+		{"PUT", "/courses/{id}", write(h.CoursesUpdateHandler)},
+
+		// Course infos, labs and tests
+		{"GET", "/courses/{id}/infos", private(h.NestedInfosGetHandler)},
+		{"POST", "/courses/{id}/infos", write(h.NestedInfosCreateHandler)},
+		{"GET", "/infos/{id}", private(h.NestedInfoGetHandler)},
+		{"PUT", "/infos/{id}", write(h.NestedInfosUpdateHandler)},
+		{"DELETE", "/infos/{id}", write(h.NestedInfosDeleteHandler)},
+
+		{"GET", "/courses/{id}/labs", private(h.NestedLabsGetHandler)},
+		{"POST", "/courses/{id}/labs", write(h.NestedLabsCreateHandler)},
+		{"GET", "/labs/{id}", private(h.NestedLabGetHandler)},
+		{"PUT", "/labs/{id}", write(h.NestedLabsUpdateHandler)},
+		{"DELETE", "/labs/{id}", write(h.NestedLabsDeleteHandler)},
+
+		{"GET", "/courses/{id}/tests", private(h.NestedTestsGetHandler)},
+		{"POST", "/courses/{id}/tests", write(h.NestedTestsCreateHandler)},
+		{"GET", "/tests/{id}", private(h.NestedTestGetHandler)},
+		{"PUT", "/tests/{id}", write(h.NestedTestsUpdateHandler)},
+		{"DELETE", "/tests/{id}", write(h.NestedTestsDeleteHandler)},
+	}
+}
+
+// Query string routes of unversioned API, kept until clients move
+// to REST paths. Listings without url values are served as before.
+func queryAliases(h *service.Handler) []route {
+	return []route{
+		{"DELETE", "/auth/sessions", private(fromQuery(nil,
+			queryAlias{"id", h.SessionsDeleteHandler}))},
+
+		{"GET", "/educational_envs", fromQuery(h.EducationalEnvsGetHandler,
+			queryAlias{"id", h.EducationalEnvGetHandler})},
+		{"GET", "/departments", fromQuery(h.DepartmentsGetHandler,
+			queryAlias{"id", h.DepartmentGetHandler},
+			queryAlias{"env_id", h.EdEnvDepartmentsGetHandler})},
+		{"GET", "/groups", fromQuery(nil,
+			queryAlias{"id", h.GroupGetHandler},
+			queryAlias{"dep_id", h.DepartmentGroupsGetHandler})},
+
+		{"GET", "/courses", private(fromQuery(h.CoursesGetHandler,
+			queryAlias{"id", h.CourseGetHandler}))},
+		{"PUT", "/courses", write(h.CoursesUpdateHandler)},
+
+		{"GET", "/courses/infos", private(fromQuery(nil,
+			queryAlias{"id", h.NestedInfoGetHandler},
+			queryAlias{"course_id", h.NestedInfosGetHandler}))},
+		{"POST", "/courses/infos", write(h.NestedInfosCreateHandler)},
+		{"PUT", "/courses/infos", write(h.NestedInfosUpdateHandler)},
+		{"DELETE", "/courses/infos", write(fromQuery(nil,
+			queryAlias{"id", h.NestedInfosDeleteHandler}))},
+
+		{"GET", "/courses/labs", private(fromQuery(nil,
+			queryAlias{"id", h.NestedLabGetHandler},
+			queryAlias{"course_id", h.NestedLabsGetHandler}))},
+		{"POST", "/courses/labs", write(h.NestedLabsCreateHandler)},
+		{"PUT", "/courses/labs", write(h.NestedLabsUpdateHandler)},
+		{"DELETE", "/courses/labs", write(fromQuery(nil,
+			queryAlias{"id", h.NestedLabsDeleteHandler}))},
+
+		{"GET", "/courses/tests", private(fromQuery(nil,
+			queryAlias{"id", h.NestedTestGetHandler},
+			queryAlias{"course_id", h.NestedTestsGetHandler}))},
+		{"POST", "/courses/tests", write(h.NestedTestsCreateHandler)},
+		{"PUT", "/courses/tests", write(h.NestedTestsUpdateHandler)},
+		{"DELETE", "/courses/tests", write(fromQuery(nil,
+			queryAlias{"id", h.NestedTestsDeleteHandler}))},
+	}
+}
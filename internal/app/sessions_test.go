@@ -0,0 +1,167 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"VEEEKTOR_api/internal/auth"
+)
+
+// Rotates refresh token of tokens answer.
+func (api *testAPI) refresh(t *testing.T,
+	refreshToken string) (auth.TokenResponse, int) {
+	t.Helper()
+	rec := api.do(http.MethodPost, "/auth/refresh", "",
+		auth.RefreshToken{Token: refreshToken})
+	var tokens auth.TokenResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &tokens); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return tokens, rec.Code
+}
+
+// Token pair of new session of user with testPassword.
+func (api *testAPI) session(t *testing.T, email string) auth.TokenResponse {
+	t.Helper()
+	rec := api.signIn(email, testPassword)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("sign in status %d: %s", rec.Code, rec.Body)
+	}
+	var tokens auth.TokenResponse
+	json.Unmarshal(rec.Body.Bytes(), &tokens)
+
+	return tokens
+}
+
+// Sessions of token owner, user without sessions is answered with 404.
+func (api *testAPI) sessions(t *testing.T,
+	accessToken string) []auth.SessionExportDTO {
+	t.Helper()
+	rec := api.do(http.MethodGet, "/auth/sessions", accessToken, nil)
+	if rec.Code == http.StatusNotFound {
+		return nil
+	} else if rec.Code != http.StatusOK {
+		t.Fatalf("sessions status %d: %s", rec.Code, rec.Body)
+	}
+	var sessions []auth.SessionExportDTO
+	json.Unmarshal(rec.Body.Bytes(), &sessions)
+
+	return sessions
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	api := newTestAPI(t)
+	api.addUser(t, "student@uni.example", auth.RoleStudent)
+	first := api.session(t, "student@uni.example")
+
+	second, status := api.refresh(t, first.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("refresh status %d", status)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token is not rotated")
+	}
+	third, status := api.refresh(t, second.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("second refresh status %d", status)
+	}
+	if sessions := api.sessions(t, third.AccessToken); len(sessions) != 1 {
+		t.Fatalf("rotation keeps one session, got %d", len(sessions))
+	}
+
+	// Replay of rotated token revokes the whole family
+	if _, status = api.refresh(t,
+		first.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("replayed token: status %d, want 401", status)
+	}
+	if _, status = api.refresh(t,
+		third.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("latest token of revoked family: status %d, want 401",
+			status)
+	}
+	if sessions := api.sessions(t, third.AccessToken); len(sessions) != 0 {
+		t.Errorf("revoked family keeps %d sessions", len(sessions))
+	}
+}
+
+func TestSessionsDelete(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.addUser(t, "student@uni.example", auth.RoleStudent)
+	api.addUser(t, "other@uni.example", auth.RoleStudent)
+	phone := api.session(t, user.Email)
+	laptop := api.session(t, user.Email)
+	browser := api.session(t, user.Email)
+	foreign := api.session(t, "other@uni.example")
+
+	sessions := api.sessions(t, phone.AccessToken)
+	if len(sessions) != 3 {
+		t.Fatalf("%d sessions, want 3", len(sessions))
+	}
+	ids := map[string]int{}
+	for _, token := range []auth.TokenResponse{
+		phone, laptop, browser, foreign} {
+		p, err := auth.ParsePrincipal(token.AccessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[token.RefreshToken] = p.SessionId
+	}
+	path := func(token auth.TokenResponse) string {
+		return "/auth/sessions/" + strconv.Itoa(ids[token.RefreshToken])
+	}
+
+	// Session of another user looks like missing one
+	if rec := api.do(http.MethodDelete, path(foreign), phone.AccessToken,
+		nil); rec.Code != http.StatusNotFound {
+		t.Errorf("foreign session: status %d, want 404", rec.Code)
+	}
+	rotated, status := api.refresh(t, foreign.RefreshToken)
+	if status != http.StatusOK {
+		t.Errorf("foreign session is revoked, refresh status %d", status)
+	}
+	if rec := api.do(http.MethodDelete, "/auth/sessions/x",
+		phone.AccessToken, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad id: status %d, want 400", rec.Code)
+	}
+
+	if rec := api.do(http.MethodDelete, path(laptop), phone.AccessToken,
+		nil); rec.Code != http.StatusOK {
+		t.Fatalf("delete status %d: %s", rec.Code, rec.Body)
+	}
+	if _, status = api.refresh(t,
+		laptop.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("revoked session: refresh status %d, want 401", status)
+	}
+	if rec := api.do(http.MethodDelete, path(laptop), phone.AccessToken,
+		nil); rec.Code != http.StatusNotFound {
+		t.Errorf("deleted twice: status %d, want 404", rec.Code)
+	}
+
+	// Token without session id can't tell current session
+	legacy := api.token(t, user)
+	if rec := api.do(http.MethodDelete, "/auth/sessions/others", legacy,
+		nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("legacy token: status %d, want 401", rec.Code)
+	}
+	if sessions = api.sessions(t, phone.AccessToken); len(sessions) != 2 {
+		t.Fatalf("legacy token removed sessions, %d left", len(sessions))
+	}
+
+	if rec := api.do(http.MethodDelete, "/auth/sessions/others",
+		phone.AccessToken, nil); rec.Code != http.StatusOK {
+		t.Fatalf("delete others status %d: %s", rec.Code, rec.Body)
+	}
+	sessions = api.sessions(t, phone.AccessToken)
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("sessions left %+v, want current one", sessions)
+	}
+	if _, status = api.refresh(t,
+		rotated.RefreshToken); status != http.StatusOK {
+		t.Errorf("sessions of other user are revoked, status %d", status)
+	}
+}
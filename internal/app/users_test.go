@@ -0,0 +1,211 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/pkg/mail"
+)
+
+// Body of unknown length (chunked transfer) is read in full.
+func TestProfileUpdateChunkedBody(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.addUser(t, "student@uni.example", auth.RoleStudent)
+
+	req := httptest.NewRequest(http.MethodPatch, apiPrefix+"/v1/users",
+		strings.NewReader(`{"name": "Stepan"}`))
+	req.ContentLength = -1
+	req.Header.Set("Authorization", "Bearer "+api.token(t, user))
+	rec := httptest.NewRecorder()
+	api.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	stored, err := api.h.Users.GetById(context.Background(), user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Stepan" || stored.Surname != user.Surname {
+		t.Errorf("stored profile %+v", stored)
+	}
+}
+
+func TestPasswordChangeRevokesSessions(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.addUser(t, "student@uni.example", auth.RoleStudent)
+	phone := api.session(t, user.Email)
+	laptop := api.session(t, user.Email)
+
+	change := models.PasswordChangeInput{CurrentPassword: "wrong-password",
+		NewPassword: "password-2"}
+	if rec := api.do(http.MethodPut, "/users/password", phone.AccessToken,
+		change); rec.Code != http.StatusForbidden {
+		t.Errorf("wrong current password: status %d, want 403", rec.Code)
+	}
+
+	change.CurrentPassword = testPassword
+	rec := api.do(http.MethodPut, "/users/password", phone.AccessToken, change)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var tokens auth.TokenResponse
+	json.Unmarshal(rec.Body.Bytes(), &tokens)
+
+	// Current session is revoked too, new pair replaces it
+	for name, old := range map[string]auth.TokenResponse{
+		"current": phone, "other": laptop} {
+		if _, status := api.refresh(t,
+			old.RefreshToken); status != http.StatusUnauthorized {
+			t.Errorf("%s session: refresh status %d, want 401",
+				name, status)
+		}
+	}
+	if _, status := api.refresh(t,
+		tokens.RefreshToken); status != http.StatusOK {
+		t.Errorf("new session: refresh status %d", status)
+	}
+
+	if rec = api.signIn(user.Email,
+		testPassword); rec.Code != http.StatusUnauthorized {
+		t.Errorf("old password: status %d, want 401", rec.Code)
+	}
+	if rec = api.signIn(user.Email, "password-2"); rec.Code != http.StatusOK {
+		t.Errorf("new password: status %d", rec.Code)
+	}
+}
+
+func TestPasswordResetToken(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.addUser(t, "student@uni.example", auth.RoleStudent)
+	session := api.session(t, user.Email)
+	forgot := func() string {
+		t.Helper()
+		if rec := api.do(http.MethodPost, "/users/password/forgot", "",
+			models.EmailInput{Email: user.Email}); rec.Code != http.StatusOK {
+			t.Fatalf("forgot status %d: %s", rec.Code, rec.Body)
+		}
+		return api.mail.token(t, user.Email)
+	}
+	reset := func(token, password string) int {
+		return api.do(http.MethodPost, "/users/password/reset", "",
+			models.PasswordResetInput{Token: token,
+				NewPassword: password}).Code
+	}
+
+	token := forgot()
+	if status := reset(token, "password-2"); status != http.StatusOK {
+		t.Fatalf("reset status %d", status)
+	}
+	if _, status := api.refresh(t,
+		session.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("session after reset: refresh status %d, want 401", status)
+	}
+	if rec := api.signIn(user.Email, "password-2"); rec.Code != http.StatusOK {
+		t.Errorf("new password: status %d", rec.Code)
+	}
+
+	// Token is single use
+	if status := reset(token, "password-3"); status != http.StatusBadRequest {
+		t.Errorf("reused token: status %d, want 400", status)
+	}
+
+	lifeTime := auth.ResetTokenLifeTime
+	t.Cleanup(func() { auth.ResetTokenLifeTime = lifeTime })
+	auth.ResetTokenLifeTime = time.Millisecond
+	token = forgot()
+	time.Sleep(10 * time.Millisecond)
+	if status := reset(token, "password-3"); status != http.StatusBadRequest {
+		t.Errorf("expired token: status %d, want 400", status)
+	}
+	if rec := api.signIn(user.Email,
+		"password-3"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("password set with expired token: status %d", rec.Code)
+	}
+}
+
+// Parallel guesses can't pass the block, attempt with right
+// password lifts block set by it.
+func TestSignInAttemptsReserved(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.addUser(t, "student@uni.example", auth.RoleStudent)
+	free := auth.AccountLoginPolicy.FreeAttempts
+
+	for i := 0; i < free; i++ {
+		api.signIn(user.Email, "wrong-password")
+	}
+	for i := 0; i < 2; i++ {
+		if rec := api.signIn(user.Email,
+			testPassword); rec.Code != http.StatusOK {
+			t.Fatalf("sign in %d after free failures: status %d: %s",
+				i+1, rec.Code, rec.Body)
+		}
+	}
+
+	other := api.addUser(t, "other@uni.example", auth.RoleStudent)
+	var wg sync.WaitGroup
+	statuses := make(chan int, 20)
+	for i := 0; i < cap(statuses); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- api.signIn(other.Email, "wrong-password").Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusUnauthorized] != free+1 ||
+		counts[http.StatusTooManyRequests] != cap(statuses)-free-1 {
+		t.Errorf("parallel guesses: %v, want %d verified", counts, free+1)
+	}
+}
+
+// Mailer which holds letters until released.
+type slowMailer struct {
+	release chan struct{}
+	mailbox
+}
+
+func (m *slowMailer) Send(ctx context.Context, msg mail.Message) error {
+	<-m.release
+	return m.mailbox.Send(ctx, msg)
+}
+
+// Registered email is answered without waiting for letter, so
+// response time doesn't tell whether email is registered.
+func TestPasswordForgotInBackground(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.addUser(t, "student@uni.example", auth.RoleStudent)
+	mailer := &slowMailer{release: make(chan struct{})}
+	api.h.Mailer = mailer
+
+	for _, email := range []string{user.Email, "nobody@uni.example"} {
+		req := httptest.NewRequest(http.MethodPost,
+			apiPrefix+"/v1/users/password/forgot",
+			strings.NewReader(`{"email":"`+email+`"}`))
+		rec := httptest.NewRecorder()
+		api.mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status %d: %s", email, rec.Code, rec.Body)
+		}
+	}
+
+	close(mailer.release)
+	api.h.WaitLetters()
+	if len(mailer.letters) != 1 || mailer.letters[0].To != user.Email {
+		t.Errorf("letters %+v, want one to %s", mailer.letters, user.Email)
+	}
+}
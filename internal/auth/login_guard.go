@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Sign in attempts of key, e.g. email:<email> or ip:<ip>. Attempt
+// is counted before credentials are verified and taken back when
+// they are right, so parallel guesses can't pass the block.
+type LoginAttempts struct {
+	Failures int
+	// Failures older than window of policy are forgotten
+	LastFailureAt time.Time
+	// Zero - sign in is not blocked
+	BlockedUntil time.Time
+}
+
+// Attempts of key are forgotten when none was made for this long
+// and key is not blocked. Longer than windows of policies.
+const LoginAttemptsRetention = 24 * time.Hour
+
+// Reports whether attempts can be forgotten at now.
+func (a LoginAttempts) Expired(now time.Time) bool {
+	return now.Sub(a.LastFailureAt) > LoginAttemptsRetention &&
+		!now.Before(a.BlockedUntil)
+}
+
+// Change of attempts of one key.
+type LoginAttemptsChange func(LoginAttempts) LoginAttempts
+
+// Audit record of lockout.
+type LoginLockout struct {
+	Id          int
+	Key         string
+	Email       string
+	Ip          string
+	Failures    int
+	LockedUntil time.Time
+	CreatedAt   time.Time
+}
+
+// Failed attempts storage. Memory implementation serves single
+// instance, Postgres one is shared by replicas.
+type LoginAttemptRepository interface {
+	// Stores attempts returned by change, zero attempts are passed
+	// when key is unknown. Key is locked until change returns, so
+	// concurrent attempts are not lost.
+	// Errors: -
+	Update(ctx context.Context, key string,
+		change LoginAttemptsChange) (LoginAttempts, error)
+	// Errors: -
+	Delete(ctx context.Context, key string) error
+	// Sets lockout id and creation time.
+	// Errors: -
+	InsertLockout(ctx context.Context, l *LoginLockout) error
+}
+
+// Limits of failed attempts of one key.
+type LoginPolicy struct {
+	// Failures allowed without delay
+	FreeAttempts int
+	// Delay after first failure over free ones, doubled on next ones
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Failures which lock key out, counter starts again after lockout
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// Failures older than window are forgotten
+	Window time.Duration
+}
+
+// Account limits are taken from config, client limits
+// allow several users behind one address.
+var (
+	AccountLoginPolicy = LoginPolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+	ClientLoginPolicy = LoginPolicy{
+		FreeAttempts:    20,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    100,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+)
+
+// Block of key after failures, reports whether it is lockout.
+func (p LoginPolicy) block(failures int) (time.Duration, bool) {
+	if failures >= p.LockoutAfter {
+		return p.LockoutDuration, true
+	}
+	if failures <= p.FreeAttempts {
+		return 0, false
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, p.MaxDelay), false
+}
+
+// Counts attempt at now unless key is blocked. Block which applies
+// when the attempt fails is set at once, so parallel attempts wait
+// for it instead of passing it.
+func (p LoginPolicy) reserve(a LoginAttempts,
+	now time.Time) (LoginAttempts, reservedKey, bool) {
+	if now.Before(a.BlockedUntil) {
+		return a, reservedKey{}, false
+	}
+	if now.Sub(a.LastFailureAt) > p.Window {
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailureAt = now
+
+	r := reservedKey{failures: a.Failures}
+	if delay, lockout := p.block(a.Failures); delay > 0 {
+		a.BlockedUntil = now.Add(delay)
+		r.until, r.lockout = a.BlockedUntil, lockout
+		if lockout {
+			a.Failures = 0
+		}
+	}
+
+	return a, r, true
+}
+
+// Attempt counted for key.
+type reservedKey struct {
+	key string
+	// Failures with the attempt
+	failures int
+	// Block set by the attempt, zero - none
+	until   time.Time
+	lockout bool
+}
+
+// Takes attempt back, block set by it is lifted unless
+// it was changed since.
+func (r reservedKey) release(a LoginAttempts) LoginAttempts {
+	if r.lockout && a.Failures == 0 {
+		a.Failures = r.failures
+	}
+	a.Failures = max(a.Failures-1, 0)
+	if !r.until.IsZero() && a.BlockedUntil.Equal(r.until) {
+		a.BlockedUntil = time.Time{}
+	}
+
+	return a
+}
+
+// Tracks sign in attempts per account and per client address
+// with exponential backoff and temporary lockout.
+type LoginGuard struct {
+	Attempts LoginAttemptRepository
+	Account  LoginPolicy
+	Client   LoginPolicy
+}
+
+func NewLoginGuard(attempts LoginAttemptRepository) *LoginGuard {
+	return &LoginGuard{
+		Attempts: attempts,
+		Account:  AccountLoginPolicy,
+		Client:   ClientLoginPolicy,
+	}
+}
+
+// Email is kept as is, the same way users are looked up.
+func accountKey(email string) string {
+	return "email:" + email
+}
+
+func clientKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Sign in attempt of email from ip.
+type LoginReservation struct {
+	email, ip string
+	// Time left until sign in is allowed, zero - attempt is counted
+	Wait time.Duration
+	keys []reservedKey
+}
+
+// Counts attempt of email from ip before credentials are verified,
+// it is either failed or released then. Blocked attempt is not
+// counted, Wait of result tells time left until sign in is allowed.
+// Errors: -
+func (g *LoginGuard) Reserve(ctx context.Context,
+	email, ip string) (*LoginReservation, error) {
+	res := &LoginReservation{email: email, ip: ip}
+	// Postgres keeps microseconds, blocks are compared on release
+	now := time.Now().Truncate(time.Microsecond)
+	for _, k := range []struct {
+		key    string
+		policy LoginPolicy
+	}{
+		{clientKey(ip), g.Client},
+		{accountKey(email), g.Account},
+	} {
+		var r reservedKey
+		counted := false
+		attempts, err := g.Attempts.Update(ctx, k.key,
+			func(a LoginAttempts) LoginAttempts {
+				a, r, counted = k.policy.reserve(a, now)
+				return a
+			})
+		if err != nil {
+			return nil, err
+		}
+
+		if !counted {
+			// Attempt counted for client is taken back
+			res.Wait = attempts.BlockedUntil.Sub(now)
+			return res, g.Release(ctx, res)
+		}
+		r.key = k.key
+		res.keys = append(res.keys, r)
+	}
+
+	return res, nil
+}
+
+// Attempt failed, lockouts set by it are logged and audited.
+// Errors: -
+func (g *LoginGuard) Failed(ctx context.Context, res *LoginReservation) error {
+	for _, k := range res.keys {
+		if !k.lockout {
+			continue
+		}
+
+		log.Printf("sign in of %s locked out until %s after %d failures",
+			k.key, k.until.Format(time.RFC3339), k.failures)
+		if err := g.Attempts.InsertLockout(ctx, &LoginLockout{
+			Key:         k.key,
+			Email:       res.email,
+			Ip:          res.ip,
+			Failures:    k.failures,
+			LockedUntil: k.until,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Credentials are right, attempt is taken back with blocks set by it.
+// Errors: -
+func (g *LoginGuard) Release(ctx context.Context,
+	res *LoginReservation) error {
+	for len(res.keys) > 0 {
+		k := res.keys[len(res.keys)-1]
+		if _, err := g.Attempts.Update(ctx, k.key, k.release); err != nil {
+			return err
+		}
+		res.keys = res.keys[:len(res.keys)-1]
+	}
+
+	return nil
+}
+
+// Forgets failures of account. Failures of client are kept,
+// so own account can't be used to reset them.
+// Errors: -
+func (g *LoginGuard) Succeeded(ctx context.Context, email string) error {
+	return g.Attempts.Delete(ctx, accountKey(email))
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+	"VEEEKTOR_api/pkg/totp"
+)
+
+// Purpose of single use token between password and code steps
+// of sign in.
+const PurposeMFAChallenge = "mfa_challenge"
+
+const (
+	MFAChallengeLifeTime = 5 * time.Minute
+	RecoveryCodesCount   = 10
+)
+
+// Shown in authenticator apps next to account email.
+var MFAIssuer = "VEEEKTOR"
+
+// TOTP enrollment of user. Enrollment is not enabled
+// until user confirms it with code.
+type MFA struct {
+	UserId  int
+	Secret  string
+	Enabled bool
+	// Step of last accepted code, codes can't be used twice
+	LastStep int64
+}
+
+// Answer of sign in when second step is needed.
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	Token       string `json:"mfa_token"`
+	// User must enroll before sign in, role requires it
+	Enroll bool `json:"enroll"`
+}
+
+// Secret to be added to authenticator app.
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	// New challenge of sign in enrollment
+	Token string `json:"mfa_token,omitempty"`
+}
+
+// Token pair of second sign in step, recovery codes are
+// returned when enrollment is confirmed by it.
+type MFASignInResponse struct {
+	TokenResponse
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+type MFAStatus struct {
+	Enabled  bool `json:"enabled"`
+	Required bool `json:"required"`
+}
+
+// Enrollments, recovery codes and role requirements storage.
+// Recovery codes are passed as digests.
+type MFARepository interface {
+	// Errors: ErrMFANotEnrolled
+	Get(ctx context.Context, userId int) (MFA, error)
+	// Replaces not confirmed enrollment.
+	// Errors: -
+	Begin(ctx context.Context, userId int, secret string) error
+	// Enables enrollment and replaces recovery codes.
+	// Errors: ErrMFANotEnrolled
+	Enable(ctx context.Context, userId int, step int64,
+		codeDigests []string) error
+	// Remembers step of accepted code, false when step is not
+	// newer than remembered one.
+	// Errors: -
+	UseStep(ctx context.Context, userId int, step int64) (bool, error)
+	// Deletes code, so it can be used once.
+	// Errors: ErrMFACodeNotValid
+	UseRecoveryCode(ctx context.Context, userId int, digest string) error
+	// Deletes enrollment with recovery codes.
+	// Errors: -
+	Delete(ctx context.Context, userId int) error
+	// Errors: -
+	RoleRequired(ctx context.Context, roleId int) (bool, error)
+	// Errors: ErrRoleNotFound
+	SetRoleRequired(ctx context.Context, roleId int, required bool) error
+}
+
+// Enrolled users and users of roles requiring it pass second step.
+// Errors: -
+func (m *Manager) NeedsMFA(ctx context.Context,
+	usr models.User) (MFAStatus, error) {
+	var status MFAStatus
+	mfa, err := m.MFA.Get(ctx, usr.Id)
+	if err == nil {
+		status.Enabled = mfa.Enabled
+	} else if err != e.ErrMFANotEnrolled {
+		return MFAStatus{}, err
+	}
+
+	if status.Required, err = m.MFA.RoleRequired(ctx, usr.RoleId); err != nil {
+		return MFAStatus{}, err
+	}
+
+	return status, nil
+}
+
+// Generates secret, previous not confirmed one stops working.
+// Errors: ErrMFAAlreadyEnabled
+func (m *Manager) BeginMFA(ctx context.Context,
+	usr models.User) (MFAEnrollment, error) {
+	mfa, err := m.MFA.Get(ctx, usr.Id)
+	if err == nil && mfa.Enabled {
+		return MFAEnrollment{}, e.ErrMFAAlreadyEnabled
+	} else if err != nil && err != e.ErrMFANotEnrolled {
+		return MFAEnrollment{}, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return MFAEnrollment{}, e.Internal("begin mfa", err)
+	}
+	if err = m.MFA.Begin(ctx, usr.Id, secret); err != nil {
+		return MFAEnrollment{}, err
+	}
+
+	return MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(MFAIssuer, usr.Email, secret),
+	}, nil
+}
+
+// Enables enrollment with code of app, returns recovery codes
+// shown to user once.
+// Errors: ErrMFANotEnrolled, ErrMFAAlreadyEnabled, ErrMFACodeNotValid
+func (m *Manager) ConfirmMFA(ctx context.Context,
+	userId int, code string) ([]string, error) {
+	mfa, err := m.MFA.Get(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled {
+		return nil, e.ErrMFAAlreadyEnabled
+	}
+
+	step, ok, err := totp.Validate(mfa.Secret, code, time.Now())
+	if err != nil {
+		return nil, e.Internal("confirm mfa", err)
+	}
+	if !ok {
+		return nil, e.ErrMFACodeNotValid
+	}
+
+	codes := make([]string, RecoveryCodesCount)
+	digests := make([]string, RecoveryCodesCount)
+	for i := range codes {
+		if codes[i], err = generateRecoveryCode(); err != nil {
+			return nil, e.Internal("confirm mfa", err)
+		}
+		digests[i] = HashRefreshToken(codes[i])
+	}
+
+	if err = m.MFA.Enable(ctx, userId, step, digests); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Accepts code of app or recovery code, both can be used once.
+// Errors: ErrMFANotEnrolled, ErrMFACodeNotValid
+func (m *Manager) CheckMFA(ctx context.Context,
+	userId int, code string) error {
+	mfa, err := m.MFA.Get(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled {
+		return e.ErrMFANotEnrolled
+	}
+
+	step, ok, err := totp.Validate(mfa.Secret, code, time.Now())
+	if err != nil {
+		return e.Internal("check mfa", err)
+	}
+	if ok {
+		if ok, err = m.MFA.UseStep(ctx, userId, step); err != nil {
+			return err
+		} else if !ok {
+			return e.ErrMFACodeNotValid
+		}
+		return nil
+	}
+
+	return m.MFA.UseRecoveryCode(ctx, userId,
+		HashRefreshToken(normalizeRecoveryCode(code)))
+}
+
+// Ten hex digits split in two, e.g. 3f9a1-c07b2.
+// Errors: -
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(b)
+
+	return code[:5] + "-" + code[5:], nil
+}
+
+// Codes are accepted without dash and in upper case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(code, "-", ""))
+	if len(code) != 10 {
+		return code
+	}
+
+	return code[:5] + "-" + code[5:]
+}
@@ -0,0 +1,57 @@
+package auth
+
+import "context"
+
+// Ids of roles table rows.
+const (
+	RoleStudent = 1
+	RoleTeacher = 2
+	RoleAdmin   = 3
+)
+
+// Owner of verified access token.
+// Tokens issued before session tracking have zero SessionId.
+type Principal struct {
+	UserId    int
+	RoleId    int
+	GroupId   int
+	SessionId int
+}
+
+func (p Principal) HasRole(roles ...int) bool {
+	for _, role := range roles {
+		if p.RoleId == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// ok is false for not authenticated requests.
+func PrincipalFromContext(ctx context.Context) (p Principal, ok bool) {
+	p, ok = ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Also checks authorization.
+// Errors: ErrTokenNotValid, ErrTokenExpired
+func ParsePrincipal(accessToken string) (Principal, error) {
+	claims, err := GetTokenClaims(accessToken)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	return Principal{
+		UserId:    claims["user_id"].(int),
+		RoleId:    claims["role_id"].(int),
+		GroupId:   claims["group_id"].(int),
+		SessionId: claims["session_id"].(int),
+	}, nil
+}
@@ -1,145 +1,221 @@
 package auth
 
 import (
-	"database/sql"
-	"errors"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"time"
 
-	"VEEEKTOR_api/pkg/database/pgsql"
+	"VEEEKTOR_api/internal/models"
 	e "VEEEKTOR_api/pkg/errors"
 )
 
+// RefreshToken holds token presented by client,
+// sessions table stores only its digest.
+// FamilyId is kept through all rotations of session refresh token.
 type Session struct {
 	Id           int       `json:"id"`
 	UserId       int       `json:"user_id"`
+	FamilyId     string    `json:"family_id"`
 	RefreshToken string    `json:"refresh_token"`
 	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastUsedAt   time.Time `json:"last_used_at"`
+	UserAgent    string    `json:"user_agent"`
+	Ip           string    `json:"ip"`
 }
 
-// User id, role_id and group_id must be valid.
-// Errors: -
-func StoreSession(user_id, role_id, groupId int) (TokenResponse, error) {
-	stmt, err := pgsql.DB.Prepare(
-		`INSERT INTO sessions (user_id, refresh_token, expires_at)
-		VALUES ($1, $2, $3)`)
-	if err != nil {
-		log.Fatal(e.ErrCantPrepareDbStmt)
-	}
+type SessionExportDTO struct {
+	Id         int    `json:"id"`
+	CreatedAt  int64  `json:"created_at"`
+	LastUsedAt int64  `json:"last_used_at"`
+	UserAgent  string `json:"user_agent"`
+	Ip         string `json:"ip"`
+	Current    bool   `json:"current"`
+}
 
-	var resp TokenResponse
-	resp.AccessToken, _ = GenerateAccessToken(user_id, role_id, groupId)
-	resp.RefreshToken, _ = GenerateRefreshToken()
+// Max count of active sessions per user,
+// oldest session is evicted when limit is reached.
+const MaxSessionsCount = 5
 
-	CheckSessionsCount(user_id)
+// Sessions storage. Refresh tokens are passed as digests.
+type SessionRepository interface {
+	// Sets session id and creation time.
+	// Errors: -
+	Insert(ctx context.Context, sess *Session, digest string) error
+	// Errors: ErrSessionNotExist
+	GetByRefreshToken(ctx context.Context, digest string) (Session, error)
+	// Replaces refresh token and remembers rotated out one.
+	// Errors: ErrSessionNotExist
+	Rotate(ctx context.Context, sess Session, oldDigest, newDigest string,
+		expiresAt time.Time, client ClientInfo) error
+	// Returns session, which family contains rotated out token.
+	// Errors: ErrSessionNotExist
+	GetByRotatedToken(ctx context.Context, digest string) (Session, error)
+	// Returns not expired sessions, recently used first.
+	// Errors: ErrSessionsNotFound
+	GetAllByUserId(ctx context.Context, userId int) ([]Session, error)
+	// Deletes oldest sessions, so only keep - 1 sessions are left.
+	// Errors: -
+	EvictOldest(ctx context.Context, userId, keep int) error
+	// Errors: -
+	DeleteByRefreshToken(ctx context.Context, digest string) error
+	// Errors: -
+	DeleteByFamilyId(ctx context.Context, familyId string) error
+	// Errors: ErrSessionNotExist
+	DeleteById(ctx context.Context, userId, sessionId int) error
+	// Errors: -
+	DeleteByUserId(ctx context.Context, userId int) error
+	// Errors: -
+	DeleteOthers(ctx context.Context, userId, sessionId int) error
+}
 
-	if _, err := stmt.Exec(
-		&user_id, &resp.RefreshToken,
-		time.Now().Add(RefreshTokenLifeTime)); err != nil {
-		log.Fatal(err)
-	}
+// Session logic over sessions and users storages.
+type Manager struct {
+	Sessions SessionRepository
+	Tokens   UserTokenRepository
+	MFA      MFARepository
+	Users    models.UserRepository
+}
 
-	return resp, nil
+func NewManager(sessions SessionRepository, tokens UserTokenRepository,
+	mfa MFARepository, users models.UserRepository) *Manager {
+	return &Manager{Sessions: sessions, Tokens: tokens, MFA: mfa, Users: users}
 }
 
-func UpdateSession(sess Session) (TokenResponse, error) {
-	stmt, err := pgsql.DB.Prepare(
-		`UPDATE sessions SET 
-		refresh_token=$2, expires_at=$3
-		WHERE refresh_token=$1`)
-	if err != nil {
-		log.Fatal(err)
-	}
+// Errors: -
+func GenerateFamilyId() (string, error) {
+	b := make([]byte, 16)
 
-	var roleId, groupId int
-	err = pgsql.DB.QueryRow(
-		`SELECT role_id, group_id FROM users WHERE id=$1`,
-		&sess.UserId).Scan(&roleId, &groupId)
-	if err != nil {
-		log.Fatal(err)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
 
+	return hex.EncodeToString(b), nil
+}
+
+// User id, role_id and group_id must be valid.
+// Errors: -
+func (m *Manager) StoreSession(ctx context.Context,
+	userId, roleId, groupId int, client ClientInfo) (TokenResponse, error) {
+	var err error
 	var resp TokenResponse
-	resp.AccessToken, _ = GenerateAccessToken(sess.UserId, roleId, groupId)
-	resp.RefreshToken, _ = GenerateRefreshToken()
+	if resp.RefreshToken, err = GenerateRefreshToken(); err != nil {
+		return TokenResponse{}, e.Internal("store session", err)
+	}
 
-	if _, err = stmt.Exec(
-		&sess.RefreshToken, &resp.RefreshToken,
-		time.Now().Add(RefreshTokenLifeTime)); err != nil {
-		log.Fatal(err)
+	sess := Session{
+		UserId:    userId,
+		ExpiresAt: time.Now().Add(RefreshTokenLifeTime),
+		UserAgent: client.UserAgent,
+		Ip:        client.Ip,
+	}
+	if sess.FamilyId, err = GenerateFamilyId(); err != nil {
+		return TokenResponse{}, e.Internal("store session", err)
 	}
 
-	return resp, nil
-}
+	if err = m.Sessions.EvictOldest(ctx, userId, MaxSessionsCount); err != nil {
+		return TokenResponse{}, err
+	}
 
-// Errors: -
-func DeleteSessionByRT(refreshToken string) error {
-	stmt, err := pgsql.DB.Prepare(
-		`DELETE FROM sessions WHERE refresh_token=$1`)
-	if err != nil {
-		log.Fatal(e.ErrCantPrepareDbStmt)
+	if err = m.Sessions.Insert(ctx,
+		&sess, HashRefreshToken(resp.RefreshToken)); err != nil {
+		return TokenResponse{}, err
 	}
 
-	if _, err := stmt.Exec(&refreshToken); err != nil {
-		log.Fatal(err)
+	if resp.AccessToken, err = GenerateAccessToken(
+		userId, roleId, groupId, sess.Id); err != nil {
+		return TokenResponse{}, err
 	}
 
-	return nil
+	return resp, nil
 }
 
-// Errors: -
-func CheckSessionsCount(user_id int) {
-	getStmt, err := pgsql.DB.Prepare(
-		`SELECT COUNT(*) FROM sessions WHERE user_id=$1`)
+// Errors: ErrSessionNotExist, ErrUserDeactivated
+func (m *Manager) UpdateSession(ctx context.Context, sess Session,
+	client ClientInfo) (TokenResponse, error) {
+	usr, err := m.Users.GetById(ctx, sess.UserId)
 	if err != nil {
-		log.Fatal(e.ErrCantPrepareDbStmt)
+		return TokenResponse{}, err
 	}
-	var count int
-	if getStmt.QueryRow(&user_id).Scan(&count); count < 5 {
-		return
+	if !usr.Active {
+		return TokenResponse{}, e.ErrUserDeactivated
 	}
 
-	deleteStmt, err := pgsql.DB.Prepare(
-		`DELETE FROM sessions WHERE user_id = $1`)
-	if err != nil {
-		log.Fatal(e.ErrCantPrepareDbStmt)
+	var resp TokenResponse
+	if resp.AccessToken, err = GenerateAccessToken(
+		sess.UserId, usr.RoleId, usr.GroupId, sess.Id); err != nil {
+		return TokenResponse{}, err
+	}
+	if resp.RefreshToken, err = GenerateRefreshToken(); err != nil {
+		return TokenResponse{}, e.Internal("update session", err)
 	}
 
-	if _, err := deleteStmt.Exec(&user_id); err != nil {
-		log.Fatal(err)
+	if err = m.Sessions.Rotate(ctx, sess,
+		HashRefreshToken(sess.RefreshToken),
+		HashRefreshToken(resp.RefreshToken),
+		time.Now().Add(RefreshTokenLifeTime), client); err != nil {
+		return TokenResponse{}, err
 	}
+
+	return resp, nil
 }
 
-// Errors: ErrSessionNotExist
-func GetSessionByRefreshToken(refreshToken string) (Session, error) {
-	stmt, err := pgsql.DB.Prepare(
-		`SELECT id, user_id, expires_at 
-		FROM sessions WHERE refresh_token=$1`)
-	if err != nil {
-		log.Fatal(e.ErrCantPrepareDbStmt)
-	}
+// Errors: -
+func (m *Manager) DeleteSessionByRT(ctx context.Context,
+	refreshToken string) error {
+	return m.Sessions.DeleteByRefreshToken(ctx, HashRefreshToken(refreshToken))
+}
 
-	var sess Session
-	if err := stmt.QueryRow(&refreshToken).Scan(
-		&sess.Id, &sess.UserId, &sess.ExpiresAt); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return Session{}, e.ErrSessionNotExist
+// Presenting already rotated token revokes whole token family.
+// Errors: ErrSessionNotExist, ErrTokenReused
+func (m *Manager) GetSessionByRefreshToken(ctx context.Context,
+	refreshToken string) (Session, error) {
+	sess, err := m.Sessions.GetByRefreshToken(ctx,
+		HashRefreshToken(refreshToken))
+	if err == e.ErrSessionNotExist {
+		if err = m.RevokeFamilyOnReuse(ctx, refreshToken); err != nil {
+			return Session{}, err
 		}
-		log.Fatal(err)
+		return Session{}, e.ErrSessionNotExist
+	} else if err != nil {
+		return Session{}, err
 	}
 	sess.RefreshToken = refreshToken
 
 	return sess, nil
 }
 
+// Deletes session, which family contains provided rotated token.
+// Rotated tokens of family are removed with session.
+// Errors: ErrTokenReused
+func (m *Manager) RevokeFamilyOnReuse(ctx context.Context,
+	refreshToken string) error {
+	sess, err := m.Sessions.GetByRotatedToken(ctx,
+		HashRefreshToken(refreshToken))
+	if err == e.ErrSessionNotExist {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err = m.Sessions.DeleteByFamilyId(ctx, sess.FamilyId); err != nil {
+		return err
+	}
+
+	log.Printf("security: refresh token reuse detected, "+
+		"session family %s of user %d revoked", sess.FamilyId, sess.UserId)
+
+	return e.ErrTokenReused
+}
+
+// Expired session is removed.
 // Errors: -
-func (sess *Session) IsExpired() (bool, error) {
+func (m *Manager) IsExpired(ctx context.Context, sess Session) (bool, error) {
 	if sess.ExpiresAt.Unix() <= time.Now().Unix() {
-		_, err := pgsql.DB.Exec(
-			`DELETE FROM sessions WHERE refresh_token=$1`,
-			&sess.RefreshToken)
-		if err != nil {
-			log.Fatal(err)
+		if err := m.DeleteSessionByRT(ctx, sess.RefreshToken); err != nil {
+			return false, err
 		}
 		return true, nil
 	}
@@ -147,18 +223,52 @@ func (sess *Session) IsExpired() (bool, error) {
 	return false, nil
 }
 
-// Removes old session
+// Removes all user sessions
 // Errors: -
-func ClearSessionsByUserId(user_id int) error {
-	stmt, err := pgsql.DB.Prepare(
-		`DELETE FROM sessions WHERE user_id=$1`)
+func (m *Manager) ClearSessionsByUserId(ctx context.Context, userId int) error {
+	return m.Sessions.DeleteByUserId(ctx, userId)
+}
+
+// Current session id is taken from access token claims.
+// Errors: ErrSessionsNotFound
+func (m *Manager) GetSessionsByUserId(ctx context.Context,
+	userId, currentSessionId int) ([]SessionExportDTO, error) {
+	sessions, err := m.Sessions.GetAllByUserId(ctx, userId)
 	if err != nil {
-		log.Fatal(e.ErrCantPrepareDbStmt)
+		return nil, err
 	}
 
-	if _, err := stmt.Exec(user_id); err != nil {
-		log.Fatal(err)
+	var dtos []SessionExportDTO
+	for _, s := range sessions {
+		dtos = append(dtos, SessionExportDTO{
+			Id:         s.Id,
+			CreatedAt:  s.CreatedAt.Unix(),
+			LastUsedAt: s.LastUsedAt.Unix(),
+			UserAgent:  s.UserAgent,
+			Ip:         s.Ip,
+			Current:    s.Id == currentSessionId,
+		})
+	}
+
+	return dtos, nil
+}
+
+// Session can be removed only by its owner.
+// Errors: ErrSessionNotExist
+func (m *Manager) DeleteSessionById(ctx context.Context,
+	userId, sessionId int) error {
+	return m.Sessions.DeleteById(ctx, userId, sessionId)
+}
+
+// Removes all user sessions except current one. Access tokens issued
+// before sessions got ids have zero session id, current session of
+// them is unknown, so nothing is removed.
+// Errors: ErrSessionUnknown
+func (m *Manager) DeleteOtherSessions(ctx context.Context,
+	userId, currentSessionId int) error {
+	if currentSessionId == 0 {
+		return e.ErrSessionUnknown
 	}
 
-	return nil
+	return m.Sessions.DeleteOthers(ctx, userId, currentSessionId)
 }
@@ -1,28 +1,33 @@
 package auth
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"log"
-	"math/rand"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 
-	"VEEEKTOR_api/pkg/database/pgsql"
 	e "VEEEKTOR_api/pkg/errors"
 )
 
+// Set from application config by Configure.
 var (
 	AccessTokenLifeTime  = time.Minute * 15    // 15 minutes
 	RefreshTokenLifeTime = time.Minute * 43800 // 30 days
-	AccessKey            = []byte(os.Getenv("JWT_KEY"))
+	AccessKey            []byte
 )
 
+// Key must be validated by caller.
+func Configure(key string, accessLifeTime, refreshLifeTime time.Duration) {
+	AccessKey = []byte(key)
+	AccessTokenLifeTime = accessLifeTime
+	RefreshTokenLifeTime = refreshLifeTime
+}
+
 type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
@@ -32,65 +37,66 @@ type TokenResponse struct {
 func GenerateRefreshToken() (string, error) {
 	b := make([]byte, 32)
 
-	s := rand.NewSource(time.Now().Unix())
-	r := rand.New(s)
-
-	if _, err := r.Read(b); err != nil {
-		log.Fatal(err)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
 
-	return fmt.Sprintf("%x", b), nil
+	return hex.EncodeToString(b), nil
+}
+
+// Only digest of refresh token is stored in database,
+// so leaked sessions table can't be used to refresh tokens.
+func HashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
 }
 
 // Errors: -
-func GenerateAccessToken(userId, roleId, groupId int) (string, error) {
+func GenerateAccessToken(userId, roleId, groupId, sessionId int) (
+	string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.MapClaims{
-		"exp":      time.Now().Add(AccessTokenLifeTime).Unix(),
-		"user_id":  userId,
-		"role_id":  roleId,
-		"group_id": groupId,
+		"exp":        time.Now().Add(AccessTokenLifeTime).Unix(),
+		"user_id":    userId,
+		"role_id":    roleId,
+		"group_id":   groupId,
+		"session_id": sessionId,
 	})
 
 	tokenString, err := token.SignedString(AccessKey)
 	if err != nil {
-		log.Fatal(err)
+		return "", e.Internal("generate access token", err)
 	}
 
 	return tokenString, nil
 }
 
-type RefreshToken struct {
-	Token string `json:"refresh_token"`
+type ClientInfo struct {
+	UserAgent string
+	Ip        string
 }
 
-// Errors: ErrTokenNotProvided
-func GetRefreshTokenFromCookieOrBody(r *http.Request) (string, error) {
-	// Try to get token from cookie
-	var err error
-	var rt RefreshToken
-	var cookie *http.Cookie
-	if cookie, err = r.Cookie("refresh_token"); err != nil &&
-		!errors.Is(err, http.ErrNoCookie) {
-		log.Fatal(err)
-	}
-	if !errors.Is(err, http.ErrNoCookie) {
-		rt.Token = cookie.Value
-	}
+// Max length of user agent stored with session
+const UserAgentMaxLen = 512
 
-	// If cookie is missing, try to get it from body
-	if errors.Is(err, http.ErrNoCookie) {
-		bytes := make([]byte, r.ContentLength)
-		r.Body.Read(bytes)
+// Errors: -
+func GetClientInfo(r *http.Request) ClientInfo {
+	var client ClientInfo
 
-		if err = json.Unmarshal(
-			bytes, &rt); err != nil {
-			return "", e.ErrTokenNotProvided
-		}
+	client.UserAgent = r.UserAgent()
+	if len(client.UserAgent) > UserAgentMaxLen {
+		client.UserAgent = client.UserAgent[:UserAgentMaxLen]
 	}
-	if rt.Token == "" {
-		return "", e.ErrTokenNotProvided
+
+	client.Ip = r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		client.Ip = host
 	}
-	return rt.Token, nil
+
+	return client
+}
+
+type RefreshToken struct {
+	Token string `json:"refresh_token"`
 }
 
 // Errors: ErrTokenNotProvided, ErrTokenNotValid
@@ -137,25 +143,12 @@ func GetTokenClaims(accessToken string) (jwt.MapClaims, error) {
 	claims["role_id"] = int(claims["role_id"].(float64))
 	claims["group_id"] = int(claims["group_id"].(float64))
 
-	return claims, nil
-}
-
-// Errors: -
-func IsRefreshTokenExpired(refreshToken string) (bool, error) {
-	stmt, err := pgsql.DB.Prepare(
-		`SELECT expires_at FROM sessions WHERE refresh_token=$1`)
-	if err != nil {
-		log.Fatal(e.ErrCantPrepareDbStmt)
-	}
-
-	var expiresAt int64
-	if err := stmt.QueryRow(&refreshToken).Scan(&expiresAt); err != nil {
-		log.Fatal(err)
+	// Tokens issued before session tracking have no session id
+	if sessionId, ok := claims["session_id"].(float64); ok {
+		claims["session_id"] = int(sessionId)
+	} else {
+		claims["session_id"] = 0
 	}
 
-	if expiresAt > time.Now().Unix() {
-		return false, nil
-	}
-
-	return true, nil
+	return claims, nil
 }
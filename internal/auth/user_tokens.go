@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+// Purposes of single use tokens sent to users by mail.
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+// Set from application config by ConfigureUserTokens.
+var (
+	VerifyTokenLifeTime = 24 * time.Hour
+	ResetTokenLifeTime  = time.Hour
+)
+
+func ConfigureUserTokens(verifyLifeTime, resetLifeTime time.Duration) {
+	VerifyTokenLifeTime = verifyLifeTime
+	ResetTokenLifeTime = resetLifeTime
+}
+
+type UserToken struct {
+	Id        int
+	UserId    int
+	Purpose   string
+	ExpiresAt time.Time
+}
+
+// Single use tokens storage. Tokens are passed as digests.
+type UserTokenRepository interface {
+	// Previous tokens of user with the same purpose are deleted.
+	// Sets token id.
+	// Errors: -
+	Replace(ctx context.Context, t *UserToken, digest string) error
+	// Deletes token, so it can be used once.
+	// Errors: ErrTokenNotValid
+	Consume(ctx context.Context, purpose, digest string) (UserToken, error)
+}
+
+// Returns token to be sent to user, only its digest is stored.
+// Errors: -
+func (m *Manager) IssueUserToken(ctx context.Context,
+	userId int, purpose string, lifeTime time.Duration) (string, error) {
+	token, err := GenerateRefreshToken()
+	if err != nil {
+		return "", e.Internal("issue user token", err)
+	}
+
+	t := UserToken{
+		UserId:    userId,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(lifeTime),
+	}
+	if err = m.Tokens.Replace(ctx, &t, HashRefreshToken(token)); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Returns id of token owner. Token can't be used again.
+// Errors: ErrTokenNotValid, ErrTokenExpired
+func (m *Manager) ConsumeUserToken(ctx context.Context,
+	purpose, token string) (int, error) {
+	t, err := m.Tokens.Consume(ctx, purpose, HashRefreshToken(token))
+	if err != nil {
+		return 0, err
+	}
+	if time.Now().After(t.ExpiresAt) {
+		return 0, e.ErrTokenExpired
+	}
+
+	return t.UserId, nil
+}
@@ -0,0 +1,512 @@
+// Package config loads application configuration
+// from optional YAML file and environment variables.
+// Environment variables override file values.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Database  Database  `yaml:"database"`
+	Auth      Auth      `yaml:"auth"`
+	Cookie    Cookie    `yaml:"cookie"`
+	CORS      CORS      `yaml:"cors"`
+	Mail      Mail      `yaml:"mail"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	OIDC      OIDC      `yaml:"oidc"`
+}
+
+type Server struct {
+	Addr         string        `yaml:"addr"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// Deadline of request context, cancels running queries.
+	// Must be less than write timeout, so error can be written.
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// Time between readiness flip and shutdown start,
+	// so load balancer notices instance is not ready.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+	// Time given to in-flight requests to finish.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type Database struct {
+	URL             string        `yaml:"url"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// Readiness probe ping timeout
+	PingTimeout time.Duration `yaml:"ping_timeout"`
+}
+
+type Auth struct {
+	JWTKey               string        `yaml:"jwt_key"`
+	AccessTokenLifeTime  time.Duration `yaml:"access_token_lifetime"`
+	RefreshTokenLifeTime time.Duration `yaml:"refresh_token_lifetime"`
+	// Lifetime of email verification and password reset tokens
+	VerifyTokenLifeTime time.Duration `yaml:"verify_token_lifetime"`
+	ResetTokenLifeTime  time.Duration `yaml:"reset_token_lifetime"`
+	// Users with unverified email can not sign in
+	RequireVerifiedEmail bool `yaml:"require_verified_email"`
+	// Failed sign in attempts are kept in memory (single instance)
+	// or postgres (shared by replicas)
+	LoginAttemptsStore string `yaml:"login_attempts_store"`
+	// Failed attempts which lock account out for lockout duration
+	LoginLockoutAfter    int           `yaml:"login_lockout_after"`
+	LoginLockoutDuration time.Duration `yaml:"login_lockout_duration"`
+}
+
+type Cookie struct {
+	Secure bool `yaml:"secure"`
+	// strict, lax or none
+	SameSite string `yaml:"same_site"`
+	Domain   string `yaml:"domain"`
+}
+
+type CORS struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+type Mail struct {
+	// smtp or file, must be set explicitly: file driver puts
+	// tokens of letters into file or log, so it is for local runs
+	Driver string `yaml:"driver"`
+	From   string `yaml:"from"`
+	// Letters are appended to this file by file driver, empty - to log
+	File string `yaml:"file"`
+	SMTP SMTP   `yaml:"smtp"`
+	// Web client address, links in letters lead to its
+	// /verify and /reset-password pages
+	LinkBaseURL string `yaml:"link_base_url"`
+}
+
+// Policies of limited routes are listed in route table.
+type RateLimit struct {
+	Enabled bool `yaml:"enabled"`
+	// Buckets are kept in memory (single instance)
+	// or postgres (shared by replicas)
+	Store string `yaml:"store"`
+}
+
+// Single sign-on with OpenID Connect provider.
+type OIDC struct {
+	Enabled bool `yaml:"enabled"`
+	// Discovery document is served under issuer
+	Issuer       string `yaml:"issuer"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// Callback registered at provider, code and state are passed
+	// from it to /auth/oidc/callback
+	RedirectURL string   `yaml:"redirect_url"`
+	Scopes      []string `yaml:"scopes"`
+	// Users created on first sign in get them
+	DefaultGroupId int `yaml:"default_group_id"`
+	DefaultDepId   int `yaml:"default_dep_id"`
+}
+
+type SMTP struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	// Empty username disables authentication
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+const JWTKeyMinLen = 32
+
+// Well known placeholders, which must never be used as a key.
+var weakJWTKeys = []string{
+	"secret", "changeme", "password", "jwt_key", "jwt-secret",
+	"Random_string_with_a-z_A-Z_0-9",
+	"Random_string_with_a-z_A-Z_0-9_at_least_32_symbols",
+}
+
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:         ":8080",
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 30 * time.Second,
+			IdleTimeout:  120 * time.Second,
+			// Less than write timeout
+			RequestTimeout: 15 * time.Second,
+
+			ShutdownDelay:   5 * time.Second,
+			ShutdownTimeout: 20 * time.Second,
+		},
+		Database: Database{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			PingTimeout:     2 * time.Second,
+		},
+		Auth: Auth{
+			AccessTokenLifeTime:  15 * time.Minute,
+			RefreshTokenLifeTime: 30 * 24 * time.Hour,
+			VerifyTokenLifeTime:  24 * time.Hour,
+			ResetTokenLifeTime:   time.Hour,
+			RequireVerifiedEmail: true,
+			LoginAttemptsStore:   "memory",
+			LoginLockoutAfter:    10,
+			LoginLockoutDuration: 15 * time.Minute,
+		},
+		Cookie: Cookie{
+			SameSite: "strict",
+		},
+		Mail: Mail{
+			From: "noreply@localhost",
+			SMTP: SMTP{Port: 587},
+		},
+		RateLimit: RateLimit{
+			Enabled: true,
+			Store:   "memory",
+		},
+		OIDC: OIDC{
+			Scopes: []string{"openid", "email", "profile"},
+		},
+	}
+}
+
+// Loads defaults, then file (if path is not empty), then env.
+// Resulting config is validated.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err = yaml.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+
+	// Malformed env values are reported with validation errors
+	envErr := cfg.loadEnv()
+	if err := cfg.Validate(); err != nil || envErr != nil {
+		return Config{}, joinErrors(nonNil(envErr, err))
+	}
+
+	return cfg, nil
+}
+
+func (cfg *Config) loadEnv() error {
+	var errs []error
+
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	integer := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			*dst = n
+		}
+	}
+	boolean := func(name string, dst *bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			*dst = b
+		}
+	}
+	duration := func(name string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			*dst = d
+		}
+	}
+	list := func(name string, dst *[]string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = nil
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*dst = append(*dst, item)
+				}
+			}
+		}
+	}
+
+	str("HTTP_ADDR", &cfg.Server.Addr)
+	duration("HTTP_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	duration("HTTP_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+	duration("HTTP_IDLE_TIMEOUT", &cfg.Server.IdleTimeout)
+	duration("HTTP_REQUEST_TIMEOUT", &cfg.Server.RequestTimeout)
+	duration("HTTP_SHUTDOWN_DELAY", &cfg.Server.ShutdownDelay)
+	duration("HTTP_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+
+	str("DATABASE_URL", &cfg.Database.URL)
+	integer("DB_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns)
+	integer("DB_MAX_IDLE_CONNS", &cfg.Database.MaxIdleConns)
+	duration("DB_CONN_MAX_LIFETIME", &cfg.Database.ConnMaxLifetime)
+	duration("DB_CONN_MAX_IDLE_TIME", &cfg.Database.ConnMaxIdleTime)
+	duration("DB_PING_TIMEOUT", &cfg.Database.PingTimeout)
+
+	str("JWT_KEY", &cfg.Auth.JWTKey)
+	duration("ACCESS_TOKEN_LIFETIME", &cfg.Auth.AccessTokenLifeTime)
+	duration("REFRESH_TOKEN_LIFETIME", &cfg.Auth.RefreshTokenLifeTime)
+	duration("VERIFY_TOKEN_LIFETIME", &cfg.Auth.VerifyTokenLifeTime)
+	duration("RESET_TOKEN_LIFETIME", &cfg.Auth.ResetTokenLifeTime)
+	boolean("REQUIRE_VERIFIED_EMAIL", &cfg.Auth.RequireVerifiedEmail)
+	str("LOGIN_ATTEMPTS_STORE", &cfg.Auth.LoginAttemptsStore)
+	integer("LOGIN_LOCKOUT_AFTER", &cfg.Auth.LoginLockoutAfter)
+	duration("LOGIN_LOCKOUT_DURATION", &cfg.Auth.LoginLockoutDuration)
+
+	boolean("COOKIE_SECURE", &cfg.Cookie.Secure)
+	str("COOKIE_SAMESITE", &cfg.Cookie.SameSite)
+	str("COOKIE_DOMAIN", &cfg.Cookie.Domain)
+
+	list("CORS_ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+
+	str("MAIL_DRIVER", &cfg.Mail.Driver)
+	str("MAIL_FROM", &cfg.Mail.From)
+	str("MAIL_FILE", &cfg.Mail.File)
+	str("MAIL_LINK_BASE_URL", &cfg.Mail.LinkBaseURL)
+	str("SMTP_HOST", &cfg.Mail.SMTP.Host)
+	integer("SMTP_PORT", &cfg.Mail.SMTP.Port)
+	str("SMTP_USERNAME", &cfg.Mail.SMTP.Username)
+	str("SMTP_PASSWORD", &cfg.Mail.SMTP.Password)
+
+	boolean("RATE_LIMIT_ENABLED", &cfg.RateLimit.Enabled)
+	str("RATE_LIMIT_STORE", &cfg.RateLimit.Store)
+
+	boolean("OIDC_ENABLED", &cfg.OIDC.Enabled)
+	str("OIDC_ISSUER", &cfg.OIDC.Issuer)
+	str("OIDC_CLIENT_ID", &cfg.OIDC.ClientID)
+	str("OIDC_CLIENT_SECRET", &cfg.OIDC.ClientSecret)
+	str("OIDC_REDIRECT_URL", &cfg.OIDC.RedirectURL)
+	list("OIDC_SCOPES", &cfg.OIDC.Scopes)
+	integer("OIDC_DEFAULT_GROUP_ID", &cfg.OIDC.DefaultGroupId)
+	integer("OIDC_DEFAULT_DEP_ID", &cfg.OIDC.DefaultDepId)
+
+	return joinErrors(errs)
+}
+
+// Collects all problems, so they can be fixed at once.
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, msg string) {
+		if !ok {
+			errs = append(errs, errors.New(msg))
+		}
+	}
+
+	check(cfg.Server.Addr != "", "server addr is empty")
+	check(cfg.Server.ReadTimeout > 0, "read timeout must be positive")
+	check(cfg.Server.WriteTimeout > 0, "write timeout must be positive")
+	check(cfg.Server.IdleTimeout > 0, "idle timeout must be positive")
+	check(cfg.Server.RequestTimeout > 0,
+		"request timeout must be positive")
+	check(cfg.Server.RequestTimeout < cfg.Server.WriteTimeout,
+		"request timeout must be less than write timeout")
+	check(cfg.Server.ShutdownDelay >= 0,
+		"shutdown delay must not be negative")
+	check(cfg.Server.ShutdownTimeout > 0,
+		"shutdown timeout must be positive")
+
+	check(cfg.Database.URL != "", "DATABASE_URL is not set")
+	check(cfg.Database.MaxOpenConns >= 0,
+		"max open connections must not be negative")
+	check(cfg.Database.MaxIdleConns >= 0,
+		"max idle connections must not be negative")
+	check(cfg.Database.PingTimeout > 0, "ping timeout must be positive")
+
+	if err := validateJWTKey(cfg.Auth.JWTKey); err != nil {
+		errs = append(errs, err)
+	}
+	check(cfg.Auth.AccessTokenLifeTime > 0,
+		"access token lifetime must be positive")
+	check(cfg.Auth.RefreshTokenLifeTime > cfg.Auth.AccessTokenLifeTime,
+		"refresh token lifetime must exceed access token lifetime")
+
+	check(cfg.Auth.VerifyTokenLifeTime > 0,
+		"verify token lifetime must be positive")
+	check(cfg.Auth.ResetTokenLifeTime > 0,
+		"reset token lifetime must be positive")
+	switch cfg.Auth.LoginAttemptsStore {
+	case "memory", "postgres":
+	default:
+		errs = append(errs, fmt.Errorf(
+			"login attempts store %q is not one of memory, postgres",
+			cfg.Auth.LoginAttemptsStore))
+	}
+	check(cfg.Auth.LoginLockoutAfter > 0,
+		"login lockout attempts must be positive")
+	check(cfg.Auth.LoginLockoutDuration > 0,
+		"login lockout duration must be positive")
+
+	switch strings.ToLower(cfg.Cookie.SameSite) {
+	case "strict", "lax":
+	case "none":
+		check(cfg.Cookie.Secure, "same site none cookie must be secure")
+	default:
+		errs = append(errs, fmt.Errorf(
+			"cookie same site %q is not one of strict, lax, none",
+			cfg.Cookie.SameSite))
+	}
+
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		if origin == "*" {
+			// Cross-site cookie is never sent to wildcard origins
+			check(!strings.EqualFold(cfg.Cookie.SameSite, "none"),
+				"cors origin \"*\" can't be used with same site none cookie, "+
+					"list origins instead")
+			continue
+		}
+		u, err := url.Parse(origin)
+		check(err == nil && u.Scheme != "" && u.Host != "",
+			fmt.Sprintf("cors origin %q is not valid", origin))
+	}
+
+	check(cfg.Mail.From != "", "mail from address is empty")
+	switch cfg.Mail.Driver {
+	case "":
+		errs = append(errs, errors.New(
+			"mail driver (MAIL_DRIVER) is not set, use smtp or file for local runs"))
+	case "file":
+	case "smtp":
+		check(cfg.Mail.SMTP.Host != "", "SMTP_HOST is not set")
+		check(cfg.Mail.SMTP.Port > 0 && cfg.Mail.SMTP.Port < 65536,
+			"smtp port is not valid")
+	default:
+		errs = append(errs, fmt.Errorf(
+			"mail driver %q is not one of smtp, file", cfg.Mail.Driver))
+	}
+	if cfg.Mail.LinkBaseURL != "" {
+		u, err := url.Parse(cfg.Mail.LinkBaseURL)
+		check(err == nil && u.Scheme != "" && u.Host != "",
+			fmt.Sprintf("mail link base url %q is not valid",
+				cfg.Mail.LinkBaseURL))
+	}
+
+	switch cfg.RateLimit.Store {
+	case "memory", "postgres":
+	default:
+		errs = append(errs, fmt.Errorf(
+			"rate limit store %q is not one of memory, postgres",
+			cfg.RateLimit.Store))
+	}
+
+	if cfg.OIDC.Enabled {
+		u, err := url.Parse(cfg.OIDC.Issuer)
+		check(err == nil && u.Scheme != "" && u.Host != "",
+			fmt.Sprintf("oidc issuer %q is not valid", cfg.OIDC.Issuer))
+		u, err = url.Parse(cfg.OIDC.RedirectURL)
+		check(err == nil && u.Scheme != "" && u.Host != "",
+			fmt.Sprintf("oidc redirect url %q is not valid",
+				cfg.OIDC.RedirectURL))
+		check(cfg.OIDC.ClientID != "", "OIDC_CLIENT_ID is not set")
+		check(slices.Contains(cfg.OIDC.Scopes, "openid"),
+			"oidc scopes must contain openid")
+		check(cfg.OIDC.DefaultGroupId > 0,
+			"OIDC_DEFAULT_GROUP_ID is not set")
+		check(cfg.OIDC.DefaultDepId > 0, "OIDC_DEFAULT_DEP_ID is not set")
+	}
+
+	if err := joinErrors(errs); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	return nil
+}
+
+func nonNil(errs ...error) []error {
+	var res []error
+	for _, err := range errs {
+		if err != nil {
+			res = append(res, err)
+		}
+	}
+
+	return res
+}
+
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+func validateJWTKey(key string) error {
+	if key == "" {
+		return errors.New("JWT_KEY is not set")
+	}
+
+	if len(key) < JWTKeyMinLen {
+		return fmt.Errorf("JWT_KEY must contain at least %d symbols",
+			JWTKeyMinLen)
+	}
+
+	for _, weak := range weakJWTKeys {
+		if strings.EqualFold(key, weak) {
+			return errors.New("JWT_KEY is a well known placeholder")
+		}
+	}
+
+	if strings.Count(key, key[:1]) == len(key) {
+		return errors.New("JWT_KEY consists of one repeated symbol")
+	}
+
+	return nil
+}
+
+// Effective config in YAML with secrets redacted.
+func (cfg Config) String() string {
+	redacted := cfg
+	if redacted.Auth.JWTKey != "" {
+		redacted.Auth.JWTKey = "REDACTED"
+	}
+	if redacted.Mail.SMTP.Password != "" {
+		redacted.Mail.SMTP.Password = "REDACTED"
+	}
+	if redacted.OIDC.ClientSecret != "" {
+		redacted.OIDC.ClientSecret = "REDACTED"
+	}
+	if u, err := url.Parse(redacted.Database.URL); err == nil &&
+		u.Scheme != "" {
+		redacted.Database.URL = u.Redacted()
+	} else if redacted.Database.URL != "" {
+		redacted.Database.URL = "REDACTED"
+	}
+
+	data, err := yaml.Marshal(redacted)
+	if err != nil {
+		return err.Error()
+	}
+
+	return string(data)
+}
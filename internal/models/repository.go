@@ -0,0 +1,131 @@
+package models
+
+import "context"
+
+// Repositories hide storage from service layer.
+// Implementations: repository/postgres for production
+// and repository/memory for tests and local runs.
+// Unless stated otherwise, Insert and Update methods
+// validate model before storing it.
+// Cancelling ctx aborts running query.
+
+type UserRepository interface {
+	// Errors: ErrUserNotFound
+	GetById(ctx context.Context, userId int) (User, error)
+	// Errors: ErrUserNotFound
+	GetByEmail(ctx context.Context, email string) (User, error)
+	// User linked to subject of identity provider.
+	// Errors: ErrUserNotFound
+	GetByIdentity(ctx context.Context, issuer, subject string) (User, error)
+	// Links subject of identity provider to user.
+	// Errors: ErrUserNotFound
+	LinkIdentity(ctx context.Context, userId int, issuer, subject string) error
+	// Collects violations of role, group and department references.
+	// Errors: ErrValidationFailed
+	CheckReferences(ctx context.Context, usr *User) error
+	// Fields must be validated and password hashed by caller.
+	// Errors: ErrValidationFailed, ErrUserExist
+	Insert(ctx context.Context, usr *User) error
+	// Password, activity and email verification are not changed.
+	// Errors: ErrUserNotFound, ErrValidationFailed, ErrUserExist
+	Update(ctx context.Context, usr *User) error
+	// Errors: -
+	UpdatePassword(ctx context.Context, userId int, hash string) error
+	// Errors: ErrUserNotFound
+	SetActive(ctx context.Context, userId int, active bool) error
+	// Errors: ErrUserNotFound
+	SetEmailVerified(ctx context.Context, userId int, verified bool) error
+	// Page of users ordered by id and total count of matching users.
+	// Passwords are not loaded.
+	// Errors: -
+	Search(ctx context.Context, f UserFilter) ([]User, int, error)
+}
+
+type CourseRepository interface {
+	// Errors: ErrCourseNotFound
+	GetById(ctx context.Context, courseId int) (Course, error)
+	// Errors: ErrCoursesNotFound
+	GetAllByGroupId(ctx context.Context,
+		groupId int) ([]CourseMultipleExportDTO, error)
+	// Errors: ErrCoursesNotFound
+	GetAllByTeacherId(ctx context.Context,
+		teacherId int) ([]CourseMultipleExportDTO, error)
+	// Errors: ErrValidationFailed
+	Insert(ctx context.Context, c *Course) (int, error)
+	// Errors: ErrCourseIdNull, ErrCourseNotFound, ErrValidationFailed
+	Update(ctx context.Context, c *Course) error
+	// User have: 0 - no access, 1 - read access, 2 - write access
+	// Errors: ErrCourseNotFound
+	CheckAccess(ctx context.Context, courseId, userId, groupId int) (int, error)
+}
+
+type GroupRepository interface {
+	// Errors: ErrGroupNotFound
+	GetById(ctx context.Context, groupId int) (Group, error)
+	// Errors: ErrGroupsNotFound
+	GetAllByDepId(ctx context.Context, depId int) ([]Group, error)
+	// Errors: ErrValidationFailed
+	Insert(ctx context.Context, g *Group) error
+	// Errors: -
+	DeleteById(ctx context.Context, groupId int) error
+	// Errors: ErrGroupLinkedToCourse, ErrValidationFailed
+	LinkCourse(ctx context.Context, gc *GroupCourse) error
+	// Errors: ErrGroupNotLinkedToCourse
+	UnlinkCourse(ctx context.Context, gc *GroupCourse) error
+}
+
+type DepartmentRepository interface {
+	// Errors: ErrDepsNotFound
+	GetAll(ctx context.Context) ([]Department, error)
+	// Errors: ErrDepNotFound
+	GetById(ctx context.Context, depId int) (Department, error)
+	// Errors: ErrDepsNotFound
+	GetAllByEnvId(ctx context.Context, envId int) ([]Department, error)
+}
+
+type EducationalEnvRepository interface {
+	// First educational environment is for admins and not listed.
+	// Errors: ErrEdEnvsNotFound
+	GetAll(ctx context.Context) ([]EducationalEnv, error)
+	// Errors: ErrEdEnvNotFound
+	GetById(ctx context.Context, envId int) (EducationalEnv, error)
+}
+
+type NestedInfoRepository interface {
+	// Errors: ErrNestedInfoNotFound
+	GetById(ctx context.Context, infoId int) (NestedInfo, error)
+	// Errors: ErrNestedInfosNotFound
+	GetAllByCourseId(ctx context.Context, courseId int) ([]NestedInfo, error)
+	// Errors: ErrValidationFailed
+	Insert(ctx context.Context, info *NestedInfo) error
+	// Errors: ErrMissingFields, ErrNestedInfoNotFound, ErrValidationFailed
+	Update(ctx context.Context, info *NestedInfo) error
+	// Errors: -
+	DeleteById(ctx context.Context, infoId int) error
+}
+
+type NestedLabRepository interface {
+	// Errors: ErrNestedLabNotFound
+	GetById(ctx context.Context, labId int) (NestedLab, error)
+	// Errors: ErrNestedLabsNotFound
+	GetAllByCourseId(ctx context.Context, courseId int) ([]NestedLab, error)
+	// Errors: ErrValidationFailed
+	Insert(ctx context.Context, lab *NestedLab) error
+	// Errors: ErrMissingFields, ErrNestedLabNotFound, ErrValidationFailed
+	Update(ctx context.Context, lab *NestedLab) error
+	// Errors: -
+	DeleteById(ctx context.Context, labId int) error
+}
+
+type NestedTestRepository interface {
+	// Errors: ErrNestedTestNotFound
+	GetById(ctx context.Context, testId int) (NestedTest, error)
+	// Errors: ErrNestedTestsNotFound
+	GetAllByCourseId(ctx context.Context, courseId int) ([]NestedTest, error)
+	// Errors: ErrValidationFailed
+	Insert(ctx context.Context, test *NestedTest) error
+	// Errors: ErrMissingFields, ErrNestedTestNotFound, ErrValidationFailed
+	Update(ctx context.Context, test *NestedTest) error
+	// Errors: -
+	DeleteById(ctx context.Context, testId int) error
+}
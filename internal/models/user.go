@@ -1,200 +1,228 @@
 package models
 
 import (
-	"database/sql"
-	"errors"
-	"fmt"
-	"log"
+	"crypto/subtle"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
 
-	"VEEEKTOR_api/pkg/database/pgsql"
 	e "VEEEKTOR_api/pkg/errors"
+	"VEEEKTOR_api/pkg/validate"
 )
 
 type User struct {
 	Id         int    `json:"id"`
-	Email      string `json:"email,omitempty"`
-	Password   string `json:"password,omitempty"`
-	GroupId    int    `json:"group_id"`
-	Name       string `json:"name"`
-	Patronymic string `json:"patronymic"`
-	Surname    string `json:"surname"`
-	RoleId     int    `json:"role_id"`
-	DepId      int    `json:"dep_id"`
+	Email      string `json:"email,omitempty" binding:"required,email,max=64"`
+	Password   string `json:"password,omitempty" binding:"required,min=8,max=50"`
+	GroupId    int    `json:"group_id" binding:"required"`
+	Name       string `json:"name" binding:"required,min=2,max=30"`
+	Patronymic string `json:"patronymic" binding:"required,min=2,max=30"`
+	Surname    string `json:"surname" binding:"required,min=2,max=30"`
+	RoleId     int    `json:"role_id" binding:"required"`
+	DepId      int    `json:"dep_id" binding:"required"`
+	// Deactivated users can not sign in
+	Active        bool `json:"active"`
+	EmailVerified bool `json:"email_verified"`
+}
+
+// Admin listing of users. Zero values of filter match any user.
+type UserFilter struct {
+	// Part of email, name, patronymic or surname, case insensitive
+	Query   string
+	RoleId  int
+	GroupId int
+	DepId   int
+	// nil - active and deactivated users
+	Active *bool
+	Limit  int
+	Offset int
 }
 
 const (
-	PasswordMinLen = 8
-	PasswordMaxLen = 50
-	FullNameMinLen = 2
-	FullNameMaxLen = 30
+	DefaultUsersLimit = 20
+	MaxUsersLimit     = 100
 )
 
-// Errors: ErrUserNotFound
-func GetUserById(userId int) (User, error) {
-	stmt, err := pgsql.DB.Prepare(`
-	SELECT email, password, group_id, name, 
-	patronymic, surname, role_id, dep_id
-	FROM users WHERE id=$1`)
-	if err != nil {
-		log.Fatal(e.ErrCantPrepareDbStmt)
+// Passwords are not exported.
+type UserPage struct {
+	Users  []User `json:"users"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+// Fields changed by admin, zero value keeps current one.
+type UserAdminPatch struct {
+	RoleId  int `json:"role_id,omitempty"`
+	GroupId int `json:"group_id,omitempty"`
+	DepId   int `json:"dep_id,omitempty"`
+}
+
+// Errors: -
+func (p *UserAdminPatch) Apply(usr *User) {
+	if p.RoleId != 0 {
+		usr.RoleId = p.RoleId
+	}
+	if p.GroupId != 0 {
+		usr.GroupId = p.GroupId
 	}
+	if p.DepId != 0 {
+		usr.DepId = p.DepId
+	}
+}
 
-	var usr User
-	usr.Id = userId
+const PasswordCost = 12
 
-	if err := stmt.QueryRow(userId).Scan(
-		&usr.Email, &usr.Password, &usr.GroupId,
-		&usr.Name, &usr.Patronymic, &usr.Surname,
-		&usr.RoleId, &usr.DepId); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return usr, e.ErrUserNotFound
-		}
-		log.Fatal(err)
+// Errors: -
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
+	if err != nil {
+		return "", err
 	}
 
-	return usr, nil
+	return string(hash), nil
 }
 
+// Rows created before hashing was introduced hold the password as is.
+func (usr *User) IsPasswordHashed() bool {
+	return strings.HasPrefix(usr.Password, "$2")
+}
+
+// Compares provided password with stored bcrypt hash
+// or legacy plaintext password.
 // Errors: ErrUserNotFound
-func GetUserByEmailAndPassword(inp SignInInput) (User, error) {
-	stmt, err := pgsql.DB.Prepare(
-		`SELECT id, email, password, group_id, name, 
-		patronymic, surname, role_id, dep_id 
-		FROM users WHERE email=$1 and password=$2`)
-	if err != nil {
-		log.Fatal(e.ErrCantPrepareDbStmt)
+func (usr *User) ComparePassword(password string) error {
+	if !usr.IsPasswordHashed() {
+		if subtle.ConstantTimeCompare(
+			[]byte(usr.Password), []byte(password)) != 1 {
+			return e.ErrUserNotFound
+		}
+		return nil
 	}
 
-	var usr User
-	if err := stmt.QueryRow(&inp.Email, &inp.Password).Scan(
-		&usr.Id, &usr.Email, &usr.Password,
-		&usr.GroupId, &usr.Name, &usr.Patronymic,
-		&usr.Surname, &usr.RoleId, &usr.DepId); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return usr, e.ErrUserNotFound
-		}
-		log.Fatal(err)
+	if err := bcrypt.CompareHashAndPassword(
+		[]byte(usr.Password), []byte(password)); err != nil {
+		return e.ErrUserNotFound
 	}
 
-	return usr, nil
+	return nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// Takes as long as ComparePassword of hashed password, so unknown
+// emails can't be told from wrong passwords by response time.
+func CompareDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword(
+			[]byte("dummy password"), PasswordCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
 }
 
-// Errors: message, ErrMissingFields, ErrRoleNotFound,
-// ErrDepNotFound, ErrUserExists
+// Checks fields only, references are checked by repository.
+// Errors: ErrValidationFailed
 func (usr *User) Validate() error {
-	if usr.RoleId == 0 || usr.DepId == 0 ||
-		usr.Password == "" || usr.Email == "" ||
-		usr.GroupId == 0 || usr.Name == "" ||
-		usr.Surname == "" {
-		return e.ErrMissingFields
-	}
+	return validate.Struct(usr).Err()
+}
 
-	if len(usr.Password) < PasswordMinLen ||
-		len(usr.Password) > PasswordMaxLen {
-		msg := fmt.Sprintf(`password must contain at least %d and no more than %d symbols lenght`, PasswordMinLen, PasswordMaxLen)
-		return errors.New(msg)
+// Same rules as Validate except password, which is stored hashed
+// and changed separately.
+// Errors: ErrValidationFailed
+func (usr *User) ValidateProfile() error {
+	res := &e.ValidationError{}
+	for _, f := range validate.Struct(usr).Fields {
+		if f.Field != "password" {
+			res.Fields = append(res.Fields, f)
+		}
 	}
 
-	if len(usr.Name) < FullNameMinLen ||
-		len(usr.Name) > FullNameMaxLen {
-		msg := fmt.Sprintf(`name must contain at least %d and no more than %d symbols lenght`, FullNameMinLen, FullNameMaxLen)
-		return errors.New(msg)
-	}
+	return res.Err()
+}
 
-	if len(usr.Patronymic) < FullNameMinLen ||
-		len(usr.Patronymic) > FullNameMaxLen {
-		msg := fmt.Sprintf(`patronymic must contain at least %d and no more than %d symbols lenght`, FullNameMinLen, FullNameMaxLen)
-		return errors.New(msg)
-	}
+type SignInInput struct {
+	Email    string `json:"email" binding:"required,email,max=64"`
+	Password string `json:"password" binding:"required,min=8,max=50"`
+}
 
-	if len(usr.Surname) < FullNameMinLen ||
-		len(usr.Surname) > FullNameMaxLen {
-		msg := fmt.Sprintf(`surname must contain at least %d and no more than %d symbols lenght`, FullNameMinLen, FullNameMaxLen)
-		return errors.New(msg)
-	}
+// Errors: ErrValidationFailed
+func (inp *SignInInput) Validate() error {
+	return validate.Struct(inp).Err()
+}
 
-	var exists bool
-	err := pgsql.DB.QueryRow(
-		`SELECT 1 FROM roles WHERE id=$1`,
-		&usr.RoleId).Scan(&exists)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return e.ErrRoleNotFound
-		}
-		log.Fatal(err)
-	}
+type PasswordChangeInput struct {
+	CurrentPassword string `json:"current_password" binding:"required,max=50"`
+	NewPassword     string `json:"new_password" binding:"required,min=8,max=50"`
+}
 
-	err = pgsql.DB.QueryRow(
-		`SELECT 1 FROM groups WHERE id=$1`,
-		&usr.GroupId).Scan(&exists)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return e.ErrGroupNotExist
-		}
-		log.Fatal(err)
-	}
+// Errors: ErrValidationFailed
+func (inp *PasswordChangeInput) Validate() error {
+	return validate.Struct(inp).Err()
+}
 
-	err = pgsql.DB.QueryRow(
-		`SELECT 1 FROM departments WHERE id=$1`,
-		&usr.DepId).Scan(&exists)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return e.ErrDepNotFound
-		}
-		log.Fatal(err)
-	}
+type EmailInput struct {
+	Email string `json:"email" binding:"required,email,max=64"`
+}
 
-	err = pgsql.DB.QueryRow(
-		`SELECT 1 FROM users WHERE email=$1`,
-		&usr.Email).Scan(&exists)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		log.Fatal(err)
-	} else if err == nil {
-		return e.ErrUserExist
-	}
+// Errors: ErrValidationFailed
+func (inp *EmailInput) Validate() error {
+	return validate.Struct(inp).Err()
+}
 
-	return nil
+// Token sent to user by mail.
+type UserTokenInput struct {
+	Token string `json:"token" binding:"required,max=64"`
 }
 
-// Errors: message, ErrMissingFields, ErrRoleNotFound,
-// ErrDepNotFound
-func (usr *User) Insert() error {
-	if err := usr.Validate(); err != nil {
-		return err
-	}
+// Errors: ErrValidationFailed
+func (inp *UserTokenInput) Validate() error {
+	return validate.Struct(inp).Err()
+}
 
-	stmt, err := pgsql.DB.Prepare(
-		`INSERT INTO users (
-		email, password, group_id, name, 
-		patronymic, surname, role_id, dep_id) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`)
-	if err != nil {
-		log.Fatal(e.ErrCantPrepareDbStmt)
-	}
-	if _, err = stmt.Exec(
-		&usr.Email, &usr.Password, &usr.GroupId,
-		&usr.Name, &usr.Patronymic, &usr.Surname,
-		&usr.RoleId, &usr.DepId); err != nil {
-		log.Fatal(err)
-	}
-	return nil
+type PasswordResetInput struct {
+	Token       string `json:"token" binding:"required,max=64"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=50"`
 }
 
-type SignInInput struct {
-	Email    string `json:"email" binding:"required,email,max=64"`
-	Password string `json:"password" binding:"required,min=8,max=50"`
+// Errors: ErrValidationFailed
+func (inp *PasswordResetInput) Validate() error {
+	return validate.Struct(inp).Err()
 }
 
-// Errors: message
-func (inp *SignInInput) Validate() error {
-	if len(inp.Email) < 4 || len(inp.Email) > 64 {
-		return errors.New("email not valid")
-	}
+// Code of authenticator app or recovery code.
+type MFACodeInput struct {
+	Code string `json:"code" binding:"required,max=16"`
+}
 
-	if len(inp.Password) < PasswordMinLen ||
-		len(inp.Password) > PasswordMaxLen {
-		msg := fmt.Sprintf(`password must contain at least %d and no more than %d symbols lenght`, PasswordMinLen, PasswordMaxLen)
-		return errors.New(msg)
-	}
-	return nil
+// Errors: ErrValidationFailed
+func (inp *MFACodeInput) Validate() error {
+	return validate.Struct(inp).Err()
+}
+
+// Challenge returned by sign in.
+type MFATokenInput struct {
+	Token string `json:"mfa_token" binding:"required,max=64"`
+}
+
+// Errors: ErrValidationFailed
+func (inp *MFATokenInput) Validate() error {
+	return validate.Struct(inp).Err()
+}
+
+type MFASignInInput struct {
+	Token string `json:"mfa_token" binding:"required,max=64"`
+	Code  string `json:"code" binding:"required,max=16"`
+}
+
+// Errors: ErrValidationFailed
+func (inp *MFASignInInput) Validate() error {
+	return validate.Struct(inp).Err()
+}
+
+type RoleMFAInput struct {
+	Required bool `json:"required"`
 }
@@ -0,0 +1,203 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type CourseRepository struct {
+	s *Store
+}
+
+func NewCourseRepository(s *Store) *CourseRepository {
+	return &CourseRepository{s: s}
+}
+
+// Errors: ErrCourseNotFound
+func (r *CourseRepository) GetById(ctx context.Context,
+	courseId int) (models.Course, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	c, ok := r.s.courses[courseId]
+	if !ok {
+		return models.Course{}, e.ErrCourseNotFound
+	}
+
+	return c.Course, nil
+}
+
+// Joins teacher and departments like Postgres query does,
+// so courses with dangling references are skipped.
+// Caller must hold the lock.
+func (r *CourseRepository) export(c course) (
+	models.CourseMultipleExportDTO, bool) {
+	var dto models.CourseMultipleExportDTO
+
+	teacher, ok := r.s.users[c.TeacherId]
+	if !ok {
+		return dto, false
+	}
+	teacherDep, ok := r.s.deps[teacher.DepId]
+	if !ok {
+		return dto, false
+	}
+	courseDep, ok := r.s.deps[c.DepId]
+	if !ok {
+		return dto, false
+	}
+
+	dto.Id = c.Id
+	dto.Name = c.Name
+	dto.Term = c.Term
+	dto.Dep = courseDep.Name
+	dto.Teacher.Name = teacher.Name
+	dto.Teacher.Patronymic = teacher.Patronymic
+	dto.Teacher.Surname = teacher.Surname
+	dto.Teacher.Dep = teacherDep.Name
+	dto.ModifiedAt = c.ModifiedAt.Unix()
+
+	return dto, true
+}
+
+// Errors: ErrCoursesNotFound
+func (r *CourseRepository) GetAllByGroupId(ctx context.Context, groupId int) (
+	[]models.CourseMultipleExportDTO, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var courses []models.CourseMultipleExportDTO
+	for _, gc := range r.s.groupCourses {
+		if gc.GroupId != groupId {
+			continue
+		}
+		c, ok := r.s.courses[gc.CourseId]
+		if !ok {
+			continue
+		}
+		if dto, ok := r.export(c); ok {
+			courses = append(courses, dto)
+		}
+	}
+
+	if len(courses) == 0 {
+		return courses, e.ErrCoursesNotFound
+	}
+	sort.Slice(courses, func(i, j int) bool {
+		return courses[i].Id < courses[j].Id
+	})
+
+	return courses, nil
+}
+
+// Errors: ErrCoursesNotFound
+func (r *CourseRepository) GetAllByTeacherId(ctx context.Context,
+	teacherId int) ([]models.CourseMultipleExportDTO, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var courses []models.CourseMultipleExportDTO
+	for _, c := range r.s.courses {
+		if c.TeacherId != teacherId {
+			continue
+		}
+		if dto, ok := r.export(c); ok {
+			courses = append(courses, dto)
+		}
+	}
+
+	if len(courses) == 0 {
+		return courses, e.ErrCoursesNotFound
+	}
+	sort.Slice(courses, func(i, j int) bool {
+		return courses[i].Id < courses[j].Id
+	})
+
+	return courses, nil
+}
+
+// Caller must hold the lock.
+// Errors: ErrCourseNotFound, ErrValidationFailed
+func (r *CourseRepository) validate(c *models.Course) error {
+	if c.Id != 0 {
+		if _, ok := r.s.courses[c.Id]; !ok {
+			return e.ErrCourseNotFound
+		}
+	}
+
+	ve := &e.ValidationError{}
+	if err := ve.Merge(c.Validate()); err != nil {
+		return err
+	}
+
+	teacher, ok := r.s.users[c.TeacherId]
+	checkReference(ve, "teacher_id", "teacher not found",
+		ok && (teacher.RoleId == 2 || teacher.RoleId == 3))
+	_, ok = r.s.deps[c.DepId]
+	checkReference(ve, "dep_id", "department not found", ok)
+
+	return ve.Err()
+}
+
+// Errors: ErrValidationFailed
+func (r *CourseRepository) Insert(ctx context.Context,
+	c *models.Course) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.validate(c); err != nil {
+		return 0, err
+	}
+
+	c.Id = r.s.nextId()
+	r.s.courses[c.Id] = course{Course: *c, ModifiedAt: time.Now()}
+
+	return c.Id, nil
+}
+
+// Errors: ErrCourseIdNull, ErrCourseNotFound, ErrValidationFailed
+func (r *CourseRepository) Update(ctx context.Context, c *models.Course) error {
+	if c.Id == 0 {
+		return e.ErrCourseIdNull
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.validate(c); err != nil {
+		return err
+	}
+
+	r.s.courses[c.Id] = course{Course: *c, ModifiedAt: time.Now()}
+
+	return nil
+}
+
+// User have: 0 - no access, 1 - read access, 2 - write access
+// Errors: ErrCourseNotFound
+func (r *CourseRepository) CheckAccess(
+	ctx context.Context, courseId, userId, groupId int) (int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	c, ok := r.s.courses[courseId]
+	if !ok {
+		return 0, e.ErrCourseNotFound
+	}
+
+	if c.TeacherId == userId {
+		return 2, nil
+	}
+
+	for _, gc := range r.s.groupCourses {
+		if gc.GroupId == groupId && gc.CourseId == courseId {
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type DepartmentRepository struct {
+	s *Store
+}
+
+func NewDepartmentRepository(s *Store) *DepartmentRepository {
+	return &DepartmentRepository{s: s}
+}
+
+// Errors: ErrDepsNotFound
+func (r *DepartmentRepository) GetAll(ctx context.Context) (
+	[]models.Department, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var deps []models.Department
+	for _, d := range r.s.deps {
+		deps = append(deps, d)
+	}
+
+	if len(deps) == 0 {
+		return deps, e.ErrDepsNotFound
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Id < deps[j].Id
+	})
+
+	return deps, nil
+}
+
+// Errors: ErrDepNotFound
+func (r *DepartmentRepository) GetById(ctx context.Context,
+	depId int) (models.Department, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	d, ok := r.s.deps[depId]
+	if !ok {
+		return models.Department{}, e.ErrDepNotFound
+	}
+
+	return d, nil
+}
+
+// Errors: ErrDepsNotFound
+func (r *DepartmentRepository) GetAllByEnvId(ctx context.Context, envId int) (
+	[]models.Department, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var deps []models.Department
+	for _, d := range r.s.deps {
+		if d.EnvId == envId {
+			deps = append(deps, d)
+		}
+	}
+
+	if len(deps) == 0 {
+		return deps, e.ErrDepsNotFound
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Id < deps[j].Id
+	})
+
+	return deps, nil
+}
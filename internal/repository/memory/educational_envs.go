@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type EducationalEnvRepository struct {
+	s *Store
+}
+
+func NewEducationalEnvRepository(s *Store) *EducationalEnvRepository {
+	return &EducationalEnvRepository{s: s}
+}
+
+// First educational environment is for admins and not listed.
+// Errors: ErrEdEnvsNotFound
+func (r *EducationalEnvRepository) GetAll(ctx context.Context) (
+	[]models.EducationalEnv, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var envs []models.EducationalEnv
+	for _, env := range r.s.edEnvs {
+		if env.Id != 1 {
+			envs = append(envs, env)
+		}
+	}
+
+	if len(envs) == 0 {
+		return envs, e.ErrEdEnvsNotFound
+	}
+	sort.Slice(envs, func(i, j int) bool {
+		return envs[i].Id < envs[j].Id
+	})
+
+	return envs, nil
+}
+
+// Errors: ErrEdEnvNotFound
+func (r *EducationalEnvRepository) GetById(ctx context.Context, envId int) (
+	models.EducationalEnv, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	env, ok := r.s.edEnvs[envId]
+	if !ok {
+		return models.EducationalEnv{}, e.ErrEdEnvNotFound
+	}
+
+	return env, nil
+}
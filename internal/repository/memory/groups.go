@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type GroupRepository struct {
+	s *Store
+}
+
+func NewGroupRepository(s *Store) *GroupRepository {
+	return &GroupRepository{s: s}
+}
+
+// Errors: ErrGroupNotFound
+func (r *GroupRepository) GetById(ctx context.Context,
+	groupId int) (models.Group, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	g, ok := r.s.groups[groupId]
+	if !ok {
+		return models.Group{}, e.ErrGroupNotFound
+	}
+
+	return g, nil
+}
+
+// Errors: ErrGroupsNotFound
+func (r *GroupRepository) GetAllByDepId(ctx context.Context,
+	depId int) ([]models.Group, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var groups []models.Group
+	for _, g := range r.s.groups {
+		if g.DepId == depId {
+			groups = append(groups, g)
+		}
+	}
+
+	if len(groups) == 0 {
+		return groups, e.ErrGroupsNotFound
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Id < groups[j].Id
+	})
+
+	return groups, nil
+}
+
+// Errors: ErrValidationFailed
+func (r *GroupRepository) Insert(ctx context.Context, g *models.Group) error {
+	ve := &e.ValidationError{}
+	if err := ve.Merge(g.Validate()); err != nil {
+		return err
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	_, ok := r.s.deps[g.DepId]
+	checkReference(ve, "dep_id", "department not found", ok)
+	if err := ve.Err(); err != nil {
+		return err
+	}
+
+	g.Id = r.s.nextId()
+	r.s.groups[g.Id] = *g
+
+	return nil
+}
+
+// Links of group are removed with it like ON DELETE CASCADE does.
+// Errors: -
+func (r *GroupRepository) DeleteById(ctx context.Context, groupId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.groups, groupId)
+	for id, gc := range r.s.groupCourses {
+		if gc.GroupId == groupId {
+			delete(r.s.groupCourses, id)
+		}
+	}
+
+	return nil
+}
+
+// Errors: ErrGroupLinkedToCourse, ErrValidationFailed
+func (r *GroupRepository) LinkCourse(ctx context.Context,
+	gc *models.GroupCourse) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, linked := range r.s.groupCourses {
+		if linked.GroupId == gc.GroupId && linked.CourseId == gc.CourseId {
+			return e.ErrGroupLinkedToCourse
+		}
+	}
+
+	ve := &e.ValidationError{}
+	if err := ve.Merge(gc.Validate()); err != nil {
+		return err
+	}
+
+	_, ok := r.s.groups[gc.GroupId]
+	checkReference(ve, "group_id", "group not found", ok)
+	_, ok = r.s.courses[gc.CourseId]
+	checkReference(ve, "course_id", "course not found", ok)
+	if err := ve.Err(); err != nil {
+		return err
+	}
+
+	gc.Id = r.s.nextId()
+	r.s.groupCourses[gc.Id] = *gc
+
+	return nil
+}
+
+// Errors: ErrGroupNotLinkedToCourse
+func (r *GroupRepository) UnlinkCourse(ctx context.Context,
+	gc *models.GroupCourse) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, linked := range r.s.groupCourses {
+		if linked.GroupId == gc.GroupId && linked.CourseId == gc.CourseId {
+			delete(r.s.groupCourses, id)
+			return nil
+		}
+	}
+
+	return e.ErrGroupNotLinkedToCourse
+}
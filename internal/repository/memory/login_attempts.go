@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+)
+
+type LoginAttemptRepository struct {
+	s *Store
+}
+
+func NewLoginAttemptRepository(s *Store) *LoginAttemptRepository {
+	return &LoginAttemptRepository{s: s}
+}
+
+// Stores attempts returned by change, zero attempts are passed
+// when key is unknown. Expired attempts are swept once a minute.
+// Errors: -
+func (r *LoginAttemptRepository) Update(ctx context.Context,
+	key string, change auth.LoginAttemptsChange) (auth.LoginAttempts, error) {
+	now := time.Now()
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if now.Sub(r.s.loginAttemptsSwept) > time.Minute {
+		for k, a := range r.s.loginAttempts {
+			if a.Expired(now) {
+				delete(r.s.loginAttempts, k)
+			}
+		}
+		r.s.loginAttemptsSwept = now
+	}
+
+	attempts := change(r.s.loginAttempts[key])
+	r.s.loginAttempts[key] = attempts
+
+	return attempts, nil
+}
+
+// Errors: -
+func (r *LoginAttemptRepository) Delete(ctx context.Context,
+	key string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.loginAttempts, key)
+
+	return nil
+}
+
+// Sets lockout id and creation time.
+// Errors: -
+func (r *LoginAttemptRepository) InsertLockout(ctx context.Context,
+	l *auth.LoginLockout) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	l.Id = r.s.nextId()
+	l.CreatedAt = time.Now()
+	r.s.lockouts = append(r.s.lockouts, *l)
+
+	return nil
+}
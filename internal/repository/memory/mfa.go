@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"context"
+
+	"VEEEKTOR_api/internal/auth"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type MFARepository struct {
+	s *Store
+}
+
+func NewMFARepository(s *Store) *MFARepository {
+	return &MFARepository{s: s}
+}
+
+// Errors: ErrMFANotEnrolled
+func (r *MFARepository) Get(ctx context.Context,
+	userId int) (auth.MFA, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	mfa, ok := r.s.mfa[userId]
+	if !ok {
+		return auth.MFA{}, e.ErrMFANotEnrolled
+	}
+
+	return mfa, nil
+}
+
+// Replaces not confirmed enrollment.
+// Errors: -
+func (r *MFARepository) Begin(ctx context.Context,
+	userId int, secret string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.s.mfa[userId].Enabled {
+		return nil
+	}
+	r.s.mfa[userId] = auth.MFA{UserId: userId, Secret: secret}
+
+	return nil
+}
+
+// Enables enrollment and replaces recovery codes.
+// Errors: ErrMFANotEnrolled
+func (r *MFARepository) Enable(ctx context.Context,
+	userId int, step int64, codeDigests []string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	mfa, ok := r.s.mfa[userId]
+	if !ok {
+		return e.ErrMFANotEnrolled
+	}
+	mfa.Enabled = true
+	mfa.LastStep = step
+	r.s.mfa[userId] = mfa
+
+	codes := make(map[string]bool, len(codeDigests))
+	for _, digest := range codeDigests {
+		codes[digest] = true
+	}
+	r.s.recoveryCodes[userId] = codes
+
+	return nil
+}
+
+// Remembers step of accepted code, false when step is not
+// newer than remembered one.
+// Errors: -
+func (r *MFARepository) UseStep(ctx context.Context,
+	userId int, step int64) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	mfa, ok := r.s.mfa[userId]
+	if !ok || mfa.LastStep >= step {
+		return false, nil
+	}
+	mfa.LastStep = step
+	r.s.mfa[userId] = mfa
+
+	return true, nil
+}
+
+// Deletes code, so it can be used once.
+// Errors: ErrMFACodeNotValid
+func (r *MFARepository) UseRecoveryCode(ctx context.Context,
+	userId int, digest string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if !r.s.recoveryCodes[userId][digest] {
+		return e.ErrMFACodeNotValid
+	}
+	delete(r.s.recoveryCodes[userId], digest)
+
+	return nil
+}
+
+// Deletes enrollment with recovery codes.
+// Errors: -
+func (r *MFARepository) Delete(ctx context.Context, userId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.mfa, userId)
+	delete(r.s.recoveryCodes, userId)
+
+	return nil
+}
+
+// Errors: -
+func (r *MFARepository) RoleRequired(ctx context.Context,
+	roleId int) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.s.mfaRoles[roleId], nil
+}
+
+// Errors: ErrRoleNotFound
+func (r *MFARepository) SetRoleRequired(ctx context.Context,
+	roleId int, required bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.roles[roleId]; !ok {
+		return e.ErrRoleNotFound
+	}
+	r.s.mfaRoles[roleId] = required
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type NestedInfoRepository struct {
+	s *Store
+}
+
+func NewNestedInfoRepository(s *Store) *NestedInfoRepository {
+	return &NestedInfoRepository{s: s}
+}
+
+// Errors: ErrNestedInfoNotFound
+func (r *NestedInfoRepository) GetById(ctx context.Context,
+	infoId int) (models.NestedInfo, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	info, ok := r.s.infos[infoId]
+	if !ok {
+		return models.NestedInfo{}, e.ErrNestedInfoNotFound
+	}
+
+	return info, nil
+}
+
+// Markdown is not listed like in Postgres implementation.
+// Errors: ErrNestedInfosNotFound
+func (r *NestedInfoRepository) GetAllByCourseId(ctx context.Context,
+	courseId int) ([]models.NestedInfo, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var infos []models.NestedInfo
+	for _, info := range r.s.infos {
+		if info.CourseId == courseId {
+			info.Markdown = ""
+			infos = append(infos, info)
+		}
+	}
+
+	if len(infos) == 0 {
+		return infos, e.ErrNestedInfosNotFound
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Id < infos[j].Id
+	})
+
+	return infos, nil
+}
+
+// Caller must hold the lock.
+// Errors: ErrNestedInfoNotFound, ErrValidationFailed
+func (r *NestedInfoRepository) validate(info *models.NestedInfo) error {
+	if info.Id != 0 {
+		if _, ok := r.s.infos[info.Id]; !ok {
+			return e.ErrNestedInfoNotFound
+		}
+	}
+
+	ve := &e.ValidationError{}
+	if err := ve.Merge(info.Validate()); err != nil {
+		return err
+	}
+
+	_, ok := r.s.courses[info.CourseId]
+	checkReference(ve, "course_id", "course not found", ok)
+
+	return ve.Err()
+}
+
+// Errors: ErrValidationFailed
+func (r *NestedInfoRepository) Insert(ctx context.Context,
+	info *models.NestedInfo) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.validate(info); err != nil {
+		return err
+	}
+
+	info.Id = r.s.nextId()
+	r.s.infos[info.Id] = *info
+
+	return nil
+}
+
+// Errors: ErrMissingFields, ErrNestedInfoNotFound, ErrValidationFailed
+func (r *NestedInfoRepository) Update(ctx context.Context,
+	info *models.NestedInfo) error {
+	if info.Id == 0 {
+		return e.ErrMissingFields
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.validate(info); err != nil {
+		return err
+	}
+
+	r.s.infos[info.Id] = *info
+
+	return nil
+}
+
+// Errors: -
+func (r *NestedInfoRepository) DeleteById(ctx context.Context,
+	infoId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.infos, infoId)
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type NestedLabRepository struct {
+	s *Store
+}
+
+func NewNestedLabRepository(s *Store) *NestedLabRepository {
+	return &NestedLabRepository{s: s}
+}
+
+// Errors: ErrNestedLabNotFound
+func (r *NestedLabRepository) GetById(ctx context.Context,
+	labId int) (models.NestedLab, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	lab, ok := r.s.labs[labId]
+	if !ok {
+		return models.NestedLab{}, e.ErrNestedLabNotFound
+	}
+
+	return lab, nil
+}
+
+// Only summary fields are listed like in Postgres implementation.
+// Errors: ErrNestedLabsNotFound
+func (r *NestedLabRepository) GetAllByCourseId(ctx context.Context,
+	courseId int) ([]models.NestedLab, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var labs []models.NestedLab
+	for _, lab := range r.s.labs {
+		if lab.CourseId == courseId {
+			labs = append(labs, models.NestedLab{
+				Id:       lab.Id,
+				CourseId: lab.CourseId,
+				Opens:    lab.Opens,
+				Closes:   lab.Closes,
+				Topic:    lab.Topic,
+			})
+		}
+	}
+
+	if len(labs) == 0 {
+		return labs, e.ErrNestedLabsNotFound
+	}
+	sort.Slice(labs, func(i, j int) bool {
+		return labs[i].Id < labs[j].Id
+	})
+
+	return labs, nil
+}
+
+// Caller must hold the lock.
+// Errors: ErrNestedLabNotFound, ErrValidationFailed
+func (r *NestedLabRepository) validate(lab *models.NestedLab) error {
+	if lab.Id != 0 {
+		if _, ok := r.s.labs[lab.Id]; !ok {
+			return e.ErrNestedLabNotFound
+		}
+	}
+
+	ve := &e.ValidationError{}
+	if err := ve.Merge(lab.Validate()); err != nil {
+		return err
+	}
+
+	_, ok := r.s.courses[lab.CourseId]
+	checkReference(ve, "course_id", "course not found", ok)
+	_, ok = r.s.locations[lab.LocationId]
+	checkReference(ve, "location_id", "location not found", ok)
+
+	return ve.Err()
+}
+
+// Errors: ErrValidationFailed
+func (r *NestedLabRepository) Insert(ctx context.Context,
+	lab *models.NestedLab) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.validate(lab); err != nil {
+		return err
+	}
+
+	lab.Id = r.s.nextId()
+	r.s.labs[lab.Id] = *lab
+
+	return nil
+}
+
+// Errors: ErrMissingFields, ErrNestedLabNotFound, ErrValidationFailed
+func (r *NestedLabRepository) Update(ctx context.Context,
+	lab *models.NestedLab) error {
+	if lab.Id == 0 {
+		return e.ErrMissingFields
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.validate(lab); err != nil {
+		return err
+	}
+
+	r.s.labs[lab.Id] = *lab
+
+	return nil
+}
+
+// Errors: -
+func (r *NestedLabRepository) DeleteById(ctx context.Context, labId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.labs, labId)
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type NestedTestRepository struct {
+	s *Store
+}
+
+func NewNestedTestRepository(s *Store) *NestedTestRepository {
+	return &NestedTestRepository{s: s}
+}
+
+// Errors: ErrNestedTestNotFound
+func (r *NestedTestRepository) GetById(ctx context.Context,
+	testId int) (models.NestedTest, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	test, ok := r.s.tests[testId]
+	if !ok {
+		return models.NestedTest{}, e.ErrNestedTestNotFound
+	}
+
+	return test, nil
+}
+
+// Only summary fields are listed like in Postgres implementation.
+// Errors: ErrNestedTestsNotFound
+func (r *NestedTestRepository) GetAllByCourseId(ctx context.Context,
+	courseId int) ([]models.NestedTest, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var tests []models.NestedTest
+	for _, test := range r.s.tests {
+		if test.CourseId == courseId {
+			tests = append(tests, models.NestedTest{
+				Id:       test.Id,
+				CourseId: test.CourseId,
+				Opens:    test.Opens,
+				Closes:   test.Closes,
+				Topic:    test.Topic,
+			})
+		}
+	}
+
+	if len(tests) == 0 {
+		return tests, e.ErrNestedTestsNotFound
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		return tests[i].Id < tests[j].Id
+	})
+
+	return tests, nil
+}
+
+// Caller must hold the lock.
+// Errors: ErrNestedTestNotFound, ErrValidationFailed
+func (r *NestedTestRepository) validate(test *models.NestedTest) error {
+	if test.Id != 0 {
+		if _, ok := r.s.tests[test.Id]; !ok {
+			return e.ErrNestedTestNotFound
+		}
+	}
+
+	ve := &e.ValidationError{}
+	if err := ve.Merge(test.Validate()); err != nil {
+		return err
+	}
+
+	_, ok := r.s.courses[test.CourseId]
+	checkReference(ve, "course_id", "course not found", ok)
+	_, ok = r.s.locations[test.LocationId]
+	checkReference(ve, "location_id", "location not found", ok)
+
+	return ve.Err()
+}
+
+// Errors: ErrValidationFailed
+func (r *NestedTestRepository) Insert(ctx context.Context,
+	test *models.NestedTest) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.validate(test); err != nil {
+		return err
+	}
+
+	test.Id = r.s.nextId()
+	r.s.tests[test.Id] = *test
+
+	return nil
+}
+
+// Errors: ErrMissingFields, ErrNestedTestNotFound, ErrValidationFailed
+func (r *NestedTestRepository) Update(ctx context.Context,
+	test *models.NestedTest) error {
+	if test.Id == 0 {
+		return e.ErrMissingFields
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.validate(test); err != nil {
+		return err
+	}
+
+	r.s.tests[test.Id] = *test
+
+	return nil
+}
+
+// Errors: -
+func (r *NestedTestRepository) DeleteById(ctx context.Context,
+	testId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.tests, testId)
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type SessionRepository struct {
+	s *Store
+}
+
+func NewSessionRepository(s *Store) *SessionRepository {
+	return &SessionRepository{s: s}
+}
+
+// Caller must hold the lock.
+func (r *SessionRepository) findByDigest(digest string) (session, bool) {
+	for _, sess := range r.s.sessions {
+		if sess.Digest == digest {
+			return sess, true
+		}
+	}
+
+	return session{}, false
+}
+
+// Rotated tokens of session family are removed with it.
+// Caller must hold the lock.
+func (r *SessionRepository) delete(sess session) {
+	delete(r.s.sessions, sess.Id)
+	for digest, familyId := range r.s.rotated {
+		if familyId == sess.FamilyId {
+			delete(r.s.rotated, digest)
+		}
+	}
+}
+
+// Sets session id and creation time.
+// Errors: -
+func (r *SessionRepository) Insert(ctx context.Context,
+	sess *auth.Session, digest string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	sess.Id = r.s.nextId()
+	sess.CreatedAt = time.Now()
+	sess.LastUsedAt = sess.CreatedAt
+	r.s.sessions[sess.Id] = session{Session: *sess, Digest: digest}
+
+	return nil
+}
+
+// Errors: ErrSessionNotExist
+func (r *SessionRepository) GetByRefreshToken(ctx context.Context,
+	digest string) (auth.Session, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	sess, ok := r.findByDigest(digest)
+	if !ok {
+		return auth.Session{}, e.ErrSessionNotExist
+	}
+
+	return sess.Session, nil
+}
+
+// Replaces refresh token and remembers rotated out one.
+// Errors: ErrSessionNotExist
+func (r *SessionRepository) Rotate(ctx context.Context, sess auth.Session,
+	oldDigest, newDigest string, expiresAt time.Time, client auth.ClientInfo) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// Token was rotated by concurrent request
+	stored, ok := r.findByDigest(oldDigest)
+	if !ok {
+		return e.ErrSessionNotExist
+	}
+
+	stored.Digest = newDigest
+	stored.ExpiresAt = expiresAt
+	stored.LastUsedAt = time.Now()
+	stored.UserAgent = client.UserAgent
+	stored.Ip = client.Ip
+	r.s.sessions[stored.Id] = stored
+	r.s.rotated[oldDigest] = stored.FamilyId
+
+	return nil
+}
+
+// Errors: ErrSessionNotExist
+func (r *SessionRepository) GetByRotatedToken(ctx context.Context,
+	digest string) (auth.Session, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	familyId, ok := r.s.rotated[digest]
+	if !ok {
+		return auth.Session{}, e.ErrSessionNotExist
+	}
+
+	for _, sess := range r.s.sessions {
+		if sess.FamilyId == familyId {
+			return sess.Session, nil
+		}
+	}
+
+	return auth.Session{}, e.ErrSessionNotExist
+}
+
+// Returns not expired sessions, recently used first.
+// Errors: ErrSessionsNotFound
+func (r *SessionRepository) GetAllByUserId(ctx context.Context, userId int) (
+	[]auth.Session, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	now := time.Now()
+	var sessions []auth.Session
+	for _, sess := range r.s.sessions {
+		if sess.UserId == userId && sess.ExpiresAt.After(now) {
+			sessions = append(sessions, sess.Session)
+		}
+	}
+
+	if len(sessions) == 0 {
+		return sessions, e.ErrSessionsNotFound
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+// Deletes oldest sessions, so only keep - 1 sessions are left.
+// Errors: -
+func (r *SessionRepository) EvictOldest(ctx context.Context,
+	userId, keep int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var sessions []session
+	for _, sess := range r.s.sessions {
+		if sess.UserId == userId {
+			sessions = append(sessions, sess)
+		}
+	}
+	if len(sessions) < keep {
+		return nil
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].CreatedAt.Equal(sessions[j].CreatedAt) {
+			return sessions[i].Id < sessions[j].Id
+		}
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	for _, sess := range sessions[:len(sessions)-keep+1] {
+		r.delete(sess)
+	}
+
+	return nil
+}
+
+// Errors: -
+func (r *SessionRepository) DeleteByRefreshToken(ctx context.Context,
+	digest string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if sess, ok := r.findByDigest(digest); ok {
+		r.delete(sess)
+	}
+
+	return nil
+}
+
+// Errors: -
+func (r *SessionRepository) DeleteByFamilyId(ctx context.Context,
+	familyId string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, sess := range r.s.sessions {
+		if sess.FamilyId == familyId {
+			r.delete(sess)
+		}
+	}
+
+	return nil
+}
+
+// Errors: ErrSessionNotExist
+func (r *SessionRepository) DeleteById(ctx context.Context,
+	userId, sessionId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	sess, ok := r.s.sessions[sessionId]
+	if !ok || sess.UserId != userId {
+		return e.ErrSessionNotExist
+	}
+	r.delete(sess)
+
+	return nil
+}
+
+// Errors: -
+func (r *SessionRepository) DeleteByUserId(ctx context.Context,
+	userId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, sess := range r.s.sessions {
+		if sess.UserId == userId {
+			r.delete(sess)
+		}
+	}
+
+	return nil
+}
+
+// Errors: -
+func (r *SessionRepository) DeleteOthers(ctx context.Context,
+	userId, sessionId int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, sess := range r.s.sessions {
+		if sess.UserId == userId && sess.Id != sessionId {
+			r.delete(sess)
+		}
+	}
+
+	return nil
+}
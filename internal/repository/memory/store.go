@@ -0,0 +1,145 @@
+// Package memory keeps all data in process memory.
+// It is used by tests and local runs without a database.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type course struct {
+	models.Course
+	ModifiedAt time.Time
+}
+
+type session struct {
+	auth.Session
+	Digest string
+}
+
+// Store is shared by all memory repositories,
+// so references between tables can be checked like in Postgres.
+type Store struct {
+	mu sync.RWMutex
+
+	lastId int
+
+	roles        map[int]string
+	edEnvs       map[int]models.EducationalEnv
+	deps         map[int]models.Department
+	groups       map[int]models.Group
+	users        map[int]models.User
+	courses      map[int]course
+	groupCourses map[int]models.GroupCourse
+	locations    map[int]string
+	infos        map[int]models.NestedInfo
+	labs         map[int]models.NestedLab
+	tests        map[int]models.NestedTest
+	sessions     map[int]session
+	// Rotated refresh token digest to session family id
+	rotated map[string]string
+	// Single use token digest to token
+	userTokens map[string]auth.UserToken
+	// Failed sign in attempts by key and audit of lockouts
+	loginAttempts      map[string]auth.LoginAttempts
+	loginAttemptsSwept time.Time
+	lockouts           []auth.LoginLockout
+	// Two-factor enrollments and recovery code digests by user id,
+	// roles requiring two-factor authentication
+	mfa           map[int]auth.MFA
+	recoveryCodes map[int]map[string]bool
+	mfaRoles      map[int]bool
+	// Users linked to subjects of identity providers
+	identities map[identity]int
+}
+
+type identity struct {
+	Issuer  string
+	Subject string
+}
+
+func NewStore() *Store {
+	return &Store{
+		roles:        make(map[int]string),
+		edEnvs:       make(map[int]models.EducationalEnv),
+		deps:         make(map[int]models.Department),
+		groups:       make(map[int]models.Group),
+		users:        make(map[int]models.User),
+		courses:      make(map[int]course),
+		groupCourses: make(map[int]models.GroupCourse),
+		locations:    make(map[int]string),
+		infos:        make(map[int]models.NestedInfo),
+		labs:         make(map[int]models.NestedLab),
+		tests:        make(map[int]models.NestedTest),
+		sessions:     make(map[int]session),
+		rotated:      make(map[string]string),
+		userTokens:   make(map[string]auth.UserToken),
+
+		loginAttempts: make(map[string]auth.LoginAttempts),
+		mfa:           make(map[int]auth.MFA),
+		recoveryCodes: make(map[int]map[string]bool),
+		mfaRoles:      make(map[int]bool),
+		identities:    make(map[identity]int),
+	}
+}
+
+// Ids are unique across all tables. Caller must hold the lock.
+func (s *Store) nextId() int {
+	s.lastId++
+	return s.lastId
+}
+
+// Roles, educational environments, departments and locations
+// have no repository methods to create them, so they are seeded here.
+
+func (s *Store) AddRole(id int, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.roles[id] = name
+	if id > s.lastId {
+		s.lastId = id
+	}
+}
+
+func (s *Store) AddEducationalEnv(env models.EducationalEnv) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.edEnvs[env.Id] = env
+	if env.Id > s.lastId {
+		s.lastId = env.Id
+	}
+}
+
+func (s *Store) AddDepartment(dep models.Department) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deps[dep.Id] = dep
+	if dep.Id > s.lastId {
+		s.lastId = dep.Id
+	}
+}
+
+func (s *Store) AddLocation(id int, location string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.locations[id] = location
+	if id > s.lastId {
+		s.lastId = id
+	}
+}
+
+// Adds "exists" violation for field, when referenced row is missing.
+// Fields already rejected by tag rules are not checked.
+func checkReference(ve *e.ValidationError, field, message string, found bool) {
+	if !found && !ve.Has(field) {
+		ve.Add(field, "exists", message)
+	}
+}
@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+
+	"VEEEKTOR_api/internal/auth"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type UserTokenRepository struct {
+	s *Store
+}
+
+func NewUserTokenRepository(s *Store) *UserTokenRepository {
+	return &UserTokenRepository{s: s}
+}
+
+// Previous tokens of user with the same purpose are deleted.
+// Sets token id.
+// Errors: -
+func (r *UserTokenRepository) Replace(ctx context.Context,
+	t *auth.UserToken, digest string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for d, stored := range r.s.userTokens {
+		if stored.UserId == t.UserId && stored.Purpose == t.Purpose {
+			delete(r.s.userTokens, d)
+		}
+	}
+
+	t.Id = r.s.nextId()
+	r.s.userTokens[digest] = *t
+
+	return nil
+}
+
+// Deletes token, so it can be used once.
+// Errors: ErrTokenNotValid
+func (r *UserTokenRepository) Consume(ctx context.Context,
+	purpose, digest string) (auth.UserToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.userTokens[digest]
+	if !ok || t.Purpose != purpose {
+		return auth.UserToken{}, e.ErrTokenNotValid
+	}
+	delete(r.s.userTokens, digest)
+
+	return t, nil
+}
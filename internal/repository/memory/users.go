@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+	"context"
+	"sort"
+	"strings"
+)
+
+type UserRepository struct {
+	s *Store
+}
+
+func NewUserRepository(s *Store) *UserRepository {
+	return &UserRepository{s: s}
+}
+
+// Errors: ErrUserNotFound
+func (r *UserRepository) GetById(ctx context.Context,
+	userId int) (models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	usr, ok := r.s.users[userId]
+	if !ok {
+		return models.User{}, e.ErrUserNotFound
+	}
+
+	return usr, nil
+}
+
+// Errors: ErrUserNotFound
+func (r *UserRepository) GetByEmail(ctx context.Context,
+	email string) (models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, usr := range r.s.users {
+		if usr.Email == email {
+			return usr, nil
+		}
+	}
+
+	return models.User{}, e.ErrUserNotFound
+}
+
+// User linked to subject of identity provider.
+// Errors: ErrUserNotFound
+func (r *UserRepository) GetByIdentity(ctx context.Context,
+	issuer, subject string) (models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	usr, ok := r.s.users[r.s.identities[identity{issuer, subject}]]
+	if !ok {
+		return models.User{}, e.ErrUserNotFound
+	}
+
+	return usr, nil
+}
+
+// Links subject of identity provider to user, existing link is kept.
+// Errors: ErrUserNotFound
+func (r *UserRepository) LinkIdentity(ctx context.Context,
+	userId int, issuer, subject string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[userId]; !ok {
+		return e.ErrUserNotFound
+	}
+	key := identity{issuer, subject}
+	if _, ok := r.s.identities[key]; !ok {
+		r.s.identities[key] = userId
+	}
+
+	return nil
+}
+
+// Collects violations of role, group and department references.
+// Errors: ErrValidationFailed
+func (r *UserRepository) CheckReferences(ctx context.Context,
+	usr *models.User) error {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.checkReferences(usr)
+}
+
+// Caller must hold the lock.
+// Errors: ErrValidationFailed
+func (r *UserRepository) checkReferences(usr *models.User) error {
+	ve := &e.ValidationError{}
+	_, ok := r.s.roles[usr.RoleId]
+	checkReference(ve, "role_id", "role not found", ok)
+	_, ok = r.s.groups[usr.GroupId]
+	checkReference(ve, "group_id", "group not found", ok)
+	_, ok = r.s.deps[usr.DepId]
+	checkReference(ve, "dep_id", "department not found", ok)
+
+	return ve.Err()
+}
+
+// Fields must be validated and password hashed by caller.
+// Errors: ErrValidationFailed, ErrUserExist
+func (r *UserRepository) Insert(ctx context.Context, usr *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.checkReferences(usr); err != nil {
+		return err
+	}
+	if err := r.checkEmail(usr); err != nil {
+		return err
+	}
+
+	usr.Id = r.s.nextId()
+	r.s.users[usr.Id] = *usr
+
+	return nil
+}
+
+// Email must not belong to other user. Caller must hold the lock.
+// Errors: ErrUserExist
+func (r *UserRepository) checkEmail(usr *models.User) error {
+	for _, u := range r.s.users {
+		if u.Email == usr.Email && u.Id != usr.Id {
+			return e.ErrUserExist
+		}
+	}
+
+	return nil
+}
+
+// Password, activity and email verification are not changed.
+// Errors: ErrUserNotFound, ErrValidationFailed, ErrUserExist
+func (r *UserRepository) Update(ctx context.Context, usr *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.checkReferences(usr); err != nil {
+		return err
+	}
+	if err := r.checkEmail(usr); err != nil {
+		return err
+	}
+
+	stored, ok := r.s.users[usr.Id]
+	if !ok {
+		return e.ErrUserNotFound
+	}
+	updated := *usr
+	updated.Password, updated.Active = stored.Password, stored.Active
+	updated.EmailVerified = stored.EmailVerified
+	r.s.users[usr.Id] = updated
+
+	return nil
+}
+
+// Errors: -
+func (r *UserRepository) UpdatePassword(ctx context.Context,
+	userId int, hash string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if usr, ok := r.s.users[userId]; ok {
+		usr.Password = hash
+		r.s.users[userId] = usr
+	}
+
+	return nil
+}
+
+// Errors: ErrUserNotFound
+func (r *UserRepository) SetActive(ctx context.Context,
+	userId int, active bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	usr, ok := r.s.users[userId]
+	if !ok {
+		return e.ErrUserNotFound
+	}
+	usr.Active = active
+	r.s.users[userId] = usr
+
+	return nil
+}
+
+// Errors: ErrUserNotFound
+func (r *UserRepository) SetEmailVerified(ctx context.Context,
+	userId int, verified bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	usr, ok := r.s.users[userId]
+	if !ok {
+		return e.ErrUserNotFound
+	}
+	usr.EmailVerified = verified
+	r.s.users[userId] = usr
+
+	return nil
+}
+
+// Page of users ordered by id and total count of matching users.
+// Passwords are not loaded.
+// Errors: -
+func (r *UserRepository) Search(ctx context.Context,
+	f models.UserFilter) ([]models.User, int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	query := strings.ToLower(f.Query)
+	matches := func(usr models.User) bool {
+		if query != "" && !strings.Contains(strings.ToLower(usr.Email), query) &&
+			!strings.Contains(strings.ToLower(usr.Name), query) &&
+			!strings.Contains(strings.ToLower(usr.Patronymic), query) &&
+			!strings.Contains(strings.ToLower(usr.Surname), query) {
+			return false
+		}
+
+		return (f.RoleId == 0 || usr.RoleId == f.RoleId) &&
+			(f.GroupId == 0 || usr.GroupId == f.GroupId) &&
+			(f.DepId == 0 || usr.DepId == f.DepId) &&
+			(f.Active == nil || usr.Active == *f.Active)
+	}
+
+	var found []models.User
+	for _, usr := range r.s.users {
+		if matches(usr) {
+			usr.Password = ""
+			found = append(found, usr)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Id < found[j].Id
+	})
+
+	users := []models.User{}
+	if f.Offset < len(found) {
+		end := min(f.Offset+f.Limit, len(found))
+		users = append(users, found[f.Offset:end]...)
+	}
+
+	return users, len(found), nil
+}
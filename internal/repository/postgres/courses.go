@@ -0,0 +1,249 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type CourseRepository struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+}
+
+func NewCourseRepository(db *sql.DB) *CourseRepository {
+	return &CourseRepository{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Errors: ErrCourseNotFound
+func (r *CourseRepository) GetById(ctx context.Context,
+	courseId int) (models.Course, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT name, term, teacher_id, markdown, dep_id 
+		FROM courses WHERE id=$1`)
+	if err != nil {
+		return models.Course{}, e.Internal("get course by id", err)
+	}
+
+	var course models.Course
+	course.Id = courseId
+	if err := stmt.QueryRowContext(ctx, &courseId).Scan(
+		&course.Name, &course.Term, &course.TeacherId,
+		&course.Markdown, &course.DepId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return course, e.ErrCourseNotFound
+		}
+		return models.Course{}, e.Internal("get course by id", err)
+	}
+
+	return course, nil
+}
+
+// Errors: ErrCoursesNotFound
+func (r *CourseRepository) GetAllByGroupId(ctx context.Context, groupId int) (
+	[]models.CourseMultipleExportDTO, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT c.id, c.name, c.term, d_c.name, u.name, 
+		u.patronymic, u.surname, d_u.name, c.modified_at 
+		FROM courses AS c 
+		JOIN users AS u ON c.teacher_id=u.id 
+		JOIN departments AS d_u ON d_u.id=u.dep_id 
+		JOIN departments AS d_c ON d_c.id=c.dep_id
+		JOIN group_courses AS gc ON gc.group_id=$1
+		WHERE c.id=gc.course_id`)
+	if err != nil {
+		return nil, e.Internal("get all courses by group id", err)
+	}
+
+	var courses []models.CourseMultipleExportDTO
+	var rows *sql.Rows
+	if rows, err = stmt.QueryContext(ctx, &groupId); err != nil {
+		return nil, e.Internal("get all courses by group id", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.CourseMultipleExportDTO
+		var t time.Time
+		if err := rows.Scan(
+			&c.Id, &c.Name, &c.Term, &c.Dep, &c.Teacher.Name,
+			&c.Teacher.Patronymic, &c.Teacher.Surname,
+			&c.Teacher.Dep, &t); err != nil {
+			return nil, e.Internal("get all courses by group id", err)
+		}
+		c.ModifiedAt = t.Unix()
+		courses = append(courses, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, e.Internal("get all courses by group id", err)
+	}
+
+	if len(courses) == 0 {
+		return courses, e.ErrCoursesNotFound
+	}
+
+	return courses, nil
+}
+
+// Errors: ErrCoursesNotFound
+func (r *CourseRepository) GetAllByTeacherId(ctx context.Context,
+	teacherId int) ([]models.CourseMultipleExportDTO, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT c.id, c.name, c.term, d_c.name, u.name, 
+		u.patronymic, u.surname, d_u.name, c.modified_at 
+		FROM courses AS c 
+		JOIN users AS u ON c.teacher_id=u.id 
+		JOIN departments AS d_u ON d_u.id=u.dep_id 
+		JOIN departments AS d_c ON d_c.id=c.dep_id
+		WHERE c.teacher_id=$1`)
+	if err != nil {
+		return nil, e.Internal("get all courses by teacher id", err)
+	}
+
+	var courses []models.CourseMultipleExportDTO
+	var rows *sql.Rows
+	if rows, err = stmt.QueryContext(ctx, &teacherId); err != nil {
+		return nil, e.Internal("get all courses by teacher id", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.CourseMultipleExportDTO
+		var t time.Time
+		if err := rows.Scan(
+			&c.Id, &c.Name, &c.Term, &c.Dep, &c.Teacher.Name,
+			&c.Teacher.Patronymic, &c.Teacher.Surname,
+			&c.Teacher.Dep, &t); err != nil {
+			return nil, e.Internal("get all courses by teacher id", err)
+		}
+		c.ModifiedAt = t.Unix()
+		courses = append(courses, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, e.Internal("get all courses by teacher id", err)
+	}
+
+	if len(courses) == 0 {
+		return courses, e.ErrCoursesNotFound
+	}
+
+	return courses, nil
+}
+
+// Errors: ErrCourseNotFound, ErrValidationFailed
+func (r *CourseRepository) validate(ctx context.Context,
+	c *models.Course) error {
+	var exists bool
+	if c.Id != 0 {
+		err := r.db.QueryRowContext(ctx,
+			`SELECT 1 FROM courses WHERE id=$1`,
+			&c.Id).Scan(&exists)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return e.ErrCourseNotFound
+			}
+			return e.Internal("validate course", err)
+		}
+	}
+
+	ve := &e.ValidationError{}
+	if err := ve.Merge(c.Validate()); err != nil {
+		return err
+	}
+	if err := checkReference(ctx, r.db, ve, "teacher_id", "teacher not found",
+		`SELECT 1 FROM users WHERE id=$1 AND role_id IN (2, 3)`,
+		&c.TeacherId); err != nil {
+		return e.Internal("validate course", err)
+	}
+	if err := checkReference(ctx, r.db, ve, "dep_id", "department not found",
+		`SELECT 1 FROM departments WHERE id=$1`, &c.DepId); err != nil {
+		return e.Internal("validate course", err)
+	}
+
+	return ve.Err()
+}
+
+// Errors: ErrValidationFailed
+func (r *CourseRepository) Insert(ctx context.Context,
+	c *models.Course) (int, error) {
+	if err := r.validate(ctx, c); err != nil {
+		return 0, err
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`INSERT INTO courses 
+		(name, term, teacher_id, markdown, dep_id) 
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`)
+	if err != nil {
+		return 0, e.Internal("insert course", err)
+	}
+
+	if err = stmt.QueryRowContext(ctx, &c.Name, &c.Term,
+		&c.TeacherId, &c.Markdown,
+		&c.DepId).Scan(&c.Id); err != nil {
+		return 0, e.Internal("insert course", err)
+	}
+
+	return c.Id, nil
+}
+
+// Errors: ErrCourseIdNull, ErrCourseNotFound, ErrValidationFailed
+func (r *CourseRepository) Update(ctx context.Context, c *models.Course) error {
+	if c.Id == 0 {
+		return e.ErrCourseIdNull
+	}
+
+	if err := r.validate(ctx, c); err != nil {
+		return err
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`UPDATE courses SET name=$2, term=$3, 
+		teacher_id=$4, markdown=$5, dep_id=$6, 
+		modified_at=$7 WHERE id=$1`)
+	if err != nil {
+		return e.Internal("update course", err)
+	}
+
+	if _, err = stmt.ExecContext(ctx,
+		&c.Id, &c.Name, &c.Term, &c.TeacherId,
+		&c.Markdown, &c.DepId, time.Now()); err != nil {
+		return e.Internal("update course", err)
+	}
+
+	return nil
+}
+
+// User have: 0 - no access, 1 - read access, 2 - write access
+// Errors: ErrCourseNotFound
+func (r *CourseRepository) CheckAccess(
+	ctx context.Context, courseId, userId, groupId int) (int, error) {
+	var teacherId int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT teacher_id FROM courses WHERE id=$1`,
+		&courseId).Scan(&teacherId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, e.ErrCourseNotFound
+	} else if err != nil {
+		return 0, e.Internal("check course access", err)
+	}
+
+	if teacherId == userId {
+		return 2, nil
+	}
+
+	var exists int
+	err = r.db.QueryRowContext(ctx,
+		`SELECT 1 FROM group_courses WHERE group_id=$1 and course_id=$2`,
+		&groupId, &courseId).Scan(&exists)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, e.Internal("check course access", err)
+	}
+
+	return exists, nil
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type DepartmentRepository struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+}
+
+func NewDepartmentRepository(db *sql.DB) *DepartmentRepository {
+	return &DepartmentRepository{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Errors: ErrDepsNotFound
+func (r *DepartmentRepository) GetAll(ctx context.Context) (
+	[]models.Department, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, name, env_id FROM departments`)
+	if err != nil {
+		return nil, e.Internal("get all departments", err)
+	}
+
+	var deps []models.Department
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, e.Internal("get all departments", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dep models.Department
+		err = rows.Scan(&dep.Id, &dep.Name, &dep.EnvId)
+		if err != nil {
+			return nil, e.Internal("get all departments", err)
+		}
+		deps = append(deps, dep)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, e.Internal("get all departments", err)
+	}
+
+	if len(deps) == 0 {
+		return deps, e.ErrDepsNotFound
+	}
+
+	return deps, nil
+}
+
+// Errors: ErrDepNotFound
+func (r *DepartmentRepository) GetById(ctx context.Context,
+	depId int) (models.Department, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, name, env_id FROM departments WHERE id=$1`)
+	if err != nil {
+		return models.Department{}, e.Internal("get department by id", err)
+	}
+
+	var dep models.Department
+	err = stmt.QueryRowContext(ctx, &depId).Scan(&dep.Id, &dep.Name, &dep.EnvId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dep, e.ErrDepNotFound
+		}
+		return models.Department{}, e.Internal("get department by id", err)
+	}
+
+	return dep, nil
+}
+
+// Errors: ErrDepsNotFound
+func (r *DepartmentRepository) GetAllByEnvId(ctx context.Context, envId int) (
+	[]models.Department, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, name, env_id FROM departments WHERE env_id=$1`)
+	if err != nil {
+		return nil, e.Internal("get all departments by environment id", err)
+	}
+
+	var deps []models.Department
+	rows, err := stmt.QueryContext(ctx, &envId)
+	if err != nil {
+		return nil, e.Internal("get all departments by environment id", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dep models.Department
+		err = rows.Scan(&dep.Id, &dep.Name, &dep.EnvId)
+		if err != nil {
+			return nil, e.Internal(
+				"get all departments by environment id", err)
+		}
+		deps = append(deps, dep)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, e.Internal("get all departments by environment id", err)
+	}
+
+	if len(deps) == 0 {
+		return deps, e.ErrDepsNotFound
+	}
+
+	return deps, nil
+}
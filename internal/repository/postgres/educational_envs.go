@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type EducationalEnvRepository struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+}
+
+func NewEducationalEnvRepository(db *sql.DB) *EducationalEnvRepository {
+	return &EducationalEnvRepository{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Errors: ErrEdEnvsNotFound
+func (r *EducationalEnvRepository) GetAll(ctx context.Context) (
+	[]models.EducationalEnv, error) {
+	// First educational environment supposed to be for admins
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, name from educational_envs WHERE id!=1`)
+	if err != nil {
+		return nil, e.Internal("get all educational envs", err)
+	}
+
+	var envs []models.EducationalEnv
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, e.Internal("get all educational envs", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var env models.EducationalEnv
+		err = rows.Scan(&env.Id, &env.Name)
+		if err != nil {
+			return nil, e.Internal("get all educational envs", err)
+		}
+		envs = append(envs, env)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, e.Internal("get all educational envs", err)
+	}
+
+	if len(envs) == 0 {
+		return envs, e.ErrEdEnvsNotFound
+	}
+
+	return envs, nil
+}
+
+// Errors: ErrEdEnvNotFound
+func (r *EducationalEnvRepository) GetById(ctx context.Context, envId int) (
+	models.EducationalEnv, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, name FROM educational_envs WHERE id=$1`)
+	if err != nil {
+		return models.EducationalEnv{}, e.Internal(
+			"get educational environment by id", err)
+	}
+
+	var env models.EducationalEnv
+	err = stmt.QueryRowContext(ctx, &envId).Scan(&env.Id, &env.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return env, e.ErrEdEnvNotFound
+		}
+		return models.EducationalEnv{}, e.Internal(
+			"get educational environment by id", err)
+	}
+
+	return env, nil
+}
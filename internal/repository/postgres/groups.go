@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type GroupRepository struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+}
+
+func NewGroupRepository(db *sql.DB) *GroupRepository {
+	return &GroupRepository{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Errors: ErrGroupNotFound
+func (r *GroupRepository) GetById(ctx context.Context,
+	groupId int) (models.Group, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, name, dep_id FROM groups WHERE id=$1`)
+	if err != nil {
+		return models.Group{}, e.Internal("get group by id", err)
+	}
+
+	var g models.Group
+	if err = stmt.QueryRowContext(ctx, &groupId).Scan(
+		&g.Id, &g.Name, &g.DepId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return g, e.ErrGroupNotFound
+		}
+		return models.Group{}, e.Internal("get group by id", err)
+	}
+
+	return g, nil
+}
+
+// Errors: ErrGroupsNotFound
+func (r *GroupRepository) GetAllByDepId(ctx context.Context,
+	depId int) ([]models.Group, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, name, dep_id FROM groups WHERE dep_id=$1`)
+	if err != nil {
+		return nil, e.Internal("get all groups by dep id", err)
+	}
+
+	var rows *sql.Rows
+	if rows, err = stmt.QueryContext(ctx, &depId); err != nil {
+		return nil, e.Internal("get all groups by dep id", err)
+	}
+	defer rows.Close()
+
+	var groups []models.Group
+	for rows.Next() {
+		var g models.Group
+		if err = rows.Scan(&g.Id, &g.Name, &g.DepId); err != nil {
+			return nil, e.Internal("get all groups by dep id", err)
+		}
+		groups = append(groups, g)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, e.Internal("get all groups by dep id", err)
+	}
+
+	if len(groups) == 0 {
+		return groups, e.ErrGroupsNotFound
+	}
+
+	return groups, nil
+}
+
+// Errors: ErrValidationFailed
+func (r *GroupRepository) Insert(ctx context.Context, g *models.Group) error {
+	ve := &e.ValidationError{}
+	if err := ve.Merge(g.Validate()); err != nil {
+		return err
+	}
+	if err := checkReference(ctx, r.db, ve, "dep_id", "department not found",
+		`SELECT 1 FROM departments WHERE id=$1`, &g.DepId); err != nil {
+		return e.Internal("insert group", err)
+	}
+	if err := ve.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`INSERT INTO groups(name, dep_id) VALUES ($1, $2) RETURNING id`)
+	if err != nil {
+		return e.Internal("insert group", err)
+	}
+
+	if err = stmt.QueryRowContext(ctx, &g.Name, &g.DepId).Scan(&g.Id); err != nil {
+		return e.Internal("insert group", err)
+	}
+
+	return nil
+}
+
+// Errors: -
+func (r *GroupRepository) DeleteById(ctx context.Context, groupId int) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM groups WHERE id=$1`)
+	if err != nil {
+		return e.Internal("delete group by id", err)
+	}
+
+	if _, err = stmt.ExecContext(ctx, &groupId); err != nil {
+		return e.Internal("delete group by id", err)
+	}
+
+	return nil
+}
+
+// Errors: ErrGroupLinkedToCourse, ErrValidationFailed
+func (r *GroupRepository) LinkCourse(ctx context.Context,
+	gc *models.GroupCourse) error {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT 1 from group_courses WHERE 
+		group_id=$1 AND course_id=$2`,
+		&gc.GroupId, &gc.CourseId).Scan(&exists)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return e.Internal("link course to group", err)
+	}
+	if exists {
+		return e.ErrGroupLinkedToCourse
+	}
+
+	ve := &e.ValidationError{}
+	if err = ve.Merge(gc.Validate()); err != nil {
+		return err
+	}
+	if err = checkReference(ctx, r.db, ve, "group_id", "group not found",
+		`SELECT 1 FROM groups WHERE id=$1`, &gc.GroupId); err != nil {
+		return e.Internal("link course to group", err)
+	}
+	if err = checkReference(ctx, r.db, ve, "course_id", "course not found",
+		`SELECT 1 FROM courses WHERE id=$1`, &gc.CourseId); err != nil {
+		return e.Internal("link course to group", err)
+	}
+	if err = ve.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`INSERT INTO group_courses(group_id, course_id)
+		VALUES ($1, $2) RETURNING id`)
+	if err != nil {
+		return e.Internal("link course to group", err)
+	}
+
+	if err := stmt.QueryRowContext(ctx,
+		&gc.GroupId, &gc.CourseId).Scan(&gc.Id); err != nil {
+		return e.Internal("link course to group", err)
+	}
+
+	return nil
+}
+
+// Errors: ErrGroupNotLinkedToCourse
+func (r *GroupRepository) UnlinkCourse(ctx context.Context,
+	gc *models.GroupCourse) error {
+	var id int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id from group_courses WHERE 
+		group_id=$1 AND course_id=$2`,
+		&gc.GroupId, &gc.CourseId).Scan(&id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return e.Internal("unlink course from group", err)
+	}
+	if id == 0 {
+		return e.ErrGroupNotLinkedToCourse
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM group_courses WHERE id=$1`)
+	if err != nil {
+		return e.Internal("unlink course from group", err)
+	}
+
+	if _, err = stmt.ExecContext(ctx, &id); err != nil {
+		return e.Internal("unlink course from group", err)
+	}
+
+	return nil
+}
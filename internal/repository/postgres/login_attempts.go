@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+// Expired attempts are deleted not more often than this.
+const loginAttemptsPruneInterval = 10 * time.Minute
+
+type LoginAttemptRepository struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+func NewLoginAttemptRepository(db *sql.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Row of key is locked until change returns, so replicas
+// don't lose attempts.
+// Errors: -
+func (r *LoginAttemptRepository) Update(ctx context.Context,
+	key string, change auth.LoginAttemptsChange) (auth.LoginAttempts, error) {
+	r.prune(ctx)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return auth.LoginAttempts{}, e.Internal("update login attempts", err)
+	}
+	defer tx.Rollback()
+
+	// Missing row is created empty, concurrent insert waits
+	// for the first one
+	if _, err = tx.ExecContext(ctx,
+		`INSERT INTO login_attempts (key) VALUES ($1)
+		ON CONFLICT (key) DO NOTHING`, &key); err != nil {
+		return auth.LoginAttempts{}, e.Internal("update login attempts", err)
+	}
+
+	var attempts auth.LoginAttempts
+	var blockedUntil sql.NullTime
+	if err = tx.QueryRowContext(ctx,
+		`SELECT failures, last_failure_at, blocked_until
+		FROM login_attempts WHERE key=$1 FOR UPDATE`, &key).Scan(
+		&attempts.Failures, &attempts.LastFailureAt,
+		&blockedUntil); err != nil {
+		return auth.LoginAttempts{}, e.Internal("update login attempts", err)
+	}
+	attempts.BlockedUntil = blockedUntil.Time
+
+	attempts = change(attempts)
+	blockedUntil = sql.NullTime{
+		Time: attempts.BlockedUntil, Valid: !attempts.BlockedUntil.IsZero()}
+	if _, err = tx.ExecContext(ctx,
+		`UPDATE login_attempts SET failures=$2, last_failure_at=$3,
+		blocked_until=$4 WHERE key=$1`,
+		&key, &attempts.Failures, &attempts.LastFailureAt,
+		&blockedUntil); err != nil {
+		return auth.LoginAttempts{}, e.Internal("update login attempts", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return auth.LoginAttempts{}, e.Internal("update login attempts", err)
+	}
+
+	return attempts, nil
+}
+
+// Deletes expired attempts, failures are logged only.
+func (r *LoginAttemptRepository) prune(ctx context.Context) {
+	r.mu.Lock()
+	if time.Since(r.lastPrune) < loginAttemptsPruneInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastPrune = time.Now()
+	r.mu.Unlock()
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM login_attempts WHERE last_failure_at < $1
+		AND (blocked_until IS NULL OR blocked_until <= now())`)
+	if err == nil {
+		_, err = stmt.ExecContext(ctx,
+			time.Now().Add(-auth.LoginAttemptsRetention))
+	}
+	if err != nil {
+		log.Printf("unable to prune login attempts: %v", err)
+	}
+}
+
+// Errors: -
+func (r *LoginAttemptRepository) Delete(ctx context.Context,
+	key string) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM login_attempts WHERE key=$1`)
+	if err != nil {
+		return e.Internal("delete login attempts", err)
+	}
+
+	if _, err = stmt.ExecContext(ctx, &key); err != nil {
+		return e.Internal("delete login attempts", err)
+	}
+
+	return nil
+}
+
+// Sets lockout id and creation time.
+// Errors: -
+func (r *LoginAttemptRepository) InsertLockout(ctx context.Context,
+	l *auth.LoginLockout) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`INSERT INTO login_lockouts (key, email, ip, failures, locked_until) 
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`)
+	if err != nil {
+		return e.Internal("insert login lockout", err)
+	}
+
+	if err = stmt.QueryRowContext(ctx,
+		&l.Key, &l.Email, &l.Ip, &l.Failures, &l.LockedUntil).Scan(
+		&l.Id, &l.CreatedAt); err != nil {
+		return e.Internal("insert login lockout", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type MFARepository struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+}
+
+func NewMFARepository(db *sql.DB) *MFARepository {
+	return &MFARepository{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Errors: ErrMFANotEnrolled
+func (r *MFARepository) Get(ctx context.Context,
+	userId int) (auth.MFA, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT user_id, secret, enabled, last_step
+		FROM user_mfa WHERE user_id=$1`)
+	if err != nil {
+		return auth.MFA{}, e.Internal("get mfa", err)
+	}
+
+	var mfa auth.MFA
+	if err = stmt.QueryRowContext(ctx, &userId).Scan(
+		&mfa.UserId, &mfa.Secret, &mfa.Enabled, &mfa.LastStep); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return auth.MFA{}, e.ErrMFANotEnrolled
+		}
+		return auth.MFA{}, e.Internal("get mfa", err)
+	}
+
+	return mfa, nil
+}
+
+// Replaces not confirmed enrollment.
+// Errors: -
+func (r *MFARepository) Begin(ctx context.Context,
+	userId int, secret string) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`INSERT INTO user_mfa (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET
+		secret=EXCLUDED.secret, last_step=0, created_at=now()
+		WHERE user_mfa.enabled=false`)
+	if err != nil {
+		return e.Internal("begin mfa", err)
+	}
+
+	if _, err = stmt.ExecContext(ctx, &userId, &secret); err != nil {
+		return e.Internal("begin mfa", err)
+	}
+
+	return nil
+}
+
+// Enables enrollment and replaces recovery codes.
+// Errors: ErrMFANotEnrolled
+func (r *MFARepository) Enable(ctx context.Context,
+	userId int, step int64, codeDigests []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Internal("enable mfa", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE user_mfa SET enabled=true, last_step=$2 WHERE user_id=$1`,
+		&userId, &step)
+	if err != nil {
+		return e.Internal("enable mfa", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return e.ErrMFANotEnrolled
+	}
+
+	if _, err = tx.ExecContext(ctx,
+		`DELETE FROM mfa_recovery_codes WHERE user_id=$1`,
+		&userId); err != nil {
+		return e.Internal("enable mfa", err)
+	}
+	for _, digest := range codeDigests {
+		if _, err = tx.ExecContext(ctx,
+			`INSERT INTO mfa_recovery_codes (user_id, digest)
+			VALUES ($1, $2)`, &userId, &digest); err != nil {
+			return e.Internal("enable mfa", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return e.Internal("enable mfa", err)
+	}
+
+	return nil
+}
+
+// Remembers step of accepted code, false when step is not
+// newer than remembered one.
+// Errors: -
+func (r *MFARepository) UseStep(ctx context.Context,
+	userId int, step int64) (bool, error) {
+	// Single statement, so concurrent sign ins can't use one code
+	stmt, err := r.stmts.Prepare(ctx,
+		`UPDATE user_mfa SET last_step=$2
+		WHERE user_id=$1 AND last_step < $2`)
+	if err != nil {
+		return false, e.Internal("use mfa step", err)
+	}
+
+	res, err := stmt.ExecContext(ctx, &userId, &step)
+	if err != nil {
+		return false, e.Internal("use mfa step", err)
+	}
+	n, _ := res.RowsAffected()
+
+	return n > 0, nil
+}
+
+// Deletes code, so it can be used once.
+// Errors: ErrMFACodeNotValid
+func (r *MFARepository) UseRecoveryCode(ctx context.Context,
+	userId int, digest string) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM mfa_recovery_codes WHERE user_id=$1 AND digest=$2`)
+	if err != nil {
+		return e.Internal("use recovery code", err)
+	}
+
+	res, err := stmt.ExecContext(ctx, &userId, &digest)
+	if err != nil {
+		return e.Internal("use recovery code", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return e.ErrMFACodeNotValid
+	}
+
+	return nil
+}
+
+// Deletes enrollment with recovery codes.
+// Errors: -
+func (r *MFARepository) Delete(ctx context.Context, userId int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Internal("delete mfa", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx,
+		`DELETE FROM mfa_recovery_codes WHERE user_id=$1`,
+		&userId); err != nil {
+		return e.Internal("delete mfa", err)
+	}
+	if _, err = tx.ExecContext(ctx,
+		`DELETE FROM user_mfa WHERE user_id=$1`, &userId); err != nil {
+		return e.Internal("delete mfa", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return e.Internal("delete mfa", err)
+	}
+
+	return nil
+}
+
+// Errors: -
+func (r *MFARepository) RoleRequired(ctx context.Context,
+	roleId int) (bool, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT mfa_required FROM roles WHERE id=$1`)
+	if err != nil {
+		return false, e.Internal("get role mfa", err)
+	}
+
+	var required bool
+	if err = stmt.QueryRowContext(ctx, &roleId).Scan(
+		&required); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, e.Internal("get role mfa", err)
+	}
+
+	return required, nil
+}
+
+// Errors: ErrRoleNotFound
+func (r *MFARepository) SetRoleRequired(ctx context.Context,
+	roleId int, required bool) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`UPDATE roles SET mfa_required=$2 WHERE id=$1`)
+	if err != nil {
+		return e.Internal("set role mfa", err)
+	}
+
+	res, err := stmt.ExecContext(ctx, &roleId, &required)
+	if err != nil {
+		return e.Internal("set role mfa", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return e.ErrRoleNotFound
+	}
+
+	return nil
+}
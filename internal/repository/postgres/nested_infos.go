@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type NestedInfoRepository struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+}
+
+func NewNestedInfoRepository(db *sql.DB) *NestedInfoRepository {
+	return &NestedInfoRepository{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Errors: ErrNestedInfoNotFound
+func (r *NestedInfoRepository) GetById(ctx context.Context,
+	infoId int) (models.NestedInfo, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, course_id, name, markdown 
+		FROM nested_infos WHERE id=$1`)
+	if err != nil {
+		return models.NestedInfo{}, e.Internal("get nested info by id", err)
+	}
+
+	var info models.NestedInfo
+	if err = stmt.QueryRowContext(ctx, &infoId).Scan(&info.Id,
+		&info.CourseId, &info.Name, &info.Markdown); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return info, e.ErrNestedInfoNotFound
+		}
+		return models.NestedInfo{}, e.Internal("get nested info by id", err)
+	}
+
+	return info, nil
+}
+
+// Errors: ErrNestedInfosNotFound
+func (r *NestedInfoRepository) GetAllByCourseId(ctx context.Context,
+	courseId int) ([]models.NestedInfo, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, course_id, name
+		FROM nested_infos WHERE course_id=$1`)
+	if err != nil {
+		return nil, e.Internal("get nested infos by course id", err)
+	}
+
+	var infos []models.NestedInfo
+	var rows *sql.Rows
+	if rows, err = stmt.QueryContext(ctx, &courseId); err != nil {
+		return nil, e.Internal("get nested infos by course id", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var info models.NestedInfo
+		if err = rows.Scan(
+			&info.Id, &info.CourseId, &info.Name); err != nil {
+			return nil, e.Internal("get nested infos by course id", err)
+		}
+		infos = append(infos, info)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, e.Internal("get nested infos by course id", err)
+	}
+
+	if len(infos) == 0 {
+		return infos, e.ErrNestedInfosNotFound
+	}
+
+	return infos, nil
+}
+
+// Errors: ErrNestedInfoNotFound, ErrValidationFailed
+func (r *NestedInfoRepository) validate(ctx context.Context,
+	info *models.NestedInfo) error {
+	var exists bool
+	if info.Id != 0 {
+		err := r.db.QueryRowContext(ctx,
+			`SELECT 1 FROM nested_infos WHERE id=$1`,
+			&info.Id).Scan(&exists)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return e.ErrNestedInfoNotFound
+			}
+			return e.Internal("validate nested info", err)
+		}
+	}
+
+	ve := &e.ValidationError{}
+	if err := ve.Merge(info.Validate()); err != nil {
+		return err
+	}
+	if err := checkReference(ctx, r.db, ve, "course_id", "course not found",
+		`SELECT 1 FROM courses WHERE id=$1`, &info.CourseId); err != nil {
+		return e.Internal("validate nested info", err)
+	}
+
+	return ve.Err()
+}
+
+// Errors: ErrValidationFailed
+func (r *NestedInfoRepository) Insert(ctx context.Context,
+	info *models.NestedInfo) error {
+	if err := r.validate(ctx, info); err != nil {
+		return err
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`INSERT INTO nested_infos(course_id, name, markdown)
+		VALUES ($1, $2, $3) RETURNING id`)
+	if err != nil {
+		return e.Internal("insert nested info", err)
+	}
+
+	if err := stmt.QueryRowContext(ctx, &info.CourseId, &info.Name,
+		&info.Markdown).Scan(&info.Id); err != nil {
+		return e.Internal("insert nested info", err)
+	}
+
+	return nil
+}
+
+// Errors: ErrMissingFields, ErrNestedInfoNotFound, ErrValidationFailed
+func (r *NestedInfoRepository) Update(ctx context.Context,
+	info *models.NestedInfo) error {
+	if info.Id == 0 {
+		return e.ErrMissingFields
+	}
+
+	if err := r.validate(ctx, info); err != nil {
+		return err
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`UPDATE nested_infos 
+		SET course_id=$2, name=$3, markdown=$4
+		WHERE id=$1`)
+	if err != nil {
+		return e.Internal("update nested info", err)
+	}
+
+	if _, err := stmt.ExecContext(ctx,
+		&info.Id, &info.CourseId, &info.Name, &info.Markdown); err != nil {
+		return e.Internal("update nested info", err)
+	}
+
+	return nil
+}
+
+// Errors: -
+func (r *NestedInfoRepository) DeleteById(ctx context.Context,
+	infoId int) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM nested_infos WHERE id=$1`)
+	if err != nil {
+		return e.Internal("delete nested info by id", err)
+	}
+
+	if _, err = stmt.ExecContext(ctx, &infoId); err != nil {
+		return e.Internal("delete nested info by id", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type NestedLabRepository struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+}
+
+func NewNestedLabRepository(db *sql.DB) *NestedLabRepository {
+	return &NestedLabRepository{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Errors: ErrNestedLabNotFound
+func (r *NestedLabRepository) GetById(ctx context.Context,
+	labId int) (models.NestedLab, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, course_id, opens, closes, topic, 
+		requirements, example, location_id, attempts 
+		FROM nested_labs WHERE id=$1`)
+	if err != nil {
+		return models.NestedLab{}, e.Internal("get nested lab by id", err)
+	}
+
+	var lab models.NestedLab
+	if err = stmt.QueryRowContext(ctx, &labId).Scan(
+		&lab.Id, &lab.CourseId, &lab.Opens,
+		&lab.Closes, &lab.Topic, &lab.Requirements,
+		&lab.Example, &lab.LocationId, &lab.Attempts); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return lab, e.ErrNestedLabNotFound
+		}
+		return models.NestedLab{}, e.Internal("get nested lab by id", err)
+	}
+
+	return lab, nil
+}
+
+// Errors: ErrNestedLabsNotFound
+func (r *NestedLabRepository) GetAllByCourseId(ctx context.Context,
+	courseId int) ([]models.NestedLab, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, course_id, opens, closes, topic 
+		FROM nested_labs WHERE course_id=$1`)
+	if err != nil {
+		return nil, e.Internal("get nested labs by course id", err)
+	}
+
+	var labs []models.NestedLab
+	var rows *sql.Rows
+	if rows, err = stmt.QueryContext(ctx, &courseId); err != nil {
+		return nil, e.Internal("get nested labs by course id", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var lab models.NestedLab
+		if err = rows.Scan(
+			&lab.Id, &lab.CourseId, &lab.Opens,
+			&lab.Closes, &lab.Topic); err != nil {
+			return nil, e.Internal("get nested labs by course id", err)
+		}
+		labs = append(labs, lab)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, e.Internal("get nested labs by course id", err)
+	}
+
+	if len(labs) == 0 {
+		return labs, e.ErrNestedLabsNotFound
+	}
+
+	return labs, nil
+}
+
+// Errors: ErrNestedLabNotFound, ErrValidationFailed
+func (r *NestedLabRepository) validate(ctx context.Context,
+	lab *models.NestedLab) error {
+	var exists bool
+	if lab.Id != 0 {
+		err := r.db.QueryRowContext(ctx,
+			`SELECT 1 FROM nested_labs WHERE id=$1`,
+			&lab.Id).Scan(&exists)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return e.ErrNestedLabNotFound
+			}
+			return e.Internal("validate nested lab", err)
+		}
+	}
+
+	ve := &e.ValidationError{}
+	if err := ve.Merge(lab.Validate()); err != nil {
+		return err
+	}
+	if err := checkReference(ctx, r.db, ve, "course_id", "course not found",
+		`SELECT 1 FROM courses WHERE id=$1`, &lab.CourseId); err != nil {
+		return e.Internal("validate nested lab", err)
+	}
+	if err := checkReference(ctx, r.db, ve, "location_id", "location not found",
+		`SELECT 1 FROM locations WHERE id=$1`, &lab.LocationId); err != nil {
+		return e.Internal("validate nested lab", err)
+	}
+
+	return ve.Err()
+}
+
+// Errors: ErrValidationFailed
+func (r *NestedLabRepository) Insert(ctx context.Context,
+	lab *models.NestedLab) error {
+	if err := r.validate(ctx, lab); err != nil {
+		return err
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`INSERT INTO nested_labs(
+		course_id, opens, closes, 
+		topic, requirements, example, 
+		location_id, attempts) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`)
+	if err != nil {
+		return e.Internal("insert nested lab", err)
+	}
+
+	err = stmt.QueryRowContext(ctx, &lab.CourseId, &lab.Opens, &lab.Closes,
+		&lab.Topic, &lab.Requirements, &lab.Example,
+		&lab.LocationId, &lab.Attempts).Scan(&lab.Id)
+	if err != nil {
+		return e.Internal("insert nested lab", err)
+	}
+
+	return nil
+}
+
+// Errors: ErrMissingFields, ErrNestedLabNotFound, ErrValidationFailed
+func (r *NestedLabRepository) Update(ctx context.Context,
+	lab *models.NestedLab) error {
+	if lab.Id == 0 {
+		return e.ErrMissingFields
+	}
+
+	if err := r.validate(ctx, lab); err != nil {
+		return err
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`UPDATE nested_labs SET 
+		course_id=$2, opens=$3, closes=$4, 
+		topic=$5, requirements=$6, example=$7, 
+		location_id=$8, attempts=$9
+		WHERE id=$1`)
+	if err != nil {
+		return e.Internal("update nested lab", err)
+	}
+
+	_, err = stmt.ExecContext(ctx,
+		&lab.Id, &lab.CourseId, &lab.Opens,
+		&lab.Closes, &lab.Topic, &lab.Requirements,
+		&lab.Example, &lab.LocationId, &lab.Attempts)
+	if err != nil {
+		return e.Internal("update nested lab", err)
+	}
+
+	return nil
+}
+
+// Errors: -
+func (r *NestedLabRepository) DeleteById(ctx context.Context, labId int) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM nested_labs WHERE id=$1`)
+	if err != nil {
+		return e.Internal("delete nested lab by id", err)
+	}
+
+	_, err = stmt.ExecContext(ctx, &labId)
+	if err != nil {
+		return e.Internal("delete nested lab by id", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type NestedTestRepository struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+}
+
+func NewNestedTestRepository(db *sql.DB) *NestedTestRepository {
+	return &NestedTestRepository{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Errors: ErrNestedTestNotFound
+func (r *NestedTestRepository) GetById(ctx context.Context,
+	testId int) (models.NestedTest, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, course_id, opens, closes, 
+		tasks_count, topic, location_id, 
+		attempts, password, time_limit 
+		FROM nested_tests WHERE id=$1`)
+	if err != nil {
+		return models.NestedTest{}, e.Internal("get nested test by id", err)
+	}
+
+	var test models.NestedTest
+	if err = stmt.QueryRowContext(ctx, &testId).Scan(
+		&test.Id, &test.CourseId, &test.Opens,
+		&test.Closes, &test.TasksCount, &test.Topic,
+		&test.LocationId, &test.Attempts, &test.Password,
+		&test.TimeLimit); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return test, e.ErrNestedTestNotFound
+		}
+		return models.NestedTest{}, e.Internal("get nested test by id", err)
+	}
+
+	return test, nil
+}
+
+// Errors: ErrNestedTestsNotFound
+func (r *NestedTestRepository) GetAllByCourseId(ctx context.Context,
+	courseId int) ([]models.NestedTest, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, course_id, opens, closes, topic 
+		FROM nested_tests WHERE course_id=$1`)
+	if err != nil {
+		return nil, e.Internal("get nested tests by course id", err)
+	}
+
+	var tests []models.NestedTest
+	var rows *sql.Rows
+	if rows, err = stmt.QueryContext(ctx, &courseId); err != nil {
+		return nil, e.Internal("get nested tests by course id", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var test models.NestedTest
+		if err = rows.Scan(
+			&test.Id, &test.CourseId, &test.Opens,
+			&test.Closes, &test.Topic); err != nil {
+			return nil, e.Internal("get nested tests by course id", err)
+		}
+		tests = append(tests, test)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, e.Internal("get nested tests by course id", err)
+	}
+
+	if len(tests) == 0 {
+		return tests, e.ErrNestedTestsNotFound
+	}
+
+	return tests, nil
+}
+
+// Errors: ErrNestedTestNotFound, ErrValidationFailed
+func (r *NestedTestRepository) validate(ctx context.Context,
+	test *models.NestedTest) error {
+	var exists bool
+	if test.Id != 0 {
+		err := r.db.QueryRowContext(ctx,
+			`SELECT 1 FROM nested_tests WHERE id=$1`,
+			&test.Id).Scan(&exists)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return e.ErrNestedTestNotFound
+			}
+			return e.Internal("validate nested test", err)
+		}
+	}
+
+	ve := &e.ValidationError{}
+	if err := ve.Merge(test.Validate()); err != nil {
+		return err
+	}
+	if err := checkReference(ctx, r.db, ve, "course_id", "course not found",
+		`SELECT 1 FROM courses WHERE id=$1`, &test.CourseId); err != nil {
+		return e.Internal("validate nested test", err)
+	}
+	if err := checkReference(ctx, r.db, ve, "location_id", "location not found",
+		`SELECT 1 FROM locations WHERE id=$1`, &test.LocationId); err != nil {
+		return e.Internal("validate nested test", err)
+	}
+
+	return ve.Err()
+}
+
+// Errors: ErrValidationFailed
+func (r *NestedTestRepository) Insert(ctx context.Context,
+	test *models.NestedTest) error {
+	if err := r.validate(ctx, test); err != nil {
+		return err
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`INSERT INTO nested_tests(
+		course_id, opens, closes, 
+		tasks_count, topic, location_id, 
+		attempts, password, time_limit) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`)
+	if err != nil {
+		return e.Internal("insert nested test", err)
+	}
+
+	err = stmt.QueryRowContext(ctx,
+		&test.CourseId, &test.Opens, &test.Closes,
+		&test.TasksCount, &test.Topic, &test.LocationId,
+		&test.Attempts, &test.Password, &test.TimeLimit).Scan(&test.Id)
+	if err != nil {
+		return e.Internal("insert nested test", err)
+	}
+
+	return nil
+}
+
+// Errors: ErrMissingFields, ErrNestedTestNotFound, ErrValidationFailed
+func (r *NestedTestRepository) Update(ctx context.Context,
+	test *models.NestedTest) error {
+	if test.Id == 0 {
+		return e.ErrMissingFields
+	}
+
+	if err := r.validate(ctx, test); err != nil {
+		return err
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`UPDATE nested_tests SET 
+		course_id=$2, opens=$3, closes=$4, 
+		tasks_count=$5, topic=$6, location_id=$7, 
+		attempts=$8, password=$9, time_limit=$10
+		WHERE id=$1`)
+	if err != nil {
+		return e.Internal("update nested test", err)
+	}
+
+	_, err = stmt.ExecContext(ctx,
+		&test.Id, &test.CourseId, &test.Opens,
+		&test.Closes, &test.TasksCount, &test.Topic,
+		&test.LocationId, &test.Attempts, &test.Password,
+		&test.TimeLimit)
+	if err != nil {
+		return e.Internal("update nested test", err)
+	}
+
+	return nil
+}
+
+// Errors: -
+func (r *NestedTestRepository) DeleteById(ctx context.Context,
+	testId int) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM nested_tests WHERE id=$1`)
+	if err != nil {
+		return e.Internal("delete nested test by id", err)
+	}
+
+	_, err = stmt.ExecContext(ctx, &testId)
+	if err != nil {
+		return e.Internal("delete nested test by id", err)
+	}
+
+	return nil
+}
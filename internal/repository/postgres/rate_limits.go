@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+	"VEEEKTOR_api/pkg/ratelimit"
+)
+
+// Full buckets are deleted not more often than this.
+const rateLimitsPruneInterval = 10 * time.Minute
+
+type RateLimitStore struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+func NewRateLimitStore(db *sql.DB) *RateLimitStore {
+	return &RateLimitStore{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Row of key is locked until token is taken, so replicas
+// don't lose tokens.
+// Errors: -
+func (r *RateLimitStore) Take(ctx context.Context,
+	key string, p ratelimit.Policy) (ratelimit.Result, error) {
+	r.prune(ctx)
+
+	now := time.Now()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ratelimit.Result{}, e.Internal("take rate limit token", err)
+	}
+	defer tx.Rollback()
+
+	// Missing bucket is created full, concurrent insert waits
+	// for the first one
+	if _, err = tx.ExecContext(ctx,
+		`INSERT INTO rate_limits (key, tokens, updated_at, full_at)
+		VALUES ($1, $2, $3, $3) ON CONFLICT (key) DO NOTHING`,
+		&key, p.Limit, &now); err != nil {
+		return ratelimit.Result{}, e.Internal("take rate limit token", err)
+	}
+
+	var b ratelimit.Bucket
+	if err = tx.QueryRowContext(ctx,
+		`SELECT tokens, updated_at FROM rate_limits WHERE key=$1 FOR UPDATE`,
+		&key).Scan(&b.Tokens, &b.UpdatedAt); err != nil {
+		return ratelimit.Result{}, e.Internal("take rate limit token", err)
+	}
+
+	b, res := p.Take(b, now)
+	if _, err = tx.ExecContext(ctx,
+		`UPDATE rate_limits SET tokens=$2, updated_at=$3, full_at=$4
+		WHERE key=$1`,
+		&key, &b.Tokens, &b.UpdatedAt, now.Add(res.Reset)); err != nil {
+		return ratelimit.Result{}, e.Internal("take rate limit token", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return ratelimit.Result{}, e.Internal("take rate limit token", err)
+	}
+
+	return res, nil
+}
+
+// Deletes full buckets, failures are logged only.
+func (r *RateLimitStore) prune(ctx context.Context) {
+	r.mu.Lock()
+	if time.Since(r.lastPrune) < rateLimitsPruneInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastPrune = time.Now()
+	r.mu.Unlock()
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM rate_limits WHERE full_at < now()`)
+	if err == nil {
+		_, err = stmt.ExecContext(ctx)
+	}
+	if err != nil {
+		log.Printf("unable to prune rate limits: %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+// Adds "exists" violation for field, when query selects no rows.
+// Fields already rejected by tag rules are not checked.
+// Errors: -
+func checkReference(ctx context.Context, db *sql.DB, ve *e.ValidationError,
+	field, message, query string, args ...any) error {
+	if ve.Has(field) {
+		return nil
+	}
+
+	var exists bool
+	err := db.QueryRowContext(ctx, query, args...).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		ve.Add(field, "exists", message)
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,286 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type SessionRepository struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Sets session id and creation time.
+// Errors: -
+func (r *SessionRepository) Insert(ctx context.Context,
+	sess *auth.Session, digest string) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`INSERT INTO sessions (user_id, family_id, refresh_token, 
+		expires_at, last_used_at, user_agent, ip)
+		VALUES ($1, $2, $3, $4, now(), $5, $6) 
+		RETURNING id, created_at, last_used_at`)
+	if err != nil {
+		return e.Internal("insert session", err)
+	}
+
+	if err := stmt.QueryRowContext(ctx,
+		&sess.UserId, &sess.FamilyId, &digest, &sess.ExpiresAt,
+		&sess.UserAgent, &sess.Ip).Scan(
+		&sess.Id, &sess.CreatedAt, &sess.LastUsedAt); err != nil {
+		return e.Internal("insert session", err)
+	}
+
+	return nil
+}
+
+// Errors: ErrSessionNotExist
+func (r *SessionRepository) GetByRefreshToken(ctx context.Context,
+	digest string) (auth.Session, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, user_id, family_id, expires_at 
+		FROM sessions WHERE refresh_token=$1`)
+	if err != nil {
+		return auth.Session{}, e.Internal("get session by refresh token", err)
+	}
+
+	var sess auth.Session
+	if err := stmt.QueryRowContext(ctx, &digest).Scan(
+		&sess.Id, &sess.UserId, &sess.FamilyId, &sess.ExpiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return auth.Session{}, e.ErrSessionNotExist
+		}
+		return auth.Session{}, e.Internal("get session by refresh token", err)
+	}
+
+	return sess, nil
+}
+
+// Replaces refresh token and remembers rotated out one.
+// Errors: ErrSessionNotExist
+func (r *SessionRepository) Rotate(ctx context.Context, sess auth.Session,
+	oldDigest, newDigest string, expiresAt time.Time, client auth.ClientInfo) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Internal("rotate session", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE sessions SET 
+		refresh_token=$2, expires_at=$3, 
+		last_used_at=now(), user_agent=$4, ip=$5
+		WHERE refresh_token=$1`,
+		&oldDigest, &newDigest, &expiresAt,
+		&client.UserAgent, &client.Ip)
+	if err != nil {
+		return e.Internal("rotate session", err)
+	}
+
+	// Token was rotated by concurrent request
+	if n, _ := res.RowsAffected(); n == 0 {
+		return e.ErrSessionNotExist
+	}
+
+	// Rotated out token is remembered to detect its reuse
+	if _, err = tx.ExecContext(ctx,
+		`INSERT INTO rotated_refresh_tokens (family_id, refresh_token)
+		VALUES ($1, $2)`,
+		&sess.FamilyId, &oldDigest); err != nil {
+		return e.Internal("rotate session", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return e.Internal("rotate session", err)
+	}
+
+	return nil
+}
+
+// Errors: ErrSessionNotExist
+func (r *SessionRepository) GetByRotatedToken(ctx context.Context,
+	digest string) (auth.Session, error) {
+	var sess auth.Session
+	err := r.db.QueryRowContext(ctx,
+		`SELECT s.id, s.user_id, s.family_id, s.expires_at 
+		FROM rotated_refresh_tokens AS rt
+		JOIN sessions AS s ON s.family_id=rt.family_id
+		WHERE rt.refresh_token=$1`,
+		&digest).Scan(&sess.Id, &sess.UserId,
+		&sess.FamilyId, &sess.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return auth.Session{}, e.ErrSessionNotExist
+		}
+		return auth.Session{}, e.Internal("get session by rotated token", err)
+	}
+
+	return sess, nil
+}
+
+// Returns not expired sessions, recently used first.
+// Errors: ErrSessionsNotFound
+func (r *SessionRepository) GetAllByUserId(ctx context.Context, userId int) (
+	[]auth.Session, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, user_id, family_id, expires_at, 
+		created_at, last_used_at, user_agent, ip
+		FROM sessions WHERE user_id=$1 AND expires_at > now()
+		ORDER BY last_used_at DESC`)
+	if err != nil {
+		return nil, e.Internal("get sessions by user id", err)
+	}
+
+	var rows *sql.Rows
+	if rows, err = stmt.QueryContext(ctx, &userId); err != nil {
+		return nil, e.Internal("get sessions by user id", err)
+	}
+	defer rows.Close()
+
+	var sessions []auth.Session
+	for rows.Next() {
+		var s auth.Session
+		if err := rows.Scan(&s.Id, &s.UserId, &s.FamilyId,
+			&s.ExpiresAt, &s.CreatedAt, &s.LastUsedAt,
+			&s.UserAgent, &s.Ip); err != nil {
+			return nil, e.Internal("get sessions by user id", err)
+		}
+		sessions = append(sessions, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, e.Internal("get sessions by user id", err)
+	}
+
+	if len(sessions) == 0 {
+		return sessions, e.ErrSessionsNotFound
+	}
+
+	return sessions, nil
+}
+
+// Deletes oldest sessions, so only keep - 1 sessions are left.
+// Errors: -
+func (r *SessionRepository) EvictOldest(ctx context.Context,
+	userId, keep int) error {
+	getStmt, err := r.stmts.Prepare(ctx,
+		`SELECT COUNT(*) FROM sessions WHERE user_id=$1`)
+	if err != nil {
+		return e.Internal("evict oldest sessions", err)
+	}
+	var count int
+	if err = getStmt.QueryRowContext(ctx, &userId).Scan(&count); err != nil {
+		return e.Internal("evict oldest sessions", err)
+	}
+	if count < keep {
+		return nil
+	}
+
+	deleteStmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM sessions WHERE id IN (
+		SELECT id FROM sessions WHERE user_id=$1 
+		ORDER BY created_at, id LIMIT $2)`)
+	if err != nil {
+		return e.Internal("evict oldest sessions", err)
+	}
+
+	if _, err := deleteStmt.ExecContext(ctx, &userId, count-keep+1); err != nil {
+		return e.Internal("evict oldest sessions", err)
+	}
+
+	return nil
+}
+
+// Errors: -
+func (r *SessionRepository) DeleteByRefreshToken(ctx context.Context,
+	digest string) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM sessions WHERE refresh_token=$1`)
+	if err != nil {
+		return e.Internal("delete session by refresh token", err)
+	}
+
+	if _, err := stmt.ExecContext(ctx, &digest); err != nil {
+		return e.Internal("delete session by refresh token", err)
+	}
+
+	return nil
+}
+
+// Rotated tokens of family are removed by cascade.
+// Errors: -
+func (r *SessionRepository) DeleteByFamilyId(ctx context.Context,
+	familyId string) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM sessions WHERE family_id=$1`)
+	if err != nil {
+		return e.Internal("delete session by family id", err)
+	}
+
+	if _, err := stmt.ExecContext(ctx, &familyId); err != nil {
+		return e.Internal("delete session by family id", err)
+	}
+
+	return nil
+}
+
+// Errors: ErrSessionNotExist
+func (r *SessionRepository) DeleteById(ctx context.Context,
+	userId, sessionId int) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM sessions WHERE id=$1 AND user_id=$2`)
+	if err != nil {
+		return e.Internal("delete session by id", err)
+	}
+
+	res, err := stmt.ExecContext(ctx, &sessionId, &userId)
+	if err != nil {
+		return e.Internal("delete session by id", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return e.ErrSessionNotExist
+	}
+
+	return nil
+}
+
+// Errors: -
+func (r *SessionRepository) DeleteByUserId(ctx context.Context,
+	userId int) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM sessions WHERE user_id=$1`)
+	if err != nil {
+		return e.Internal("delete sessions by user id", err)
+	}
+
+	if _, err := stmt.ExecContext(ctx, &userId); err != nil {
+		return e.Internal("delete sessions by user id", err)
+	}
+
+	return nil
+}
+
+// Errors: -
+func (r *SessionRepository) DeleteOthers(ctx context.Context,
+	userId, sessionId int) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM sessions WHERE user_id=$1 AND id!=$2`)
+	if err != nil {
+		return e.Internal("delete other sessions", err)
+	}
+
+	if _, err := stmt.ExecContext(ctx, &userId, &sessionId); err != nil {
+		return e.Internal("delete other sessions", err)
+	}
+
+	return nil
+}
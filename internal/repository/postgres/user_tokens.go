@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type UserTokenRepository struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+}
+
+func NewUserTokenRepository(db *sql.DB) *UserTokenRepository {
+	return &UserTokenRepository{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Previous tokens of user with the same purpose are deleted.
+// Sets token id.
+// Errors: -
+func (r *UserTokenRepository) Replace(ctx context.Context,
+	t *auth.UserToken, digest string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Internal("replace user token", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx,
+		`DELETE FROM user_tokens WHERE user_id=$1 AND purpose=$2`,
+		&t.UserId, &t.Purpose); err != nil {
+		return e.Internal("replace user token", err)
+	}
+
+	if err = tx.QueryRowContext(ctx,
+		`INSERT INTO user_tokens (user_id, purpose, digest, expires_at) 
+		VALUES ($1, $2, $3, $4) RETURNING id`,
+		&t.UserId, &t.Purpose, &digest, &t.ExpiresAt).Scan(&t.Id); err != nil {
+		return e.Internal("replace user token", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return e.Internal("replace user token", err)
+	}
+
+	return nil
+}
+
+// Deletes token, so it can be used once.
+// Errors: ErrTokenNotValid
+func (r *UserTokenRepository) Consume(ctx context.Context,
+	purpose, digest string) (auth.UserToken, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`DELETE FROM user_tokens WHERE digest=$1 AND purpose=$2 
+		RETURNING id, user_id, purpose, expires_at`)
+	if err != nil {
+		return auth.UserToken{}, e.Internal("consume user token", err)
+	}
+
+	var t auth.UserToken
+	if err = stmt.QueryRowContext(ctx, &digest, &purpose).Scan(
+		&t.Id, &t.UserId, &t.Purpose, &t.ExpiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return auth.UserToken{}, e.ErrTokenNotValid
+		}
+		return auth.UserToken{}, e.Internal("consume user token", err)
+	}
+
+	return t, nil
+}
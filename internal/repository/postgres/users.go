@@ -0,0 +1,353 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"VEEEKTOR_api/internal/models"
+	"VEEEKTOR_api/pkg/database/pgsql"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type UserRepository struct {
+	db    *sql.DB
+	stmts *pgsql.StmtCache
+}
+
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{db: db, stmts: pgsql.NewStmtCache(db)}
+}
+
+// Errors: ErrUserNotFound
+func (r *UserRepository) GetById(ctx context.Context,
+	userId int) (models.User, error) {
+	stmt, err := r.stmts.Prepare(ctx, `
+	SELECT email, password, group_id, name, 
+	patronymic, surname, role_id, dep_id, active, email_verified
+	FROM users WHERE id=$1`)
+	if err != nil {
+		return models.User{}, e.Internal("get user by id", err)
+	}
+
+	var usr models.User
+	usr.Id = userId
+
+	if err := stmt.QueryRowContext(ctx, userId).Scan(
+		&usr.Email, &usr.Password, &usr.GroupId,
+		&usr.Name, &usr.Patronymic, &usr.Surname,
+		&usr.RoleId, &usr.DepId, &usr.Active,
+		&usr.EmailVerified); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return usr, e.ErrUserNotFound
+		}
+		return models.User{}, e.Internal("get user by id", err)
+	}
+
+	return usr, nil
+}
+
+// Errors: ErrUserNotFound
+func (r *UserRepository) GetByEmail(ctx context.Context,
+	email string) (models.User, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, email, password, group_id, name, 
+		patronymic, surname, role_id, dep_id, active, email_verified 
+		FROM users WHERE email=$1`)
+	if err != nil {
+		return models.User{}, e.Internal("get user by email", err)
+	}
+
+	var usr models.User
+	if err := stmt.QueryRowContext(ctx, &email).Scan(
+		&usr.Id, &usr.Email, &usr.Password,
+		&usr.GroupId, &usr.Name, &usr.Patronymic,
+		&usr.Surname, &usr.RoleId, &usr.DepId, &usr.Active,
+		&usr.EmailVerified); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, e.ErrUserNotFound
+		}
+		return models.User{}, e.Internal("get user by email", err)
+	}
+
+	return usr, nil
+}
+
+// User linked to subject of identity provider.
+// Errors: ErrUserNotFound
+func (r *UserRepository) GetByIdentity(ctx context.Context,
+	issuer, subject string) (models.User, error) {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT u.id, u.email, u.password, u.group_id, u.name,
+		u.patronymic, u.surname, u.role_id, u.dep_id, u.active,
+		u.email_verified
+		FROM user_identities i JOIN users u ON u.id = i.user_id
+		WHERE i.issuer=$1 AND i.subject=$2`)
+	if err != nil {
+		return models.User{}, e.Internal("get user by identity", err)
+	}
+
+	var usr models.User
+	if err := stmt.QueryRowContext(ctx, &issuer, &subject).Scan(
+		&usr.Id, &usr.Email, &usr.Password,
+		&usr.GroupId, &usr.Name, &usr.Patronymic,
+		&usr.Surname, &usr.RoleId, &usr.DepId, &usr.Active,
+		&usr.EmailVerified); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, e.ErrUserNotFound
+		}
+		return models.User{}, e.Internal("get user by identity", err)
+	}
+
+	return usr, nil
+}
+
+// Links subject of identity provider to user, existing link is kept.
+// Errors: ErrUserNotFound
+func (r *UserRepository) LinkIdentity(ctx context.Context,
+	userId int, issuer, subject string) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`INSERT INTO user_identities (user_id, issuer, subject)
+		SELECT id, $2, $3 FROM users WHERE id=$1
+		ON CONFLICT (issuer, subject) DO NOTHING`)
+	if err != nil {
+		return e.Internal("link user identity", err)
+	}
+
+	res, err := stmt.ExecContext(ctx, &userId, &issuer, &subject)
+	if err != nil {
+		return e.Internal("link user identity", err)
+	}
+	// Nothing is inserted for missing user and for existing link
+	if n, _ := res.RowsAffected(); n == 0 {
+		_, err = r.GetById(ctx, userId)
+		return err
+	}
+
+	return nil
+}
+
+// Collects violations of role, group and department references.
+// Errors: ErrValidationFailed
+func (r *UserRepository) CheckReferences(ctx context.Context,
+	usr *models.User) error {
+	ve := &e.ValidationError{}
+	if err := checkReference(ctx, r.db, ve, "role_id", "role not found",
+		`SELECT 1 FROM roles WHERE id=$1`, &usr.RoleId); err != nil {
+		return e.Internal("check user references", err)
+	}
+	if err := checkReference(ctx, r.db, ve, "group_id", "group not found",
+		`SELECT 1 FROM groups WHERE id=$1`, &usr.GroupId); err != nil {
+		return e.Internal("check user references", err)
+	}
+	if err := checkReference(ctx, r.db, ve, "dep_id", "department not found",
+		`SELECT 1 FROM departments WHERE id=$1`, &usr.DepId); err != nil {
+		return e.Internal("check user references", err)
+	}
+
+	return ve.Err()
+}
+
+// Fields must be validated and password hashed by caller.
+// Errors: ErrValidationFailed, ErrUserExist
+func (r *UserRepository) Insert(ctx context.Context, usr *models.User) error {
+	if err := r.CheckReferences(ctx, usr); err != nil {
+		return err
+	}
+
+	if err := r.checkEmail(ctx, usr); err != nil {
+		return err
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`INSERT INTO users (
+		email, password, group_id, name, 
+		patronymic, surname, role_id, dep_id, active, email_verified) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`)
+	if err != nil {
+		return e.Internal("insert user", err)
+	}
+	if err = stmt.QueryRowContext(ctx,
+		&usr.Email, &usr.Password, &usr.GroupId,
+		&usr.Name, &usr.Patronymic, &usr.Surname,
+		&usr.RoleId, &usr.DepId, &usr.Active,
+		&usr.EmailVerified).Scan(&usr.Id); err != nil {
+		return e.Internal("insert user", err)
+	}
+	return nil
+}
+
+// Email must not belong to other user.
+// Errors: ErrUserExist
+func (r *UserRepository) checkEmail(ctx context.Context,
+	usr *models.User) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT 1 FROM users WHERE email=$1 AND id<>$2`)
+	if err != nil {
+		return e.Internal("check user email", err)
+	}
+
+	var exists bool
+	err = stmt.QueryRowContext(ctx, &usr.Email, &usr.Id).Scan(&exists)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return e.Internal("check user email", err)
+	} else if err == nil {
+		return e.ErrUserExist
+	}
+
+	return nil
+}
+
+// Password, activity and email verification are not changed.
+// Errors: ErrUserNotFound, ErrValidationFailed, ErrUserExist
+func (r *UserRepository) Update(ctx context.Context, usr *models.User) error {
+	if err := r.CheckReferences(ctx, usr); err != nil {
+		return err
+	}
+	if err := r.checkEmail(ctx, usr); err != nil {
+		return err
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`UPDATE users SET email=$2, group_id=$3, name=$4, 
+		patronymic=$5, surname=$6, role_id=$7, dep_id=$8 
+		WHERE id=$1`)
+	if err != nil {
+		return e.Internal("update user", err)
+	}
+
+	res, err := stmt.ExecContext(ctx,
+		&usr.Id, &usr.Email, &usr.GroupId,
+		&usr.Name, &usr.Patronymic, &usr.Surname,
+		&usr.RoleId, &usr.DepId)
+	if err != nil {
+		return e.Internal("update user", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return e.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Errors: -
+func (r *UserRepository) UpdatePassword(ctx context.Context,
+	userId int, hash string) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`UPDATE users SET password=$2 WHERE id=$1`)
+	if err != nil {
+		return e.Internal("update user password", err)
+	}
+
+	if _, err = stmt.ExecContext(ctx, &userId, &hash); err != nil {
+		return e.Internal("update user password", err)
+	}
+
+	return nil
+}
+
+// Errors: ErrUserNotFound
+func (r *UserRepository) SetActive(ctx context.Context,
+	userId int, active bool) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`UPDATE users SET active=$2 WHERE id=$1`)
+	if err != nil {
+		return e.Internal("set user activity", err)
+	}
+
+	res, err := stmt.ExecContext(ctx, &userId, &active)
+	if err != nil {
+		return e.Internal("set user activity", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return e.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Errors: ErrUserNotFound
+func (r *UserRepository) SetEmailVerified(ctx context.Context,
+	userId int, verified bool) error {
+	stmt, err := r.stmts.Prepare(ctx,
+		`UPDATE users SET email_verified=$2 WHERE id=$1`)
+	if err != nil {
+		return e.Internal("set user email verification", err)
+	}
+
+	res, err := stmt.ExecContext(ctx, &userId, &verified)
+	if err != nil {
+		return e.Internal("set user email verification", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return e.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Zero filter values disable conditions, so statements are prepared once.
+// ILIKE ignores case like memory repository does, letters beyond ASCII
+// are folded when LC_CTYPE of database is UTF-8 one.
+const usersFilter = `
+	WHERE ($1 = '' OR email ILIKE $1 OR name ILIKE $1
+	OR patronymic ILIKE $1 OR surname ILIKE $1)
+	AND ($2 = 0 OR role_id=$2) AND ($3 = 0 OR group_id=$3)
+	AND ($4 = 0 OR dep_id=$4) AND ($5::boolean IS NULL OR active=$5)`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Page of users ordered by id and total count of matching users.
+// Passwords are not loaded.
+// Errors: -
+func (r *UserRepository) Search(ctx context.Context,
+	f models.UserFilter) ([]models.User, int, error) {
+	var pattern string
+	if f.Query != "" {
+		pattern = "%" + likeEscaper.Replace(f.Query) + "%"
+	}
+	args := []any{pattern, f.RoleId, f.GroupId, f.DepId, f.Active}
+
+	countStmt, err := r.stmts.Prepare(ctx,
+		`SELECT count(*) FROM users`+usersFilter)
+	if err != nil {
+		return nil, 0, e.Internal("search users", err)
+	}
+
+	var total int
+	if err = countStmt.QueryRowContext(ctx, args...).Scan(&total); err != nil {
+		return nil, 0, e.Internal("search users", err)
+	}
+
+	stmt, err := r.stmts.Prepare(ctx,
+		`SELECT id, email, group_id, name, patronymic, 
+		surname, role_id, dep_id, active, email_verified 
+		FROM users`+usersFilter+` ORDER BY id LIMIT $6 OFFSET $7`)
+	if err != nil {
+		return nil, 0, e.Internal("search users", err)
+	}
+
+	rows, err := stmt.QueryContext(ctx, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, e.Internal("search users", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var usr models.User
+		if err = rows.Scan(&usr.Id, &usr.Email, &usr.GroupId,
+			&usr.Name, &usr.Patronymic, &usr.Surname,
+			&usr.RoleId, &usr.DepId, &usr.Active,
+			&usr.EmailVerified); err != nil {
+			return nil, 0, e.Internal("search users", err)
+		}
+		users = append(users, usr)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, e.Internal("search users", err)
+	}
+
+	return users, total, nil
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+	"VEEEKTOR_api/pkg/validate"
+)
+
+// Admin users GET logic.
+// Lists users ordered by id, allowed only to admins.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Query values (all optional):
+// q : part of email, name, patronymic or surname;
+// role_id, group_id, dep_id : ids users must have;
+// active : true or false;
+// limit : page size (1-100), default 20;
+// offset : count of skipped users.
+// Response:
+// Error message or page of users:
+// users : users without passwords;
+// total : count of users matching filter;
+// limit, offset : applied page bounds.
+// Response codes:
+// 200, 400, 401, 403.
+func (h *Handler) AdminUsersGetHandler(w http.ResponseWriter, r *http.Request) {
+	f, err := userFilter(r)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	users, total, err := h.Users.Search(r.Context(), f)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	jsonBytes, _ := json.Marshal(models.UserPage{
+		Users: users, Total: total, Limit: f.Limit, Offset: f.Offset})
+	w.Write(jsonBytes)
+}
+
+// Admin user GET logic.
+// User id is taken from path: /api/admin/users/{id}.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Response:
+// Error message or user data without password.
+// Response codes:
+// 200, 400, 401, 403, 404, 500.
+func (h *Handler) AdminUserGetHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := h.Users.GetById(r.Context(), userId)
+	if err != nil {
+		e.ResponseWithError(w, r, userReadStatus(err), err)
+		return
+	}
+	user.Password = ""
+
+	jsonBytes, _ := json.Marshal(user)
+	w.Write(jsonBytes)
+}
+
+// Admin users INSERT logic.
+// Creates user of any role, e.g. teacher or admin.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Expected body:
+// email : user email (4-64 symbols);
+// password : user password (8-50 symbols);
+// name, patronymic, surname : user names (2-30 symbols);
+// group_id : group id;
+// role_id : role id;
+// dep_id : department id;
+// active : false to create deactivated account, default true;
+// email_verified : false to require verification, default true.
+// Response:
+// id : user id.
+// Response codes:
+// 200, 400, 401, 403, 409, 422.
+func (h *Handler) AdminUsersCreateHandler(w http.ResponseWriter, r *http.Request) {
+	dto := models.User{Active: true, EmailVerified: true}
+	if err := decodeBody(w, r, &dto); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	dto.Id = 0
+
+	// All field and reference violations are reported at once
+	ve := validate.Struct(&dto)
+	if err := ve.Merge(h.Users.CheckReferences(r.Context(), &dto)); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if err := ve.Err(); err != nil {
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var err error
+	if dto.Password, err = models.HashPassword(dto.Password); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError,
+			e.Internal("create user", err))
+		return
+	}
+
+	if err = h.Users.Insert(r.Context(), &dto); err != nil {
+		e.ResponseWithError(w, r, userWriteStatus(err), err)
+		return
+	}
+
+	w.Write([]byte(fmt.Sprintf(`{"id" : %d}`, dto.Id)))
+}
+
+// Admin users PATCH logic.
+// Changes role, group and department of user.
+// User id is taken from path: /api/admin/users/{id}.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Expected body (omitted fields are not changed):
+// role_id : role id;
+// group_id : group id;
+// dep_id : department id.
+// New role and group are put into access tokens on next refresh.
+// Response:
+// Error message or updated user data without password.
+// Response codes:
+// 200, 400, 401, 403, 404, 422, 500.
+func (h *Handler) AdminUsersUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var patch models.UserAdminPatch
+	if err = decodeBody(w, r, &patch); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := h.Users.GetById(r.Context(), userId)
+	if err != nil {
+		e.ResponseWithError(w, r, userReadStatus(err), err)
+		return
+	}
+	patch.Apply(&user)
+
+	if err = h.Users.Update(r.Context(), &user); err != nil {
+		e.ResponseWithError(w, r, userWriteStatus(err), err)
+		return
+	}
+	user.Password = ""
+
+	jsonBytes, _ := json.Marshal(user)
+	w.Write(jsonBytes)
+}
+
+// Admin users deactivation logic.
+// Deactivated user can not sign in, sessions of user are revoked.
+// Issued access tokens stay valid until they expire.
+// User id is taken from path: /api/admin/users/{id}/deactivate.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Response: Error message or StatusOk.
+// Response codes:
+// 200, 400, 401, 403, 404, 500.
+func (h *Handler) AdminUsersDeactivateHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = h.Users.SetActive(r.Context(), userId, false); err != nil {
+		e.ResponseWithError(w, r, userReadStatus(err), err)
+		return
+	}
+
+	if err = h.Auth.ClearSessionsByUserId(r.Context(), userId); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Admin users reactivation logic.
+// User id is taken from path: /api/admin/users/{id}/activate.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Response: Error message or StatusOk.
+// Response codes:
+// 200, 400, 401, 403, 404, 500.
+func (h *Handler) AdminUsersActivateHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = h.Users.SetActive(r.Context(), userId, true); err != nil {
+		e.ResponseWithError(w, r, userReadStatus(err), err)
+		return
+	}
+}
+
+// Admin force logout logic.
+// Revokes all sessions of user, so refresh tokens stop working.
+// User id is taken from path: /api/admin/users/{id}/sessions.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Response: Error message or StatusOk.
+// Response codes:
+// 200, 400, 401, 403, 404, 500.
+func (h *Handler) AdminUserSessionsDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err = h.Users.GetById(r.Context(), userId); err != nil {
+		e.ResponseWithError(w, r, userReadStatus(err), err)
+		return
+	}
+
+	if err = h.Auth.ClearSessionsByUserId(r.Context(), userId); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Filter of admin listing from query string.
+// Errors: ErrUrlValueNotValid
+func userFilter(r *http.Request) (models.UserFilter, error) {
+	query := r.URL.Query()
+	f := models.UserFilter{
+		Query: query.Get("q"),
+		Limit: models.DefaultUsersLimit,
+	}
+
+	for key, dst := range map[string]*int{
+		"role_id":  &f.RoleId,
+		"group_id": &f.GroupId,
+		"dep_id":   &f.DepId,
+		"limit":    &f.Limit,
+		"offset":   &f.Offset,
+	} {
+		if value := query.Get(key); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return models.UserFilter{}, e.ErrUrlValueNotValid
+			}
+			*dst = n
+		}
+	}
+	if f.Limit < 1 || f.Limit > models.MaxUsersLimit {
+		return models.UserFilter{}, e.ErrUrlValueNotValid
+	}
+
+	if value := query.Get("active"); value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			return models.UserFilter{}, e.ErrUrlValueNotValid
+		}
+		f.Active = &active
+	}
+
+	return f, nil
+}
+
+// Status of lookup errors of user repository.
+func userReadStatus(err error) int {
+	if errors.Is(err, e.ErrUserNotFound) {
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Status of Insert and Update errors of user repository.
+func userWriteStatus(err error) int {
+	switch {
+	case errors.Is(err, e.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, e.ErrUserExist):
+		return http.StatusConflict
+	}
+
+	return http.StatusBadRequest
+}
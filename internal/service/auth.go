@@ -2,6 +2,7 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	auth "VEEEKTOR_api/internal/auth"
@@ -9,6 +10,8 @@ import (
 )
 
 // Token refresh for mobile and web clients.
+// Refresh token is rotated on every call. Reuse of rotated token
+// revokes the session it was issued for.
 // Expected cookie / body (for mobile clients):
 // refresh_token : <refresh token>.
 // Response:
@@ -18,52 +21,50 @@ import (
 // Access token claims:
 // exp : token expiration date and time;
 // user_id : user id;
-// role_id : user role id.
+// role_id : user role id;
+// session_id : id of session token pair belongs to.
 // Cookie:
 // refresh_token : <rt>.
 // Response codes:
-// 200, 400, 401, 405.
-func UpdateToken(w http.ResponseWriter, r *http.Request) {
+// 200, 400, 401, 405, 500.
+func (h *Handler) UpdateToken(w http.ResponseWriter, r *http.Request) {
 	var err error
-	if r.Method != http.MethodPost {
-		e.ResponseWithError(
-			w, r, http.StatusMethodNotAllowed, e.ErrOnlyPostAllowed)
-		return
-	}
-
 	var refreshToken string
-	if refreshToken, err = auth.GetRefreshTokenFromCookieOrBody(r); err != nil {
+	if refreshToken, err = refreshTokenFromCookieOrBody(w, r); err != nil {
 		e.ResponseWithError(
 			w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	var sess auth.Session
-	if sess, err = auth.GetSessionByRefreshToken(refreshToken); err != nil {
+	if sess, err = h.Auth.GetSessionByRefreshToken(
+		r.Context(), refreshToken); err != nil {
 		e.ResponseWithError(
 			w, r, http.StatusUnauthorized, err)
 		return
 	}
 
 	var exp bool
-	if exp, _ = sess.IsExpired(); exp {
+	if exp, err = h.Auth.IsExpired(r.Context(), sess); err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusInternalServerError, err)
+		return
+	} else if exp {
 		e.ResponseWithError(
 			w, r, http.StatusUnauthorized, e.ErrTokenExpired)
 		return
 	}
 
-	tokens, _ := auth.UpdateSession(sess)
+	tokens, err := h.Auth.UpdateSession(r.Context(), sess, auth.GetClientInfo(r))
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusUnauthorized, err)
+		return
+	}
 
 	// Write jwt and refresh token pair
 	jsonBytes, _ := json.Marshal(tokens)
-	http.SetCookie(w, &http.Cookie{
-		Name:  "refresh_token",
-		Value: tokens.RefreshToken,
-		Path:  "/",
-		// Secure:   true, *causes cookie not set with http unsecure protocol*
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-	})
+	h.setRefreshTokenCookie(w, tokens.RefreshToken)
 
 	w.Write(jsonBytes)
 }
@@ -77,29 +78,111 @@ func UpdateToken(w http.ResponseWriter, r *http.Request) {
 // "refresh_token" : <null>.
 // Response codes:
 // 200, 400, 405.
-func Logout(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var refreshToken string
+	if refreshToken, err = refreshTokenFromCookieOrBody(w, r); err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusMethodNotAllowed, e.ErrOnlyPostAllowed)
+			w, r, http.StatusBadRequest, e.ErrTokenNotProvided)
 		return
 	}
 
-	var err error
-	var refreshToken string
-	if refreshToken, err = auth.GetRefreshTokenFromCookieOrBody(r); err != nil {
+	if err = h.Auth.DeleteSessionByRT(r.Context(), refreshToken); err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrTokenNotProvided)
+			w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.setRefreshTokenCookie(w, "")
+}
+
+// Sessions GET logic.
+// Returns active sessions (devices) of token owner.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Response: Error message or sessions:
+// id : session id;
+// created_at : session creation time in UNIX format;
+// last_used_at : last sign in or token refresh time in UNIX format;
+// user_agent : user agent of client;
+// ip : ip address of client;
+// current : true for session access token belongs to.
+// Response codes:
+// 200, 400, 401, 404.
+func (h *Handler) SessionsGetHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	sessions, err := h.Auth.GetSessionsByUserId(
+		r.Context(), p.UserId, p.SessionId)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
 		return
 	}
 
-	_ = auth.DeleteSessionByRT(refreshToken)
+	jsonBytes, _ := json.Marshal(sessions)
+	w.Write(jsonBytes)
+}
+
+// Sessions DELETE logic.
+// Revokes session of token owner.
+// Session id is taken from path: /api/auth/sessions/{id}.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Response: Error message or StatusOk.
+// Response codes:
+// 200, 400, 401, 404.
+func (h *Handler) SessionsDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	sessionId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = h.Auth.DeleteSessionById(r.Context(),
+		principal(r).UserId, sessionId); err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Other sessions DELETE logic.
+// Revokes all sessions of token owner except current one.
+// Access token without session id is answered with 401,
+// it must be refreshed first.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Response: Error message or StatusOk.
+// Response codes:
+// 200, 400, 401, 405, 500.
+func (h *Handler) OtherSessionsDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	err := h.Auth.DeleteOtherSessions(r.Context(), p.UserId, p.SessionId)
+	if errors.Is(err, e.ErrSessionUnknown) {
+		e.ResponseWithError(w, r, http.StatusUnauthorized, err)
+		return
+	} else if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Refresh token from cookie or, for mobile clients, from body.
+// Errors: ErrTokenNotProvided
+func refreshTokenFromCookieOrBody(w http.ResponseWriter,
+	r *http.Request) (string, error) {
+	var rt auth.RefreshToken
+	if cookie, err := r.Cookie("refresh_token"); err == nil {
+		rt.Token = cookie.Value
+	} else if err = decodeBody(w, r, &rt); err != nil {
+		return "", e.ErrTokenNotProvided
+	}
+	if rt.Token == "" {
+		return "", e.ErrTokenNotProvided
+	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:  "refresh_token",
-		Value: "",
-		Path:  "/",
-		// Secure:   true, *causes cookie not set with http unsecure protocol*
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-	})
+	return rt.Token, nil
 }
@@ -7,109 +7,89 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
-
-	"github.com/golang-jwt/jwt"
 )
 
-func GetCouresesHandler(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetAccessTokenFromHeader(r)
-	if err != nil {
-		e.ResponseWithError(w, r, http.StatusBadRequest, err)
-		return
-	}
-
-	claims, err := auth.GetTokenClaims(token)
-	if err == e.ErrTokenExpired {
-		e.ResponseWithError(w, r, http.StatusUnauthorized, e.ErrTokenExpired)
-		return
-	} else if err != nil {
-		e.ResponseWithError(w, r, http.StatusBadRequest, err)
-		return
-	}
-
-	switch r.Method {
-	case http.MethodGet:
-		CoursesGetHandler(w, r, token, claims)
-	case http.MethodPost:
-		CoursesCreateHandler(w, r, token, claims)
-	case http.MethodPut:
-		CoursesUpdateHandler(w, r, token, claims)
-	default:
-		e.ResponseWithError(w, r, http.StatusMethodNotAllowed,
-			e.ErrMethodNotAllowed)
-	}
-}
-
 // Courses GET logic.
-// Courses can be get by id in url values or by group_id in token claims;
+// Students get courses of their group, teachers and admins get
+// courses they teach.
 // Expected header:
 // Authorization : Bearer <access token>
-// Response: Error message or course(s) by course_id (group_id):
+// Response: Error message or courses:
 // id : id of course;
 // name : name of course;
 // term : term of course;
-// teacher_id : id of teacher (user) (get by course_id only);
-// markdown : markdown text of course (get by course_id only);
-// dep_id : id of course department (get by course_id only);
-// teacher.name : teacher name (get by group_id only);
-// teacher.patronymic : teacher patronymic (get by group_id only);
-// teacher.surname : teacher surname (get by group_id only);
-// teacher.dep : teacher department (get by group_id only);
-// dep : course department (get by group_id only);
-// modified_at : course last modified time in UNIX format (get by group_id only).
+// teacher.name : teacher name;
+// teacher.patronymic : teacher patronymic;
+// teacher.surname : teacher surname;
+// teacher.dep : teacher department;
+// dep : course department;
+// modified_at : course last modified time in UNIX format
+// (RFC 3339 string since v2).
 // Response codes:
-// 200, 400, 401, 403, 404.
-func CoursesGetHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
+// 200, 400, 401, 404.
+func (h *Handler) CoursesGetHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
-	var jsonBytes []byte
 
-	rawQuery := r.URL.Query()
-	if rawQuery.Has("id") {
-		courseId, err := strconv.Atoi(rawQuery.Get("id"))
+	p := principal(r)
+	var courses []models.CourseMultipleExportDTO
+	if p.RoleId == auth.RoleStudent {
+		courses, err = h.Courses.GetAllByGroupId(r.Context(), p.GroupId)
 		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
+			e.ResponseWithError(w, r, http.StatusNotFound, err)
 			return
 		}
-
-		course, err := models.GetCourseById(courseId)
+	} else { // Teacher or admin
+		courses, err = h.Courses.GetAllByTeacherId(r.Context(), p.UserId)
 		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, e.ErrCourseNotFound)
+			e.ResponseWithError(w, r, http.StatusNotFound, err)
 			return
 		}
+	}
 
-		// Error checked in GetCourseById
-		access, _ := course.CheckAccess(claims)
-		if access == 0 {
-			e.ResponseWithError(
-				w, r, http.StatusForbidden, e.ErrAccessDenied)
-			return
-		}
+	jsonBytes, _ := json.Marshal(serializeCourses(r, courses))
+	w.Write(jsonBytes)
+}
 
-		jsonBytes, _ = json.Marshal(course)
-
-	} else {
-		var courses []models.CourseMultipleExportDTO
-		if claims["role_id"] == 1 { // Student
-			courses, err = models.GetAllCoursesByGroupId(claims["group_id"].(int))
-			if err != nil {
-				e.ResponseWithError(w, r, http.StatusNotFound, e.ErrCoursesNotFound)
-				return
-			}
-		} else { // Teacher or admin
-			courses, err = models.GetAllCoursesByTeacherId(claims["user_id"].(int))
-			if err != nil {
-				e.ResponseWithError(w, r, http.StatusNotFound, e.ErrCoursesNotFound)
-				return
-			}
-		}
+// Course GET logic.
+// Course id is taken from path: /api/courses/{id}.
+// Expected header:
+// Authorization : Bearer <access token>
+// Response: Error message or course:
+// id : id of course;
+// name : name of course;
+// term : term of course;
+// teacher_id : id of teacher (user);
+// markdown : markdown text of course;
+// dep_id : id of course department.
+// Response codes:
+// 200, 400, 401, 403, 404.
+func (h *Handler) CourseGetHandler(w http.ResponseWriter, r *http.Request) {
+	courseId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
 
-		jsonBytes, _ = json.Marshal(courses)
+	course, err := h.Courses.GetById(r.Context(), courseId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusNotFound, err)
+		return
 	}
 
+	access, err := h.courseAccess(r.Context(), course.Id)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusBadRequest, err)
+		return
+	}
+	if access == 0 {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrAccessDenied)
+		return
+	}
+
+	jsonBytes, _ := json.Marshal(course)
 	w.Write(jsonBytes)
 }
 
@@ -126,27 +106,16 @@ func CoursesGetHandler(w http.ResponseWriter, r *http.Request,
 // markdown : markdown text of course;
 // dep_id : id of course department.
 // Response codes:
-// 200, 400, 401, 403.
-func CoursesCreateHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
-		e.ResponseWithError(
-			w, r, http.StatusForbidden, e.ErrAccessDenied)
-		return
-	}
-
-	bytes := make([]byte, r.ContentLength)
-	r.Body.Read(bytes)
-
+// 200, 400, 401, 403, 422.
+func (h *Handler) CoursesCreateHandler(w http.ResponseWriter, r *http.Request) {
 	var course models.Course
-
-	if err := json.Unmarshal(bytes, &course); err != nil {
+	if err := decodeBody(w, r, &course); err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUnableToUnmarshalBody)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	course_id, err := course.Insert()
+	course_id, err := h.Courses.Insert(r.Context(), &course)
 	if err != nil {
 		e.ResponseWithError(
 			w, r, http.StatusBadRequest, err)
@@ -157,6 +126,8 @@ func CoursesCreateHandler(w http.ResponseWriter, r *http.Request,
 }
 
 // Courses PUT logic.
+// Course id is taken from path: /api/courses/{id},
+// deprecated route without it takes id from body.
 // Expected header:
 // Authorization : Bearer <access token>
 // Course update allowed only to teachers and admins.
@@ -169,30 +140,27 @@ func CoursesCreateHandler(w http.ResponseWriter, r *http.Request,
 // markdown : markdown text of course;
 // dep_id : id of course department.
 // Response codes:
-// 200, 400, 401, 403.
-func CoursesUpdateHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
+// 200, 400, 401, 403, 422.
+func (h *Handler) CoursesUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	var course models.Course
+	if err := decodeBody(w, r, &course); err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusForbidden, e.ErrAccessDenied)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	bytes := make([]byte, r.ContentLength)
-	r.Body.Read(bytes)
-
-	var course models.Course
-
-	if err := json.Unmarshal(bytes, &course); err != nil {
-		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUnableToUnmarshalBody)
-		return
+	var err error
+	if r.PathValue("id") != "" {
+		if course.Id, err = pathId(r, "id"); err != nil {
+			e.ResponseWithError(w, r, http.StatusBadRequest, err)
+			return
+		}
 	}
 
-	access, err := course.CheckAccess(claims)
+	access, err := h.courseAccess(r.Context(), course.Id)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrCourseNotFound)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 	if access != 2 {
@@ -201,7 +169,7 @@ func CoursesUpdateHandler(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	if err := course.Update(); err != nil {
+	if err := h.Courses.Update(r.Context(), &course); err != nil {
 		e.ResponseWithError(
 			w, r, http.StatusBadRequest, err)
 		return
@@ -1,79 +1,79 @@
 package service
 
 import (
-	"VEEEKTOR_api/internal/models"
 	e "VEEEKTOR_api/pkg/errors"
 	"encoding/json"
 	"net/http"
-	"strconv"
 )
 
-func GetDepartmentsHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		DepartmentsGetHandler(w, r)
-	default:
-		e.ResponseWithError(w, r, http.StatusMethodNotAllowed,
-			e.ErrOnlyGetAllowed)
+// Departments GET logic.
+// Response: Error message or departments:
+// id : id of department;
+// name : name of department;
+// env_id : id of department educational environment.
+// Response codes:
+// 200, 404.
+func (h *Handler) DepartmentsGetHandler(w http.ResponseWriter, r *http.Request) {
+	deps, err := h.Deps.GetAll(r.Context())
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusNotFound, err)
+		return
 	}
+
+	jsonBytes, _ := json.Marshal(deps)
+	w.Write(jsonBytes)
 }
 
-// Departments GET logic.
-// Departments can be get by id or env_id in url values;
-// Response: Error message or department(s):
+// Department GET logic.
+// Department id is taken from path: /api/departments/{id}.
+// Response: Error message or department:
 // id : id of department;
 // name : name of department;
 // env_id : id of department educational environment.
 // Response codes:
 // 200, 400, 404.
-func DepartmentsGetHandler(w http.ResponseWriter, r *http.Request) {
-	var jsonBytes []byte
-
-	rawQuery := r.URL.Query()
-	if rawQuery.Has("id") {
-		depId, err := strconv.Atoi(rawQuery.Get("id"))
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
-			return
-		}
-
-		dep, err := models.GetDepartmentById(depId)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, err)
-			return
-		}
-
-		jsonBytes, _ = json.Marshal(dep)
-	} else if rawQuery.Has("env_id") {
-		envId, err := strconv.Atoi(rawQuery.Get("env_id"))
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
-			return
-		}
+func (h *Handler) DepartmentGetHandler(w http.ResponseWriter, r *http.Request) {
+	depId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
 
-		deps, err := models.GetAllDepartmentsByEnvironmentId(envId)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, e.ErrDepsNotFound)
-			return
-		}
+	dep, err := h.Deps.GetById(r.Context(), depId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusNotFound, err)
+		return
+	}
 
-		jsonBytes, _ = json.Marshal(deps)
+	jsonBytes, _ := json.Marshal(dep)
+	w.Write(jsonBytes)
+}
 
-	} else {
-		deps, err := models.GetAllDepartments()
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, e.ErrDepsNotFound)
-			return
-		}
+// Educational environment departments GET logic.
+// Environment id is taken from path:
+// /api/educational_envs/{id}/departments.
+// Response: Error message or departments:
+// id : id of department;
+// name : name of department;
+// env_id : id of department educational environment.
+// Response codes:
+// 200, 400, 404.
+func (h *Handler) EdEnvDepartmentsGetHandler(w http.ResponseWriter, r *http.Request) {
+	envId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
 
-		jsonBytes, _ = json.Marshal(deps)
+	deps, err := h.Deps.GetAllByEnvId(r.Context(), envId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusNotFound, err)
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	jsonBytes, _ := json.Marshal(deps)
 	w.Write(jsonBytes)
 }
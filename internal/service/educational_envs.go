@@ -1,61 +1,50 @@
 package service
 
 import (
-	"VEEEKTOR_api/internal/models"
 	e "VEEEKTOR_api/pkg/errors"
 	"encoding/json"
 	"net/http"
-	"strconv"
 )
 
-func GetEducatinalEnvironmentsHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		EducationalEnvsGetHandler(w, r)
-	default:
-		e.ResponseWithError(w, r, http.StatusMethodNotAllowed,
-			e.ErrOnlyGetAllowed)
+// Educational environments GET logic.
+// Response: Error message or educational environments:
+// id : id of educational env;
+// name : name of educational env.
+// Response codes:
+// 200, 404.
+func (h *Handler) EducationalEnvsGetHandler(w http.ResponseWriter, r *http.Request) {
+	envs, err := h.EdEnvs.GetAll(r.Context())
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusNotFound, err)
+		return
 	}
+
+	jsonBytes, _ := json.Marshal(envs)
+	w.Write(jsonBytes)
 }
 
-// Educational environments GET logic.
-// Environments can be get by id in url values or all together.
-// Response: Error message or educational environment(s):
+// Educational environment GET logic.
+// Environment id is taken from path: /api/educational_envs/{id}.
+// Response: Error message or educational environment:
 // id : id of educational env;
 // name : name of educational env.
 // Response codes:
 // 200, 400, 404.
-func EducationalEnvsGetHandler(w http.ResponseWriter, r *http.Request) {
-	var jsonBytes []byte
-
-	rawQuery := r.URL.Query()
-	if rawQuery.Has("id") {
-		envId, err := strconv.Atoi(rawQuery.Get("id"))
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
-			return
-		}
-
-		env, err := models.GetEducationalEnvironmentById(envId)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, e.ErrEdEnvNotFound)
-			return
-		}
-
-		jsonBytes, _ = json.Marshal(env)
-	} else {
-		envs, err := models.GetAllEducationalEnvs()
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, e.ErrEdEnvsNotFound)
-			return
-		}
+func (h *Handler) EducationalEnvGetHandler(w http.ResponseWriter, r *http.Request) {
+	envId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
 
-		jsonBytes, _ = json.Marshal(envs)
+	env, err := h.EdEnvs.GetById(r.Context(), envId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusNotFound, err)
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	jsonBytes, _ := json.Marshal(env)
 	w.Write(jsonBytes)
 }
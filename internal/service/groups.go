@@ -1,76 +1,61 @@
 package service
 
 import (
-	auth "VEEEKTOR_api/internal/auth"
 	"VEEEKTOR_api/internal/models"
 	e "VEEEKTOR_api/pkg/errors"
 	"encoding/json"
 	"net/http"
-	"strconv"
 )
 
-func GetGroupsHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		GroupsGetHandler(w, r)
-	default:
-		e.ResponseWithError(w, r, http.StatusMethodNotAllowed,
-			e.ErrMethodNotAllowed)
-	}
-}
-
-// Groups GET logic.
-// Groups can be get via group id or department id.
-// Url values should contain ?id=<group_id> or ?dep_id=<department_id>
+// Group GET logic.
+// Group id is taken from path: /api/groups/{id}.
 // Response: Error message or group:
-// id : group id
-// name : group name like O722B
-// dep_id : group department id
+// id : group id;
+// name : group name like O722B;
+// dep_id : group department id.
 // Response codes:
 // 200, 400, 404.
-func GroupsGetHandler(w http.ResponseWriter, r *http.Request) {
-	var jsonBytes []byte
-
-	rawQuery := r.URL.Query()
-	if rawQuery.Has("id") {
-		groupId, err := strconv.Atoi(rawQuery.Get("id"))
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
-			return
-		}
-
-		group, err := models.GetGroupById(groupId)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, err)
-			return
-		}
+func (h *Handler) GroupGetHandler(w http.ResponseWriter, r *http.Request) {
+	groupId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
 
-		jsonBytes, _ = json.Marshal(group)
+	group, err := h.Groups.GetById(r.Context(), groupId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusNotFound, err)
+		return
+	}
 
-	} else if rawQuery.Has("dep_id") {
-		depId, err := strconv.Atoi(rawQuery.Get("dep_id"))
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
-			return
-		}
+	jsonBytes, _ := json.Marshal(group)
+	w.Write(jsonBytes)
+}
 
-		groups, err := models.GetAllGroupsByDepId(depId)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, e.ErrGroupsNotFound)
-			return
-		}
+// Department groups GET logic.
+// Department id is taken from path: /api/departments/{id}/groups.
+// Response: Error message or groups:
+// id : group id;
+// name : group name like O722B;
+// dep_id : group department id.
+// Response codes:
+// 200, 400, 404.
+func (h *Handler) DepartmentGroupsGetHandler(w http.ResponseWriter, r *http.Request) {
+	depId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
 
-		jsonBytes, _ = json.Marshal(groups)
-	} else {
+	groups, err := h.Groups.GetAllByDepId(r.Context(), depId)
+	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUrlValueMissing)
+			w, r, http.StatusNotFound, err)
 		return
 	}
 
+	jsonBytes, _ := json.Marshal(groups)
 	w.Write(jsonBytes)
 }
 
@@ -83,50 +68,19 @@ func GroupsGetHandler(w http.ResponseWriter, r *http.Request) {
 // group_id : id of group;
 // course_id : id of course.
 // Response codes:
-// 200, 400, 401, 403.
-func LinkGroupWithCourse(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		e.ResponseWithError(
-			w, r, http.StatusMethodNotAllowed, e.ErrOnlyPostAllowed)
-		return
-	}
-
-	token, err := auth.GetAccessTokenFromHeader(r)
-	if err != nil {
-		e.ResponseWithError(w, r, http.StatusBadRequest, err)
-		return
-	}
-
-	claims, err := auth.GetTokenClaims(token)
-	if err == e.ErrTokenExpired {
-		e.ResponseWithError(w, r, http.StatusUnauthorized, e.ErrTokenExpired)
-		return
-	} else if err != nil {
-		e.ResponseWithError(w, r, http.StatusBadRequest, err)
-		return
-	}
-
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
-		e.ResponseWithError(w, r, http.StatusForbidden, e.ErrAccessDenied)
-		return
-	}
-
-	bytes := make([]byte, r.ContentLength)
-	r.Body.Read(bytes)
-
+// 200, 400, 401, 403, 422.
+func (h *Handler) LinkGroupWithCourse(w http.ResponseWriter, r *http.Request) {
 	var gc models.GroupCourse
-	if err := json.Unmarshal(bytes, &gc); err != nil {
+	if err := decodeBody(w, r, &gc); err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUnableToUnmarshalBody)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	var course models.Course
-	course.Id = gc.CourseId
-	access, err := course.CheckAccess(claims)
+	access, err := h.courseAccess(r.Context(), gc.CourseId)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrCourseNotFound)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 	if access != 2 {
@@ -135,7 +89,7 @@ func LinkGroupWithCourse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = gc.Insert(); err != nil {
+	if err = h.Groups.LinkCourse(r.Context(), &gc); err != nil {
 		e.ResponseWithError(
 			w, r, http.StatusBadRequest, err)
 		return
@@ -154,50 +108,18 @@ func LinkGroupWithCourse(w http.ResponseWriter, r *http.Request) {
 // course_id : id of course.
 // Response codes:
 // 200, 400, 401, 403.
-func UnlinkGroupFromCourse(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		e.ResponseWithError(
-			w, r, http.StatusMethodNotAllowed, e.ErrOnlyPostAllowed)
-		return
-	}
-
-	token, err := auth.GetAccessTokenFromHeader(r)
-	if err != nil {
-		e.ResponseWithError(w, r, http.StatusBadRequest, err)
-		return
-	}
-
-	claims, err := auth.GetTokenClaims(token)
-	if err == e.ErrTokenExpired {
-		e.ResponseWithError(w, r, http.StatusUnauthorized, e.ErrTokenExpired)
-		return
-	} else if err != nil {
-		e.ResponseWithError(w, r, http.StatusBadRequest, err)
-		return
-	}
-
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
-		e.ResponseWithError(w, r, http.StatusForbidden, e.ErrAccessDenied)
-		return
-	}
-
-	bytes := make([]byte, r.ContentLength)
-	r.Body.Read(bytes)
-
+func (h *Handler) UnlinkGroupFromCourse(w http.ResponseWriter, r *http.Request) {
 	var gc models.GroupCourse
-
-	if err := json.Unmarshal(bytes, &gc); err != nil {
+	if err := decodeBody(w, r, &gc); err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUnableToUnmarshalBody)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	var course models.Course
-	course.Id = gc.CourseId
-	access, err := course.CheckAccess(claims)
+	access, err := h.courseAccess(r.Context(), gc.CourseId)
 	if err != nil {
 		e.ResponseWithError(w, r, http.StatusBadRequest,
-			e.ErrCourseNotFound)
+			err)
 		return
 	}
 	if access != 2 {
@@ -206,7 +128,7 @@ func UnlinkGroupFromCourse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = gc.Delete(); err != nil {
+	if err = h.Groups.UnlinkCourse(r.Context(), &gc); err != nil {
 		e.ResponseWithError(
 			w, r, http.StatusBadRequest, err)
 		return
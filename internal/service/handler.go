@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/config"
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+	"VEEEKTOR_api/pkg/mail"
+	"VEEEKTOR_api/pkg/ratelimit"
+)
+
+// Handler holds storages used by HTTP handlers,
+// so they can be served over Postgres or in-memory repositories.
+type Handler struct {
+	Auth    *auth.Manager
+	Login   *auth.LoginGuard
+	Users   models.UserRepository
+	Courses models.CourseRepository
+	Groups  models.GroupRepository
+	Deps    models.DepartmentRepository
+	EdEnvs  models.EducationalEnvRepository
+	Infos   models.NestedInfoRepository
+	Labs    models.NestedLabRepository
+	Tests   models.NestedTestRepository
+	// Refresh token cookie flags
+	Cookie config.Cookie
+	// Letters with verification and password reset links
+	Mailer mail.Mailer
+	// Web client address links in letters lead to
+	LinkBaseURL string
+	// Users with unverified email can not sign in
+	RequireVerifiedEmail bool
+	// Buckets of RateLimit, nil - requests are not limited
+	RateLimits ratelimit.Store
+	// Single sign-on, nil - disabled
+	OIDC *OIDC
+
+	// Letters sent in background
+	letters sync.WaitGroup
+}
+
+func NewHandler(sessions auth.SessionRepository,
+	tokens auth.UserTokenRepository,
+	attempts auth.LoginAttemptRepository,
+	mfa auth.MFARepository,
+	users models.UserRepository,
+	courses models.CourseRepository,
+	groups models.GroupRepository,
+	deps models.DepartmentRepository,
+	edEnvs models.EducationalEnvRepository,
+	infos models.NestedInfoRepository,
+	labs models.NestedLabRepository,
+	tests models.NestedTestRepository) *Handler {
+	return &Handler{
+		Auth:                 auth.NewManager(sessions, tokens, mfa, users),
+		Login:                auth.NewLoginGuard(attempts),
+		Users:                users,
+		Courses:              courses,
+		Groups:               groups,
+		Deps:                 deps,
+		EdEnvs:               edEnvs,
+		Infos:                infos,
+		Labs:                 labs,
+		Tests:                tests,
+		Cookie:               config.Default().Cookie,
+		Mailer:               mail.NewFileMailer("", config.Default().Mail.From),
+		RequireVerifiedEmail: config.Default().Auth.RequireVerifiedEmail,
+		RateLimits:           ratelimit.NewMemoryStore(),
+	}
+}
+
+// Access of principal from ctx to course.
+// User have: 0 - no access, 1 - read access, 2 - write access
+// Errors: ErrCourseNotFound
+func (h *Handler) courseAccess(ctx context.Context,
+	courseId int) (int, error) {
+	p, _ := auth.PrincipalFromContext(ctx)
+	return h.Courses.CheckAccess(ctx, courseId, p.UserId, p.GroupId)
+}
+
+// Course content is changed only with write access to course it
+// belongs to and, when it is moved, to target course.
+// Errors: ErrCourseNotFound
+func (h *Handler) canWriteCourses(ctx context.Context,
+	courseIds ...int) (bool, error) {
+	for _, courseId := range courseIds {
+		access, err := h.courseAccess(ctx, courseId)
+		if err != nil {
+			return false, err
+		}
+		if access != 2 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// JSON request bodies are small, larger ones are not read.
+const maxBodySize = 1 << 20
+
+// Decodes JSON body into dst. Body is read up to maxBodySize whatever
+// Content-Length says, so chunked and partially received bodies
+// are read in full.
+// Errors: ErrUnableToUnmarshalBody
+func decodeBody(w http.ResponseWriter, r *http.Request, dst any) error {
+	if err := json.NewDecoder(http.MaxBytesReader(
+		w, r.Body, maxBodySize)).Decode(dst); err != nil {
+		return e.ErrUnableToUnmarshalBody
+	}
+
+	return nil
+}
+
+// Id from path wildcard, e.g. {id} of /api/labs/{id}.
+// Errors: ErrUrlValueMissing, ErrUrlValueNotValid
+func pathId(r *http.Request, name string) (int, error) {
+	value := r.PathValue(name)
+	if value == "" {
+		return 0, e.ErrUrlValueMissing
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, e.ErrUrlValueNotValid
+	}
+
+	return id, nil
+}
+
+func (h *Handler) setRefreshTokenCookie(w http.ResponseWriter, value string) {
+	sameSite := http.SameSiteStrictMode
+	switch strings.ToLower(h.Cookie.SameSite) {
+	case "lax":
+		sameSite = http.SameSiteLaxMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:  "refresh_token",
+		Value: value,
+		Path:  "/",
+		// Secure cookie is not set over plain http
+		Secure:   h.Cookie.Secure,
+		Domain:   h.Cookie.Domain,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
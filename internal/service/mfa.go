@@ -0,0 +1,404 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+// Second sign in step logic.
+// Challenge is returned by sign in, it can be used once, so wrong
+// code requires sign in with password again. Wrong codes are counted
+// as failed sign in attempts. Enrollment started with
+// /users/signin/mfa/enroll is confirmed by this step.
+// Expected body:
+// mfa_token : challenge token;
+// code : code of authenticator app or recovery code.
+// Response:
+// Error message or token pair (see sign in):
+// recovery_codes : single use codes, only when enrollment is confirmed.
+// Cookie:
+// refresh_token : <rt>.
+// Response codes:
+// 200, 400, 401, 403, 404, 422, 429, 500.
+func (h *Handler) UsersSignInMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var inp models.MFASignInInput
+	if err := decodeBody(w, r, &inp); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	user, ok := h.mfaChallengeUser(w, r, inp.Token)
+	if !ok {
+		return
+	}
+
+	// Not confirmed enrollment is confirmed by the first code
+	var resp auth.MFASignInResponse
+	if !h.checkMFACode(w, r, user.Email, http.StatusUnauthorized,
+		func() error {
+			mfa, err := h.Auth.MFA.Get(r.Context(), user.Id)
+			if err == nil && !mfa.Enabled {
+				resp.RecoveryCodes, err = h.Auth.ConfirmMFA(
+					r.Context(), user.Id, inp.Code)
+			} else if err == nil {
+				err = h.Auth.CheckMFA(r.Context(), user.Id, inp.Code)
+			}
+			return err
+		}) {
+		return
+	}
+
+	tokens, err := h.startSession(r, user)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	resp.TokenResponse = tokens
+
+	jsonBytes, _ := json.Marshal(resp)
+	h.setRefreshTokenCookie(w, resp.RefreshToken)
+	w.Write(jsonBytes)
+}
+
+// Sign in enrollment logic.
+// Used when role of user requires two-factor authentication and
+// user has not enrolled yet, so challenge is returned with enroll set.
+// Expected body:
+// mfa_token : challenge token.
+// Response:
+// Error message or enrollment:
+// secret : base32 secret for manual entry;
+// provisioning_uri : otpauth:// URI shown as QR code;
+// mfa_token : new challenge to confirm enrollment with
+// /users/signin/mfa.
+// Response codes:
+// 200, 400, 403, 404, 409, 422, 500.
+func (h *Handler) UsersSignInMFAEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	var inp models.MFATokenInput
+	if err := decodeBody(w, r, &inp); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	user, ok := h.mfaChallengeUser(w, r, inp.Token)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.Auth.BeginMFA(r.Context(), user)
+	if err != nil {
+		e.ResponseWithError(w, r, mfaStatus(err), err)
+		return
+	}
+
+	if enrollment.Token, err = h.Auth.IssueUserToken(r.Context(), user.Id,
+		auth.PurposeMFAChallenge, auth.MFAChallengeLifeTime); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	jsonBytes, _ := json.Marshal(enrollment)
+	w.Write(jsonBytes)
+}
+
+// Two-factor authentication status GET logic.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Response:
+// Error message or status:
+// enabled : user has confirmed enrollment;
+// required : role of user requires two-factor authentication.
+// Response codes:
+// 200, 400, 401, 404, 500.
+func (h *Handler) UsersMFAGetHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := h.Users.GetById(r.Context(), principal(r).UserId)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	status, err := h.Auth.NeedsMFA(r.Context(), user)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	jsonBytes, _ := json.Marshal(status)
+	w.Write(jsonBytes)
+}
+
+// Two-factor authentication enrollment logic.
+// Secret starts working after confirmation, repeated request
+// replaces not confirmed secret.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Response:
+// Error message or enrollment:
+// secret : base32 secret for manual entry;
+// provisioning_uri : otpauth:// URI shown as QR code.
+// Response codes:
+// 200, 400, 401, 404, 409, 500.
+func (h *Handler) UsersMFAEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := h.Users.GetById(r.Context(), principal(r).UserId)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	enrollment, err := h.Auth.BeginMFA(r.Context(), user)
+	if err != nil {
+		e.ResponseWithError(w, r, mfaStatus(err), err)
+		return
+	}
+
+	jsonBytes, _ := json.Marshal(enrollment)
+	w.Write(jsonBytes)
+}
+
+// Two-factor authentication confirmation logic.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Expected body:
+// code : code of authenticator app.
+// Response:
+// Error message or recovery codes, they are shown once:
+// recovery_codes : single use codes replacing app code.
+// Wrong codes are counted as failed sign in attempts.
+// Response codes:
+// 200, 400, 401, 403, 404, 409, 422, 429, 500.
+func (h *Handler) UsersMFAConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	var inp models.MFACodeInput
+	if err := decodeBody(w, r, &inp); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	user, err := h.Users.GetById(r.Context(), principal(r).UserId)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	var codes []string
+	if !h.checkMFACode(w, r, user.Email, http.StatusForbidden,
+		func() (err error) {
+			codes, err = h.Auth.ConfirmMFA(r.Context(), user.Id, inp.Code)
+			return err
+		}) {
+		return
+	}
+
+	jsonBytes, _ := json.Marshal(auth.RecoveryCodes{Codes: codes})
+	w.Write(jsonBytes)
+}
+
+// Two-factor authentication disabling logic.
+// Not allowed when role of user requires it.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Expected body:
+// code : code of authenticator app or recovery code.
+// Response: Error message or StatusOk.
+// Wrong codes are counted as failed sign in attempts.
+// Response codes:
+// 200, 400, 401, 403, 404, 422, 429, 500.
+func (h *Handler) UsersMFADeleteHandler(w http.ResponseWriter, r *http.Request) {
+	var inp models.MFACodeInput
+	if err := decodeBody(w, r, &inp); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	user, err := h.Users.GetById(r.Context(), principal(r).UserId)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	status, err := h.Auth.NeedsMFA(r.Context(), user)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if status.Required {
+		e.ResponseWithError(w, r, http.StatusForbidden, e.ErrMFARequired)
+		return
+	}
+
+	if !h.checkMFACode(w, r, user.Email, http.StatusForbidden,
+		func() error {
+			return h.Auth.CheckMFA(r.Context(), user.Id, inp.Code)
+		}) {
+		return
+	}
+
+	if err = h.Auth.MFA.Delete(r.Context(), user.Id); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Admin role two-factor requirement logic.
+// Users of role without enrollment are asked to enroll on next
+// sign in, issued tokens are not affected.
+// Role id is taken from path: /api/admin/roles/{id}/mfa.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Expected body:
+// required : true to require two-factor authentication.
+// Response: Error message or StatusOk.
+// Response codes:
+// 200, 400, 401, 403, 404.
+func (h *Handler) AdminRoleMFAHandler(w http.ResponseWriter, r *http.Request) {
+	roleId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var inp models.RoleMFAInput
+	if err = decodeBody(w, r, &inp); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = h.Auth.MFA.SetRoleRequired(
+		r.Context(), roleId, inp.Required); err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
+		return
+	}
+}
+
+// Admin two-factor reset logic.
+// Deletes enrollment of user who lost authenticator app
+// and recovery codes.
+// User id is taken from path: /api/admin/users/{id}/mfa.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Response: Error message or StatusOk.
+// Response codes:
+// 200, 400, 401, 403, 404, 500.
+func (h *Handler) AdminUserMFADeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err = h.Users.GetById(r.Context(), userId); err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	if err = h.Auth.MFA.Delete(r.Context(), userId); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	log.Printf("two-factor authentication of user %d reset by user %d",
+		userId, principal(r).UserId)
+}
+
+// Consumes challenge and returns its active owner,
+// writes error response otherwise.
+func (h *Handler) mfaChallengeUser(w http.ResponseWriter, r *http.Request,
+	token string) (models.User, bool) {
+	userId, err := h.Auth.ConsumeUserToken(
+		r.Context(), auth.PurposeMFAChallenge, token)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return models.User{}, false
+	}
+
+	user, err := h.Users.GetById(r.Context(), userId)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
+		return models.User{}, false
+	}
+	if !user.Active {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrUserDeactivated)
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+// Runs check of code of user, wrong codes are counted as failed sign
+// in attempts, so codes can't be guessed endlessly. Writes error
+// response, invalid status for wrong code, and returns false on failure.
+func (h *Handler) checkMFACode(w http.ResponseWriter, r *http.Request,
+	email string, invalid int, check func() error) bool {
+	attempt, err := h.Login.Reserve(
+		r.Context(), email, auth.GetClientInfo(r).Ip)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return false
+	} else if attempt.Wait > 0 {
+		w.Header().Set("Retry-After", ceilSeconds(attempt.Wait))
+		e.ResponseWithError(
+			w, r, http.StatusTooManyRequests, e.ErrTooManyAttempts)
+		return false
+	}
+
+	err = check()
+	if errors.Is(err, e.ErrMFACodeNotValid) {
+		if err = h.Login.Failed(r.Context(), attempt); err != nil {
+			e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+			return false
+		}
+		e.ResponseWithError(w, r, invalid, e.ErrMFACodeNotValid)
+		return false
+	}
+
+	// Only wrong codes are counted
+	if rerr := h.Login.Release(r.Context(), attempt); rerr != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, rerr)
+		return false
+	}
+	if err != nil {
+		e.ResponseWithError(w, r, mfaStatus(err), err)
+		return false
+	}
+
+	return true
+}
+
+// Status of two-factor errors of auth manager.
+func mfaStatus(err error) int {
+	switch {
+	case errors.Is(err, e.ErrMFANotEnrolled):
+		return http.StatusNotFound
+	case errors.Is(err, e.ErrMFAAlreadyEnabled):
+		return http.StatusConflict
+	case errors.Is(err, e.ErrMFACodeNotValid):
+		return http.StatusForbidden
+	}
+
+	return http.StatusInternalServerError
+}
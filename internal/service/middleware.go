@@ -0,0 +1,61 @@
+package service
+
+import (
+	"net/http"
+
+	"VEEEKTOR_api/internal/auth"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+type Middleware func(http.Handler) http.Handler
+
+// Checks access token once and puts its owner into request context.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Response codes:
+// 400 - token is missing or not valid, 401 - token expired.
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetAccessTokenFromHeader(r)
+		if err != nil {
+			e.ResponseWithError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		p, err := auth.ParsePrincipal(token)
+		if err == e.ErrTokenExpired {
+			e.ResponseWithError(w, r, http.StatusUnauthorized, err)
+			return
+		} else if err != nil {
+			e.ResponseWithError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	})
+}
+
+// Lets through only principals with one of roles,
+// must be used after Authenticate.
+// Response codes:
+// 403.
+func RequireRole(roles ...int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := auth.PrincipalFromContext(r.Context())
+			if !ok || !p.HasRole(roles...) {
+				e.ResponseWithError(
+					w, r, http.StatusForbidden, e.ErrAccessDenied)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Principal put by Authenticate.
+func principal(r *http.Request) auth.Principal {
+	p, _ := auth.PrincipalFromContext(r.Context())
+	return p
+}
@@ -1,128 +1,96 @@
 package service
 
 import (
-	auth "VEEEKTOR_api/internal/auth"
 	"VEEEKTOR_api/internal/models"
 	e "VEEEKTOR_api/pkg/errors"
 	"encoding/json"
 	"net/http"
-	"strconv"
-
-	"github.com/golang-jwt/jwt"
 )
 
-func GetNestedInfosHandler(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetAccessTokenFromHeader(r)
+// Nested info GET logic.
+// Info id is taken from path: /api/infos/{id}.
+// Info pages can only be accessible for users that belongs to info page course.
+// Expected header:
+// Authorization : Bearer <access token>
+// Response: Error message or info page:
+// id : id of info page;
+// course_id : id of info page course;
+// markdown : markdown of info page.
+// Response codes:
+// 200, 400, 401, 403, 404.
+func (h *Handler) NestedInfoGetHandler(w http.ResponseWriter, r *http.Request) {
+	infoId, err := pathId(r, "id")
 	if err != nil {
 		e.ResponseWithError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	claims, err := auth.GetTokenClaims(token)
-	if err == e.ErrTokenExpired {
-		e.ResponseWithError(w, r, http.StatusUnauthorized, e.ErrTokenExpired)
-		return
-	} else if err != nil {
-		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+	info, err := h.Infos.GetById(r.Context(), infoId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusNotFound, err)
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		NestedInfosGetHandler(w, r, token, claims)
-	case http.MethodPost:
-		NestedInfosCreateHandler(w, r, token, claims)
-	case http.MethodPut:
-		NestedInfosUpdateHandler(w, r, token, claims)
-	case http.MethodDelete:
-		NestedInfosDeleteHandler(w, r, token, claims)
-	default:
-		e.ResponseWithError(w, r, http.StatusMethodNotAllowed,
-			e.ErrMethodNotAllowed)
+	access, err := h.courseAccess(r.Context(), info.CourseId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusBadRequest, err)
+		return
 	}
+	if access == 0 {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrAccessDenied)
+		return
+	}
+
+	jsonBytes, _ := json.Marshal(info)
+	w.Write(jsonBytes)
 }
 
 // Nested infos GET logic.
-// Url values should contain ?id=<info_id> or ?id=<course_id>.
+// Course id is taken from path: /api/courses/{id}/infos.
 // Info pages can only be accessible for users that belongs to info page course.
 // Expected header:
 // Authorization : Bearer <access token>
-// Response: Error message or info page(s) by info id (course id):
+// Response: Error message or info pages of course:
 // id : id of info page;
-// course_id : id of info page course;
-// markdown : markdown of info page (only for get by id);
+// course_id : id of info page course.
 // Response codes:
 // 200, 400, 401, 403, 404.
-func NestedInfosGetHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	var jsonBytes []byte
-
-	rawQuery := r.URL.Query()
-	if rawQuery.Has("id") {
-		infoId, err := strconv.Atoi(rawQuery.Get("id"))
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
-			return
-		}
-
-		info, err := models.GetNestedInfoById(infoId)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, e.ErrNestedInfoNotFound)
-			return
-		}
-
-		access, _ := info.CheckAccess(claims)
-		if access == 0 {
-			e.ResponseWithError(
-				w, r, http.StatusForbidden, e.ErrAccessDenied)
-			return
-		}
-
-		jsonBytes, _ = json.Marshal(info)
-
-	} else if rawQuery.Has("course_id") {
-		courseId, err := strconv.Atoi(rawQuery.Get("course_id"))
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
-			return
-		}
-
-		var course models.Course
-		course.Id = courseId
-		access, err := course.CheckAccess(claims)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrCourseNotFound)
-			return
-		}
-		if access == 0 {
-			e.ResponseWithError(
-				w, r, http.StatusForbidden, e.ErrUserNotBelongToCourse)
-			return
-		}
-
-		infos, err := models.GetNestedInfosByCourseId(courseId)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, e.ErrNestedInfosNotFound)
-			return
-		}
+func (h *Handler) NestedInfosGetHandler(w http.ResponseWriter, r *http.Request) {
+	courseId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
 
-		jsonBytes, _ = json.Marshal(infos)
+	access, err := h.courseAccess(r.Context(), courseId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusBadRequest, err)
+		return
+	}
+	if access == 0 {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrUserNotBelongToCourse)
+		return
+	}
 
-	} else {
+	infos, err := h.Infos.GetAllByCourseId(r.Context(), courseId)
+	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUrlValueMissing)
+			w, r, http.StatusNotFound, err)
 		return
 	}
 
+	jsonBytes, _ := json.Marshal(infos)
 	w.Write(jsonBytes)
 }
 
 // Course infos POST logic.
+// Course id is taken from path: /api/courses/{id}/infos,
+// deprecated route without it takes course_id from body.
 // Expected header:
 // Authorization : Bearer <access token>
 // This method allowed only to teachers, who belongs to course or admins.
@@ -131,30 +99,27 @@ func NestedInfosGetHandler(w http.ResponseWriter, r *http.Request,
 // course_id : id of course;
 // markdown : markdown text of info page.
 // Response codes:
-// 200, 400, 401, 403.
-func NestedInfosCreateHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
+// 200, 400, 401, 403, 422.
+func (h *Handler) NestedInfosCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var info models.NestedInfo
+	if err := decodeBody(w, r, &info); err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusForbidden, e.ErrAccessDenied)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	bytes := make([]byte, r.ContentLength)
-	r.Body.Read(bytes)
-
-	var info models.NestedInfo
-
-	if err := json.Unmarshal(bytes, &info); err != nil {
-		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUnableToUnmarshalBody)
-		return
+	var err error
+	if r.PathValue("id") != "" {
+		if info.CourseId, err = pathId(r, "id"); err != nil {
+			e.ResponseWithError(w, r, http.StatusBadRequest, err)
+			return
+		}
 	}
 
-	access, err := info.CheckAccess(claims)
+	access, err := h.courseAccess(r.Context(), info.CourseId)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrCourseNotFound)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 	if access != 2 {
@@ -163,7 +128,7 @@ func NestedInfosCreateHandler(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	if err := info.Insert(); err != nil {
+	if err := h.Infos.Insert(r.Context(), &info); err != nil {
 		e.ResponseWithError(
 			w, r, http.StatusBadRequest, err)
 		return
@@ -173,9 +138,12 @@ func NestedInfosCreateHandler(w http.ResponseWriter, r *http.Request,
 }
 
 // Nested infos PUT logic.
+// Info id is taken from path: /api/infos/{id},
+// deprecated route without it takes id from body.
 // Expected header:
 // Authorization : Bearer <access token>
 // This method allowed only to teachers, who belongs to course or admins.
+// Moving to another course needs access to both courses.
 // Response: Error message or StatusOk:
 // Expected body:
 // id : nested info page id;
@@ -183,40 +151,45 @@ func NestedInfosCreateHandler(w http.ResponseWriter, r *http.Request,
 // name : nested info page name;
 // markdown : markdown of nested info page.
 // Response codes:
-// 200, 400, 401, 403.
-func NestedInfosUpdateHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
+// 200, 400, 401, 403, 404, 422.
+func (h *Handler) NestedInfosUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	var info models.NestedInfo
+	if err := decodeBody(w, r, &info); err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusForbidden, e.ErrAccessDenied)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	bytes := make([]byte, r.ContentLength)
-	r.Body.Read(bytes)
-
-	var info models.NestedInfo
+	var err error
+	if r.PathValue("id") != "" {
+		if info.Id, err = pathId(r, "id"); err != nil {
+			e.ResponseWithError(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
 
-	if err := json.Unmarshal(bytes, &info); err != nil {
+	stored, err := h.Infos.GetById(r.Context(), info.Id)
+	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUnableToUnmarshalBody)
+			w, r, http.StatusNotFound, err)
 		return
 	}
 
-	// NOT THE BEST CHECK
-	access, err := info.CheckAccess(claims)
+	// Stored course is checked, body one may belong to another teacher
+	allowed, err := h.canWriteCourses(r.Context(),
+		stored.CourseId, info.CourseId)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrCourseNotFound)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
-	if access != 2 {
+	if !allowed {
 		e.ResponseWithError(
 			w, r, http.StatusForbidden, e.ErrAccessDenied)
 		return
 	}
 
-	if err := info.Update(); err != nil {
+	if err := h.Infos.Update(r.Context(), &info); err != nil {
 		e.ResponseWithError(
 			w, r, http.StatusBadRequest, err)
 		return
@@ -226,49 +199,43 @@ func NestedInfosUpdateHandler(w http.ResponseWriter, r *http.Request,
 }
 
 // Nested infos DELETE logic.
-// URL values should contain ?id=<info_page_id>.
+// Info id is taken from path: /api/infos/{id}.
 // This method allowed only to admins and teachers, who belongs to course.
 // Expected header:
 // Authorization : Bearer <access token>.
 // Response: Error message or StatusOk:
 // Response codes:
 // 200, 400, 401, 403, 404.
-func NestedInfosDeleteHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
-		e.ResponseWithError(
-			w, r, http.StatusForbidden, e.ErrAccessDenied)
-		return
-	}
-
-	rawQuery := r.URL.Query()
-	if !rawQuery.Has("id") {
-		e.ResponseWithError(w, r, http.StatusBadRequest, e.ErrUrlValueMissing)
+func (h *Handler) NestedInfosDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	infoId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	infoId, err := strconv.Atoi(rawQuery.Get("id"))
+	info, err := h.Infos.GetById(r.Context(), infoId)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
+			w, r, http.StatusNotFound, err)
 		return
 	}
 
-	info, err := models.GetNestedInfoById(infoId)
+	access, err := h.courseAccess(r.Context(), info.CourseId)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusNotFound, e.ErrNestedInfoNotFound)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
-
-	access, _ := info.CheckAccess(claims)
 	if access != 2 {
 		e.ResponseWithError(
 			w, r, http.StatusForbidden, e.ErrAccessDenied)
 		return
 	}
 
-	_ = models.DeleteNestedInfoById(infoId)
+	if err = h.Infos.DeleteById(r.Context(), infoId); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
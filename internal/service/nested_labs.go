@@ -1,135 +1,105 @@
 package service
 
 import (
-	auth "VEEEKTOR_api/internal/auth"
 	"VEEEKTOR_api/internal/models"
 	e "VEEEKTOR_api/pkg/errors"
 	"encoding/json"
 	"net/http"
-	"strconv"
-
-	"github.com/golang-jwt/jwt"
 )
 
-func GetNestedLabsHandler(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetAccessTokenFromHeader(r)
+// Nested lab GET logic.
+// Lab id is taken from path: /api/labs/{id}.
+// Lab pages can only be accessible for users that belongs to course.
+// Expected header:
+// Authorization : Bearer <access token>
+// Response: Error message or lab page:
+// id : lab id;
+// course_id : course id;
+// opens : date, when lab opens in UTC;
+// closes : date, when lab closes in UTC;
+// topic : lab topic;
+// requirements : link to lab requirements;
+// example : link to lab example;
+// location_id : id of location;
+// attempts : number of attempts.
+// Response codes:
+// 200, 400, 401, 403, 404.
+func (h *Handler) NestedLabGetHandler(w http.ResponseWriter, r *http.Request) {
+	labId, err := pathId(r, "id")
 	if err != nil {
 		e.ResponseWithError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	claims, err := auth.GetTokenClaims(token)
-	if err == e.ErrTokenExpired {
-		e.ResponseWithError(w, r, http.StatusUnauthorized, e.ErrTokenExpired)
-		return
-	} else if err != nil {
-		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+	lab, err := h.Labs.GetById(r.Context(), labId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusNotFound, err)
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		NestedLabsGetHandler(w, r, token, claims)
-	case http.MethodPost:
-		NestedLabsCreateHandler(w, r, token, claims)
-	case http.MethodPut:
-		NestedLabsUpdateHandler(w, r, token, claims)
-	case http.MethodDelete:
-		NestedLabsDeleteHandler(w, r, token, claims)
-	default:
-		e.ResponseWithError(w, r, http.StatusMethodNotAllowed,
-			e.ErrMethodNotAllowed)
+	access, err := h.courseAccess(r.Context(), lab.CourseId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusBadRequest, err)
+		return
 	}
+	if access == 0 {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrAccessDenied)
+		return
+	}
+
+	jsonBytes, _ := json.Marshal(lab)
+	w.Write(jsonBytes)
 }
 
 // Nested labs GET logic.
+// Course id is taken from path: /api/courses/{id}/labs.
 // Lab pages can only be accessible for users that belongs to course.
-// Url values should contain ?id=<lab_id> or ?id=<course_id>.
 // Expected header:
 // Authorization : Bearer <access token>
-// Response: Error message or lab page(s) by lab id (course id):
+// Response: Error message or lab pages of course:
 // id : lab id;
 // course_id : course id;
 // opens : date, when lab opens in UTC;
 // closes : date, when lab closes in UTC;
-// topic : lab topic;
-// requirements : link to lab requirements (only with get by id);
-// example : link to lab example (only with get by id);
-// location_id : id of location (only with get by id);
-// attempts : number of attempts (only with get by id).
+// topic : lab topic.
 // Response codes:
 // 200, 400, 401, 403, 404.
-func NestedLabsGetHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	var jsonBytes []byte
-
-	rawQuery := r.URL.Query()
-	if rawQuery.Has("id") {
-		labId, err := strconv.Atoi(rawQuery.Get("id"))
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
-			return
-		}
-
-		lab, err := models.GetNestedLabById(labId)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, e.ErrNestedLabNotFound)
-			return
-		}
-
-		access, _ := lab.CheckAccess(claims)
-		if access == 0 {
-			e.ResponseWithError(
-				w, r, http.StatusForbidden, e.ErrAccessDenied)
-			return
-		}
-
-		jsonBytes, _ = json.Marshal(lab)
-
-	} else if rawQuery.Has("course_id") {
-		courseId, err := strconv.Atoi(rawQuery.Get("course_id"))
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
-			return
-		}
-
-		var course models.Course
-		course.Id = courseId
-
-		access, err := course.CheckAccess(claims)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrCourseNotFound)
-			return
-		}
-		if access == 0 {
-			e.ResponseWithError(
-				w, r, http.StatusForbidden, e.ErrUserNotBelongToCourse)
-			return
-		}
-
-		labs, err := models.GetNestedLabsByCourseId(courseId)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, e.ErrNestedLabsNotFound)
-			return
-		}
+func (h *Handler) NestedLabsGetHandler(w http.ResponseWriter, r *http.Request) {
+	courseId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
 
-		jsonBytes, _ = json.Marshal(labs)
+	access, err := h.courseAccess(r.Context(), courseId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusBadRequest, err)
+		return
+	}
+	if access == 0 {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrUserNotBelongToCourse)
+		return
+	}
 
-	} else {
+	labs, err := h.Labs.GetAllByCourseId(r.Context(), courseId)
+	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUrlValueMissing)
+			w, r, http.StatusNotFound, err)
 		return
 	}
 
+	jsonBytes, _ := json.Marshal(labs)
 	w.Write(jsonBytes)
 }
 
 // Nested labs POST logic.
+// Course id is taken from path: /api/courses/{id}/labs,
+// deprecated route without it takes course_id from body.
 // Expected header:
 // Authorization : Bearer <access token>
 // This method allowed only to teachers, who belongs to course or admins.
@@ -144,30 +114,27 @@ func NestedLabsGetHandler(w http.ResponseWriter, r *http.Request,
 // location_id : id of location;
 // attempts : number of attempts.
 // Response codes:
-// 200, 400, 401, 403.
-func NestedLabsCreateHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
+// 200, 400, 401, 403, 422.
+func (h *Handler) NestedLabsCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var lab models.NestedLab
+	if err := decodeBody(w, r, &lab); err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusForbidden, e.ErrAccessDenied)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	bytes := make([]byte, r.ContentLength)
-	r.Body.Read(bytes)
-
-	var lab models.NestedLab
-
-	if err := json.Unmarshal(bytes, &lab); err != nil {
-		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUnableToUnmarshalBody)
-		return
+	var err error
+	if r.PathValue("id") != "" {
+		if lab.CourseId, err = pathId(r, "id"); err != nil {
+			e.ResponseWithError(w, r, http.StatusBadRequest, err)
+			return
+		}
 	}
 
-	access, err := lab.CheckAccess(claims)
+	access, err := h.courseAccess(r.Context(), lab.CourseId)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrCourseNotFound)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 	if access != 2 {
@@ -176,7 +143,7 @@ func NestedLabsCreateHandler(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	if err := lab.Insert(); err != nil {
+	if err := h.Labs.Insert(r.Context(), &lab); err != nil {
 		e.ResponseWithError(
 			w, r, http.StatusBadRequest, err)
 		return
@@ -186,9 +153,12 @@ func NestedLabsCreateHandler(w http.ResponseWriter, r *http.Request,
 }
 
 // Nested labs PUT logic.
+// Lab id is taken from path: /api/labs/{id},
+// deprecated route without it takes id from body.
 // Expected header:
 // Authorization : Bearer <access token>
 // This method allowed only to teachers, who belongs to course or admins.
+// Moving to another course needs access to both courses.
 // Response: Error message or StatusOk:
 // Expected body:
 // id : lab id;
@@ -201,38 +171,45 @@ func NestedLabsCreateHandler(w http.ResponseWriter, r *http.Request,
 // location_id : id of location;
 // attempts : number of attempts.
 // Response codes:
-// 200, 400, 401, 403.
-func NestedLabsUpdateHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
+// 200, 400, 401, 403, 404, 422.
+func (h *Handler) NestedLabsUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	var lab models.NestedLab
+	if err := decodeBody(w, r, &lab); err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusForbidden, e.ErrAccessDenied)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	bytes := make([]byte, r.ContentLength)
-	r.Body.Read(bytes)
+	var err error
+	if r.PathValue("id") != "" {
+		if lab.Id, err = pathId(r, "id"); err != nil {
+			e.ResponseWithError(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
 
-	var lab models.NestedLab
-	if err := json.Unmarshal(bytes, &lab); err != nil {
+	stored, err := h.Labs.GetById(r.Context(), lab.Id)
+	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUnableToUnmarshalBody)
+			w, r, http.StatusNotFound, err)
 		return
 	}
 
-	access, err := lab.CheckAccess(claims)
+	// Stored course is checked, body one may belong to another teacher
+	allowed, err := h.canWriteCourses(r.Context(),
+		stored.CourseId, lab.CourseId)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrCourseNotFound)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
-	if access != 2 {
+	if !allowed {
 		e.ResponseWithError(
 			w, r, http.StatusForbidden, e.ErrUserNotBelongToCourse)
 		return
 	}
 
-	if err := lab.Update(); err != nil {
+	if err := h.Labs.Update(r.Context(), &lab); err != nil {
 		e.ResponseWithError(
 			w, r, http.StatusBadRequest, err)
 		return
@@ -242,49 +219,43 @@ func NestedLabsUpdateHandler(w http.ResponseWriter, r *http.Request,
 }
 
 // Nested labs DELETE logic.
-// URL values should contain ?id=<lab_id>
+// Lab id is taken from path: /api/labs/{id}.
 // Expected header:
 // Authorization : Bearer <access token>
 // This method allowed only to admins and teachers, who belongs to course.
 // Response: Error message or StatusOk:
 // Response codes:
 // 200, 400, 401, 403, 404, 500.
-func NestedLabsDeleteHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
-		e.ResponseWithError(
-			w, r, http.StatusForbidden, e.ErrAccessDenied)
-		return
-	}
-
-	rawQuery := r.URL.Query()
-	if !rawQuery.Has("id") {
-		e.ResponseWithError(w, r, http.StatusBadRequest, e.ErrUrlValueMissing)
+func (h *Handler) NestedLabsDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	labId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	labId, err := strconv.Atoi(rawQuery.Get("id"))
+	lab, err := h.Labs.GetById(r.Context(), labId)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
+			w, r, http.StatusNotFound, err)
 		return
 	}
 
-	lab, err := models.GetNestedLabById(labId)
+	access, err := h.courseAccess(r.Context(), lab.CourseId)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusNotFound, e.ErrNestedLabNotFound)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
-
-	access, _ := lab.CheckAccess(claims)
 	if access != 2 {
 		e.ResponseWithError(
 			w, r, http.StatusForbidden, e.ErrUserNotBelongToCourse)
 		return
 	}
 
-	_ = models.DeleteNestedLabById(labId)
+	if err = h.Labs.DeleteById(r.Context(), labId); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
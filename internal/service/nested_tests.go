@@ -1,136 +1,106 @@
 package service
 
 import (
-	auth "VEEEKTOR_api/internal/auth"
 	"VEEEKTOR_api/internal/models"
 	e "VEEEKTOR_api/pkg/errors"
 	"encoding/json"
 	"net/http"
-	"strconv"
-
-	"github.com/golang-jwt/jwt"
 )
 
-func GetNestedTestsHandler(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetAccessTokenFromHeader(r)
+// Nested test GET logic.
+// Test id is taken from path: /api/tests/{id}.
+// Test pages can only be accessible for users that belongs to course.
+// Expected header:
+// Authorization : Bearer <access token>
+// Response: Error message or test:
+// id : test id;
+// course_id : course id;
+// opens : date, when test opens in UTC;
+// closes : date, when test closes in UTC;
+// tasks_count : count of test tasks;
+// topic : test topic;
+// location_id : id of location;
+// attempts : number of attempts;
+// password : test password (optional);
+// time_limit : time limit duration.
+// Response codes:
+// 200, 400, 401, 403, 404.
+func (h *Handler) NestedTestGetHandler(w http.ResponseWriter, r *http.Request) {
+	testId, err := pathId(r, "id")
 	if err != nil {
 		e.ResponseWithError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	claims, err := auth.GetTokenClaims(token)
-	if err == e.ErrTokenExpired {
-		e.ResponseWithError(w, r, http.StatusUnauthorized, e.ErrTokenExpired)
-		return
-	} else if err != nil {
-		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+	test, err := h.Tests.GetById(r.Context(), testId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusNotFound, err)
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		NestedTestsGetHandler(w, r, token, claims)
-	case http.MethodPost:
-		NestedTestsCreateHandler(w, r, token, claims)
-	case http.MethodPut:
-		NestedTestsUpdateHandler(w, r, token, claims)
-	case http.MethodDelete:
-		NestedTestsDeleteHandler(w, r, token, claims)
-	default:
-		e.ResponseWithError(w, r, http.StatusMethodNotAllowed,
-			e.ErrMethodNotAllowed)
+	access, err := h.courseAccess(r.Context(), test.CourseId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusBadRequest, err)
+		return
+	}
+	if access == 0 {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrUserNotBelongToCourse)
+		return
 	}
+
+	jsonBytes, _ := json.Marshal(test)
+	w.Write(jsonBytes)
 }
 
 // Nested tests GET logic.
+// Course id is taken from path: /api/courses/{id}/tests.
 // Test pages can only be accessible for users that belongs to course.
-// Url values should contain ?id=<test_id> or ?id=<course_id>.
 // Expected header:
 // Authorization : Bearer <access token>
-// Response: Error message or test(s) by test id (course id):
+// Response: Error message or tests of course:
 // id : test id;
 // course_id : course id;
 // opens : date, when test opens in UTC;
 // closes : date, when test closes in UTC;
-// tasks_count : count of test tasks (only with get by id);
-// topic : test topic;
-// location_id : id of location (only with get by id);
-// attempts : number of attempts (only with get by id);
-// password : test password (optional) (only with get by id);
-// time_limit : time limit duration (only with get by id).
+// topic : test topic.
 // Response codes:
 // 200, 400, 401, 403, 404.
-func NestedTestsGetHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	var jsonBytes []byte
-
-	rawQuery := r.URL.Query()
-	if rawQuery.Has("id") {
-		testId, err := strconv.Atoi(rawQuery.Get("id"))
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
-			return
-		}
-
-		test, err := models.GetNestedTestById(testId)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, e.ErrNestedTestNotFound)
-			return
-		}
-
-		access, _ := test.CheckAccess(claims)
-		if access == 0 {
-			e.ResponseWithError(
-				w, r, http.StatusForbidden, e.ErrUserNotBelongToCourse)
-			return
-		}
-
-		jsonBytes, _ = json.Marshal(test)
-
-	} else if rawQuery.Has("course_id") {
-		courseId, err := strconv.Atoi(rawQuery.Get("course_id"))
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
-			return
-		}
-
-		var course models.Course
-		course.Id = courseId
-
-		access, err := course.CheckAccess(claims)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusBadRequest, e.ErrCourseNotFound)
-			return
-		}
-		if access == 0 {
-			e.ResponseWithError(
-				w, r, http.StatusForbidden, e.ErrUserNotBelongToCourse)
-			return
-		}
-
-		tests, err := models.GetNestedTestsByCourseId(courseId)
-		if err != nil {
-			e.ResponseWithError(
-				w, r, http.StatusNotFound, e.ErrNestedTestsNotFound)
-			return
-		}
+func (h *Handler) NestedTestsGetHandler(w http.ResponseWriter, r *http.Request) {
+	courseId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
 
-		jsonBytes, _ = json.Marshal(tests)
+	access, err := h.courseAccess(r.Context(), courseId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusBadRequest, err)
+		return
+	}
+	if access == 0 {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrUserNotBelongToCourse)
+		return
+	}
 
-	} else {
+	tests, err := h.Tests.GetAllByCourseId(r.Context(), courseId)
+	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUrlValueMissing)
+			w, r, http.StatusNotFound, err)
 		return
 	}
 
+	jsonBytes, _ := json.Marshal(tests)
 	w.Write(jsonBytes)
 }
 
 // Nested tests POST logic.
+// Course id is taken from path: /api/courses/{id}/tests,
+// deprecated route without it takes course_id from body.
 // Expected header:
 // Authorization : Bearer <access token>
 // This method allowed only to teachers, who belongs to course or admins.
@@ -146,15 +116,8 @@ func NestedTestsGetHandler(w http.ResponseWriter, r *http.Request,
 // password : test password (optional);
 // time_limit : time limit duration (00:15:00).
 // Response codes:
-// 200, 400, 401, 403.
-func NestedTestsCreateHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
-		e.ResponseWithError(
-			w, r, http.StatusForbidden, e.ErrAccessDenied)
-		return
-	}
-
+// 200, 400, 401, 403, 422.
+func (h *Handler) NestedTestsCreateHandler(w http.ResponseWriter, r *http.Request) {
 	bytes := make([]byte, r.ContentLength)
 	r.Body.Read(bytes)
 
@@ -166,10 +129,18 @@ func NestedTestsCreateHandler(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	access, err := test.CheckAccess(claims)
+	var err error
+	if r.PathValue("id") != "" {
+		if test.CourseId, err = pathId(r, "id"); err != nil {
+			e.ResponseWithError(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	access, err := h.courseAccess(r.Context(), test.CourseId)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrCourseNotFound)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 	if access != 2 {
@@ -178,7 +149,7 @@ func NestedTestsCreateHandler(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	if err := test.Insert(); err != nil {
+	if err := h.Tests.Insert(r.Context(), &test); err != nil {
 		e.ResponseWithError(
 			w, r, http.StatusBadRequest, err)
 		return
@@ -188,9 +159,12 @@ func NestedTestsCreateHandler(w http.ResponseWriter, r *http.Request,
 }
 
 // Nested test page PUT logic.
+// Test id is taken from path: /api/tests/{id},
+// deprecated route without it takes id from body.
 // Expected header:
 // Authorization : Bearer <access token>
 // This method allowed only to teachers, who belongs to course or admins.
+// Moving to another course needs access to both courses.
 // Response: Error message or StatusOk:
 // Expected body:
 // id : test id;
@@ -204,15 +178,8 @@ func NestedTestsCreateHandler(w http.ResponseWriter, r *http.Request,
 // password : test password (optional);
 // time_limit : time limit duration (00:15:00).
 // Response codes:
-// 200, 400, 401, 403.
-func NestedTestsUpdateHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
-		e.ResponseWithError(
-			w, r, http.StatusForbidden, e.ErrAccessDenied)
-		return
-	}
-
+// 200, 400, 401, 403, 404, 422.
+func (h *Handler) NestedTestsUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	bytes := make([]byte, r.ContentLength)
 	r.Body.Read(bytes)
 
@@ -224,19 +191,36 @@ func NestedTestsUpdateHandler(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	access, err := test.CheckAccess(claims)
+	var err error
+	if r.PathValue("id") != "" {
+		if test.Id, err = pathId(r, "id"); err != nil {
+			e.ResponseWithError(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	stored, err := h.Tests.GetById(r.Context(), test.Id)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrCourseNotFound)
+			w, r, http.StatusNotFound, err)
 		return
 	}
-	if access != 2 {
+
+	// Stored course is checked, body one may belong to another teacher
+	allowed, err := h.canWriteCourses(r.Context(),
+		stored.CourseId, test.CourseId)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusBadRequest, err)
+		return
+	}
+	if !allowed {
 		e.ResponseWithError(
 			w, r, http.StatusForbidden, e.ErrUserNotBelongToCourse)
 		return
 	}
 
-	if err := test.Update(); err != nil {
+	if err := h.Tests.Update(r.Context(), &test); err != nil {
 		e.ResponseWithError(
 			w, r, http.StatusBadRequest, err)
 		return
@@ -246,49 +230,43 @@ func NestedTestsUpdateHandler(w http.ResponseWriter, r *http.Request,
 }
 
 // Nested test page DELETE logic.
-// URL values should contain ?id=<test_id>
+// Test id is taken from path: /api/tests/{id}.
 // This method allowed only to admins and teachers, who belongs to course.
 // Expected header:
 // Authorization : Bearer <access token>
 // Response: Error message or StatusOk:
 // Response codes:
 // 200, 400, 401, 403, 404.
-func NestedTestsDeleteHandler(w http.ResponseWriter, r *http.Request,
-	token string, claims jwt.MapClaims) {
-	if claims["role_id"] != 2 && claims["role_id"] != 3 {
-		e.ResponseWithError(
-			w, r, http.StatusForbidden, e.ErrAccessDenied)
-		return
-	}
-
-	rawQuery := r.URL.Query()
-	if !rawQuery.Has("id") {
-		e.ResponseWithError(w, r, http.StatusBadRequest, e.ErrUrlValueMissing)
+func (h *Handler) NestedTestsDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	testId, err := pathId(r, "id")
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	testId, err := strconv.Atoi(rawQuery.Get("id"))
+	test, err := h.Tests.GetById(r.Context(), testId)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUrlValueNotValid)
+			w, r, http.StatusNotFound, err)
 		return
 	}
 
-	test, err := models.GetNestedTestById(testId)
+	access, err := h.courseAccess(r.Context(), test.CourseId)
 	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusNotFound, e.ErrNestedTestNotFound)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
-
-	access, _ := test.CheckAccess(claims)
 	if access != 2 {
 		e.ResponseWithError(
 			w, r, http.StatusForbidden, e.ErrCourseNotFound)
 		return
 	}
 
-	_ = models.DeleteNestedTestById(testId)
+	if err = h.Tests.DeleteById(r.Context(), testId); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+	"VEEEKTOR_api/pkg/oidc"
+)
+
+// Single sign-on with OpenID Connect provider.
+type OIDC struct {
+	Provider *oidc.Provider
+	// Users created on first sign in get them
+	GroupId int
+	DepId   int
+}
+
+const (
+	oidcFlowCookie = "oidc_flow"
+	// Time given to user for sign in at provider
+	oidcFlowLifeTime = 10 * time.Minute
+)
+
+// State, nonce and PKCE verifier of started sign in.
+type oidcFlow struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// OIDC sign in start logic.
+// Redirects user to sign in page of identity provider. State, nonce
+// and PKCE verifier are kept in signed HttpOnly cookie until callback.
+// Response:
+// Error message or redirect to provider.
+// Cookie:
+// oidc_flow : <signed flow state>, lifetime - 10m.
+// Response codes:
+// 302, 404, 405, 500.
+func (h *Handler) OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if h.OIDC == nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, e.ErrOIDCDisabled)
+		return
+	}
+
+	var flow oidcFlow
+	for _, dst := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		value, err := oidc.RandomString()
+		if err != nil {
+			e.ResponseWithError(w, r, http.StatusInternalServerError,
+				e.Internal("oidc login", err))
+			return
+		}
+		*dst = value
+	}
+
+	redirect, err := h.OIDC.Provider.AuthCodeURL(r.Context(),
+		flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError,
+			e.Internal("oidc login", err))
+		return
+	}
+
+	h.setOIDCFlowCookie(w, sealOIDCFlow(flow, time.Now()),
+		int(oidcFlowLifeTime/time.Second))
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+// OIDC sign in callback logic.
+// Expected query:
+// code : authorization code of provider;
+// state : state of started sign in.
+// Expected cookie:
+// oidc_flow : cookie set by /auth/oidc/login.
+// Provider subject is mapped to user linked to it, then to user with
+// the same email (when provider verified it), otherwise student of
+// configured group and department is created. Email verification
+// is not required, identity is proven by provider. Email and names
+// of created user are checked with sign up rules.
+// Response:
+// Error message, token pair or MFA challenge, like /users/signin.
+// Cookie:
+// refresh_token : <rt>.
+// Response codes:
+// 200, 400, 401, 403, 404, 405, 409, 422, 500.
+func (h *Handler) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if h.OIDC == nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, e.ErrOIDCDisabled)
+		return
+	}
+
+	// Flow is single use, cookie is cleared whatever the outcome
+	var flow oidcFlow
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err == nil {
+		flow, err = openOIDCFlow(cookie.Value, time.Now())
+	}
+	h.setOIDCFlowCookie(w, "", -1)
+	query := r.URL.Query()
+	if err != nil || subtle.ConstantTimeCompare(
+		[]byte(flow.State), []byte(query.Get("state"))) != 1 {
+		e.ResponseWithError(
+			w, r, http.StatusBadRequest, e.ErrOIDCStateNotValid)
+		return
+	}
+
+	if reason := query.Get("error"); reason != "" || query.Get("code") == "" {
+		log.Printf("oidc sign in is rejected by provider: %q", reason)
+		e.ResponseWithError(
+			w, r, http.StatusUnauthorized, e.ErrOIDCLoginFailed)
+		return
+	}
+
+	claims, err := h.OIDC.Provider.Exchange(r.Context(),
+		query.Get("code"), flow.Verifier, flow.Nonce)
+	if errors.Is(err, oidc.ErrExchange) ||
+		errors.Is(err, oidc.ErrTokenNotValid) {
+		log.Printf("oidc sign in failed: %v", err)
+		e.ResponseWithError(
+			w, r, http.StatusUnauthorized, e.ErrOIDCLoginFailed)
+		return
+	} else if err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError,
+			e.Internal("oidc exchange", err))
+		return
+	}
+
+	user, err := h.oidcUser(r.Context(), claims)
+	switch {
+	case errors.Is(err, e.ErrOIDCLoginFailed):
+		e.ResponseWithError(w, r, http.StatusUnauthorized, err)
+		return
+	case errors.Is(err, e.ErrUserExist):
+		e.ResponseWithError(w, r, http.StatusConflict, err)
+		return
+	case errors.Is(err, e.ErrValidationFailed):
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	case err != nil:
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !user.Active {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrUserDeactivated)
+		return
+	}
+
+	h.finishSignIn(w, r, user)
+}
+
+// Finds user of provider subject, links or creates one on first
+// sign in. Account with the same email is linked only when provider
+// verified email, otherwise it could be taken over.
+// Errors: ErrOIDCLoginFailed, ErrUserExist, ErrValidationFailed
+func (h *Handler) oidcUser(ctx context.Context,
+	claims oidc.Claims) (models.User, error) {
+	issuer := h.OIDC.Provider.Issuer()
+	user, err := h.Users.GetByIdentity(ctx, issuer, claims.Subject)
+	if err == nil || !errors.Is(err, e.ErrUserNotFound) {
+		return user, err
+	}
+
+	email := strings.TrimSpace(claims.Email)
+	if email == "" {
+		log.Printf("oidc subject %q has no email", claims.Subject)
+		return models.User{}, e.ErrOIDCLoginFailed
+	}
+
+	user, err = h.Users.GetByEmail(ctx, email)
+	switch {
+	case err == nil && !claims.EmailVerified:
+		return models.User{}, e.ErrUserExist
+	case err == nil:
+		if !user.EmailVerified {
+			if err = h.Users.SetEmailVerified(ctx, user.Id, true); err != nil {
+				return models.User{}, err
+			}
+			user.EmailVerified = true
+		}
+	case errors.Is(err, e.ErrUserNotFound):
+		if user, err = h.createOIDCUser(ctx, email, claims); err != nil {
+			return models.User{}, err
+		}
+	default:
+		return models.User{}, err
+	}
+
+	if err = h.Users.LinkIdentity(ctx, user.Id,
+		issuer, claims.Subject); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// Student of configured group and department. Password is random,
+// user can set own one with password reset.
+// Errors: ErrUserExist, ErrValidationFailed
+func (h *Handler) createOIDCUser(ctx context.Context,
+	email string, claims oidc.Claims) (models.User, error) {
+	password, err := oidc.RandomString()
+	if err != nil {
+		return models.User{}, e.Internal("create oidc user", err)
+	}
+	if password, err = models.HashPassword(password); err != nil {
+		return models.User{}, e.Internal("create oidc user", err)
+	}
+
+	name, surname := claims.GivenName, claims.FamilyName
+	if name == "" && surname == "" {
+		name, surname, _ = strings.Cut(strings.TrimSpace(claims.Name), " ")
+	}
+	login, _, _ := strings.Cut(email, "@")
+
+	user := models.User{
+		Email:         email,
+		Password:      password,
+		GroupId:       h.OIDC.GroupId,
+		Name:          profileName(name, login),
+		Patronymic:    profileName(claims.MiddleName),
+		Surname:       profileName(surname),
+		RoleId:        auth.RoleStudent,
+		DepId:         h.OIDC.DepId,
+		Active:        true,
+		EmailVerified: claims.EmailVerified,
+	}
+	if err = user.ValidateProfile(); err != nil {
+		log.Printf("oidc subject %q doesn't fit sign up rules: %v",
+			claims.Subject, err)
+		return models.User{}, err
+	}
+	if err = h.Users.Insert(ctx, &user); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// Placeholder of name parts provider didn't share.
+const unknownName = "Unknown"
+
+// First of names fitting sign up limits, longer ones are truncated.
+// Placeholder is used when all are too short, so created user
+// can update profile without filling them in.
+func profileName(names ...string) string {
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		for utf8.RuneCountInString(name) > 30 {
+			_, size := utf8.DecodeLastRuneInString(name)
+			name = name[:len(name)-size]
+		}
+		if utf8.RuneCountInString(name) >= 2 {
+			return name
+		}
+	}
+
+	return unknownName
+}
+
+// Cookie must be sent on redirect from provider, so it is lax
+// whatever same site of refresh token cookie is.
+func (h *Handler) setOIDCFlowCookie(w http.ResponseWriter,
+	value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   h.Cookie.Secure,
+		Domain:   h.Cookie.Domain,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Key of flow signatures derived from access token key.
+func oidcFlowKey() []byte {
+	mac := hmac.New(sha256.New, auth.AccessKey)
+	mac.Write([]byte(oidcFlowCookie))
+	return mac.Sum(nil)
+}
+
+// state.nonce.verifier.expiration.signature, parts are
+// URL safe base64 without dots.
+func sealOIDCFlow(flow oidcFlow, now time.Time) string {
+	payload := strings.Join([]string{flow.State, flow.Nonce, flow.Verifier,
+		strconv.FormatInt(now.Add(oidcFlowLifeTime).Unix(), 10)}, ".")
+	mac := hmac.New(sha256.New, oidcFlowKey())
+	mac.Write([]byte(payload))
+
+	return payload + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Errors: ErrOIDCStateNotValid
+func openOIDCFlow(value string, now time.Time) (oidcFlow, error) {
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return oidcFlow{}, e.ErrOIDCStateNotValid
+	}
+	payload := value[:i]
+	sig, err := base64.RawURLEncoding.DecodeString(value[i+1:])
+	mac := hmac.New(sha256.New, oidcFlowKey())
+	mac.Write([]byte(payload))
+	if err != nil || !hmac.Equal(sig, mac.Sum(nil)) {
+		return oidcFlow{}, e.ErrOIDCStateNotValid
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 4 {
+		return oidcFlow{}, e.ErrOIDCStateNotValid
+	}
+	exp, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || now.Unix() > exp {
+		return oidcFlow{}, e.ErrOIDCStateNotValid
+	}
+
+	return oidcFlow{State: parts[0], Nonce: parts[1], Verifier: parts[2]}, nil
+}
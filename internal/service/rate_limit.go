@@ -0,0 +1,87 @@
+package service
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+	e "VEEEKTOR_api/pkg/errors"
+	"VEEEKTOR_api/pkg/ratelimit"
+)
+
+// Subject of rate limit bucket, e.g. ip:<ip> or user:<id>.
+type RateKey func(r *http.Request) string
+
+func ByIP(r *http.Request) string {
+	return "ip:" + auth.GetClientInfo(r).Ip
+}
+
+// Owner of access token, requests without valid token are
+// limited by address. Token is checked here, so limit can be
+// put before Authenticate.
+func ByUser(r *http.Request) string {
+	p, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		token, err := auth.GetAccessTokenFromHeader(r)
+		if err != nil {
+			return ByIP(r)
+		}
+		if p, err = auth.ParsePrincipal(token); err != nil {
+			return ByIP(r)
+		}
+	}
+
+	return "user:" + strconv.Itoa(p.UserId)
+}
+
+// Limits requests of key subject by token bucket of policy.
+// Requests are let through when storage is not set or fails.
+// Response headers:
+// RateLimit-Policy : <limit>;w=<period seconds>;
+// RateLimit-Limit, RateLimit-Remaining : bucket size and tokens left;
+// RateLimit-Reset : seconds until bucket is full;
+// Retry-After : seconds until next token, on 429 only.
+// Response codes:
+// 429.
+func (h *Handler) RateLimit(p ratelimit.Policy, key RateKey) Middleware {
+	policy := strconv.Itoa(p.Limit) + ";w=" +
+		strconv.Itoa(int(p.Period.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h.RateLimits == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := h.RateLimits.Take(
+				r.Context(), p.Name+":"+key(r), p)
+			if err != nil {
+				log.Printf("rate limit %s is not applied: %v", p.Name, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			hd := w.Header()
+			hd.Set("RateLimit-Policy", policy)
+			hd.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			hd.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			hd.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+			if !res.Allowed {
+				hd.Set("Retry-After", ceilSeconds(res.RetryAfter))
+				e.ResponseWithError(
+					w, r, http.StatusTooManyRequests, e.ErrRateLimited)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
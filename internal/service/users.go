@@ -1,24 +1,18 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 
 	"VEEEKTOR_api/internal/auth"
 	"VEEEKTOR_api/internal/models"
 	e "VEEEKTOR_api/pkg/errors"
+	"VEEEKTOR_api/pkg/validate"
 )
 
-func GetUsersHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		UsersGetHandler(w, r)
-	default:
-		e.ResponseWithError(w, r, http.StatusMethodNotAllowed,
-			e.ErrOnlyGetAllowed)
-	}
-}
-
 // Get user by access token method.
 // Expected header:
 // Authorization : Bearer <Access token>.
@@ -30,28 +24,15 @@ func GetUsersHandler(w http.ResponseWriter, r *http.Request) {
 // patronymic : user patronymic;
 // surname : user surname;
 // role_id : id of user role;
-// dep_id : id of user department.
+// dep_id : id of user department;
+// active : false for deactivated account;
+// email_verified : true after email verification.
 // Response codes:
 // 200, 400, 401, 404, 500.
-func UsersGetHandler(w http.ResponseWriter, r *http.Request) {
-	accessToken, err := auth.GetAccessTokenFromHeader(r)
-	if err != nil {
-		e.ResponseWithError(w, r, http.StatusBadRequest, err)
-		return
-	}
-
-	claims, err := auth.GetTokenClaims(accessToken)
-	if err == e.ErrTokenExpired {
-		e.ResponseWithError(w, r, http.StatusUnauthorized, err)
-		return
-	} else if err != nil {
-		e.ResponseWithError(w, r, http.StatusBadRequest, err)
-		return
-	}
-
-	user, err := models.GetUserById(claims["user_id"].(int))
+func (h *Handler) UsersGetHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := h.Users.GetById(r.Context(), principal(r).UserId)
 	if err != nil {
-		e.ResponseWithError(w, r, http.StatusNotFound, e.ErrUserNotFound)
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
 		return
 	}
 	user.Password = ""
@@ -68,60 +49,158 @@ func UsersGetHandler(w http.ResponseWriter, r *http.Request) {
 // Error message or token pair:
 // access_token  : token for access to private pages, lifetime - 15m;
 // refresh_token : token for refreshing access token, lifetime - 30 days.
+// When user has two-factor authentication or role of user requires it,
+// challenge for /users/signin/mfa is returned instead:
+// mfa_required : true;
+// mfa_token : single use challenge token, lifetime - 5m;
+// enroll : user must enroll with /users/signin/mfa/enroll first.
 // Access token claims:
 // exp : token expiration date and time in UNIX format;
 // user_id : user id;
-// role_id : user role id.
+// role_id : user role id;
+// session_id : id of session token pair belongs to.
 // Cookie:
 // refresh_token : <rt>.
+// Unknown email and wrong password are answered with 401.
+// Attempts of account and client address are counted before
+// password is compared and taken back when it is right, after
+// several failures sign in is delayed with growing backoff
+// and then locked out, blocked attempts are answered with 429
+// and Retry-After header.
+// Deactivated users and users with unverified email (when
+// verification is required) are answered with 403.
 // Response codes:
-// 200, 400, 404, 405.
-func UsersSignInHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+// 200, 400, 401, 403, 405, 422, 429.
+func (h *Handler) UsersSignInHandler(w http.ResponseWriter, r *http.Request) {
+	var inp models.SignInInput
+	if err := decodeBody(w, r, &inp); err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusMethodNotAllowed, e.ErrOnlyPostAllowed)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	bytes := make([]byte, r.ContentLength)
-	r.Body.Read(bytes)
+	if err := inp.Validate(); err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
 
-	var err error
-	var inp models.SignInInput
-	if err = json.Unmarshal(bytes, &inp); err != nil {
+	// Attempt is counted before password is compared, so parallel
+	// guesses can't pass the block
+	attempt, err := h.Login.Reserve(
+		r.Context(), inp.Email, auth.GetClientInfo(r).Ip)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusInternalServerError, err)
+		return
+	} else if attempt.Wait > 0 {
+		w.Header().Set("Retry-After", ceilSeconds(attempt.Wait))
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUnableToUnmarshalBody)
+			w, r, http.StatusTooManyRequests, e.ErrTooManyAttempts)
 		return
 	}
 
-	if err := inp.Validate(); err != nil {
+	// Unknown email and wrong password are answered the same way
+	user, err := h.Users.GetByEmail(r.Context(), inp.Email)
+	if errors.Is(err, e.ErrUserNotFound) {
+		models.CompareDummyPassword(inp.Password)
+	} else if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, err)
+			w, r, http.StatusInternalServerError, err)
+		return
+	} else {
+		err = user.ComparePassword(inp.Password)
+	}
+	if err != nil {
+		if err = h.Login.Failed(r.Context(), attempt); err != nil {
+			e.ResponseWithError(
+				w, r, http.StatusInternalServerError, err)
+			return
+		}
+		e.ResponseWithError(
+			w, r, http.StatusUnauthorized, e.ErrInvalidCredentials)
+		return
+	}
+	if err = h.Login.Release(r.Context(), attempt); err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Checked after password, so state of account is not disclosed
+	if !user.Active {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrUserDeactivated)
 		return
 	}
+	if h.RequireVerifiedEmail && !user.EmailVerified {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrEmailNotVerified)
+		return
+	}
+
+	// Upgrade legacy row, sign in is not affected on failure
+	if !user.IsPasswordHashed() {
+		if err = h.rehashPassword(r.Context(), user.Id, inp.Password); err != nil {
+			log.Printf("unable to rehash password of user %d: %v",
+				user.Id, err)
+		}
+	}
 
-	var user models.User
-	if user, err = models.GetUserByEmailAndPassword(inp); err != nil {
+	// Password is proven, code is checked by second step
+	h.finishSignIn(w, r, user)
+}
+
+// Answers proven user with MFA challenge or token pair
+// of new session.
+func (h *Handler) finishSignIn(w http.ResponseWriter, r *http.Request,
+	user models.User) {
+	status, err := h.Auth.NeedsMFA(r.Context(), user)
+	if err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusNotFound, err)
+			w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if status.Enabled || status.Required {
+		token, err := h.Auth.IssueUserToken(r.Context(), user.Id,
+			auth.PurposeMFAChallenge, auth.MFAChallengeLifeTime)
+		if err != nil {
+			e.ResponseWithError(
+				w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		jsonBytes, _ := json.Marshal(auth.MFAChallenge{
+			MFARequired: true, Token: token, Enroll: !status.Enabled})
+		w.Write(jsonBytes)
 		return
 	}
 
-	tokens, _ := auth.StoreSession(user.Id, user.RoleId, user.GroupId)
+	tokens, err := h.startSession(r, user)
+	if err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusInternalServerError, err)
+		return
+	}
 
 	// Write jwt and refresh token pair
 	jsonBytes, _ := json.Marshal(tokens)
-	http.SetCookie(w, &http.Cookie{
-		Name:  "refresh_token",
-		Value: tokens.RefreshToken,
-		Path:  "/",
-		// Secure:   true, *causes cookie not set with http unsecure protocol*
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-	})
+	h.setRefreshTokenCookie(w, tokens.RefreshToken)
 	w.Write(jsonBytes)
 }
 
+// Forgets failed attempts of account and stores new session.
+// Errors: -
+func (h *Handler) startSession(r *http.Request,
+	user models.User) (auth.TokenResponse, error) {
+	if err := h.Login.Succeeded(r.Context(), user.Email); err != nil {
+		return auth.TokenResponse{}, err
+	}
+
+	return h.Auth.StoreSession(r.Context(),
+		user.Id, user.RoleId, user.GroupId, auth.GetClientInfo(r))
+}
+
 // Users sign up logic.
 // Expected body:
 // email : user email (4-64 symbols);
@@ -129,28 +208,21 @@ func UsersSignInHandler(w http.ResponseWriter, r *http.Request) {
 // name : user name (2-30 symbols);
 // patronymic : user patronymic (2-30 symbols);
 // surname : user surname (2-30 symbols);
+// group_id : group id;
 // dep_id : department id.
+// Verification letter is sent to email, see UsersVerifyHandler.
 // Response:
-// Error message or StatusOk.
+// Error message or StatusOk. Invalid fields are listed
+// in error details with field, rule and message.
 // Response codes:
-// 200, 400, 405, 409.
-func UsersSignUpHandler(w http.ResponseWriter, r *http.Request) {
+// 200, 400, 405, 409, 422.
+func (h *Handler) UsersSignUpHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
-	if r.Method != http.MethodPost {
-		e.ResponseWithError(
-			w, r, http.StatusMethodNotAllowed, e.ErrOnlyPostAllowed)
-		return
-	}
-
-	bytes := make([]byte, r.ContentLength)
-	r.Body.Read(bytes)
-
 	var dto models.User
 	dto.RoleId = 1
-
-	if err = json.Unmarshal(bytes, &dto); err != nil {
+	if err = decodeBody(w, r, &dto); err != nil {
 		e.ResponseWithError(
-			w, r, http.StatusBadRequest, e.ErrUnableToUnmarshalBody)
+			w, r, http.StatusBadRequest, err)
 		return
 	}
 
@@ -160,22 +232,191 @@ func UsersSignUpHandler(w http.ResponseWriter, r *http.Request) {
 			w, r, http.StatusBadRequest, e.ErrRoleCantBeSet)
 		return
 	}
+	dto.Active, dto.EmailVerified = true, false
 
 	// Admin department not availiable for basic users
 	if dto.DepId == 1 {
 		e.ResponseWithError(
 			w, r, http.StatusBadRequest, e.ErrCantSetThisDep)
+		return
 	}
 
-	if err := dto.Validate(); err != nil {
-		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+	// All field and reference violations are reported at once
+	ve := validate.Struct(&dto)
+	if err := ve.Merge(h.Users.CheckReferences(r.Context(), &dto)); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if err := ve.Err(); err != nil {
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
 		return
 	}
 
-	if err := dto.Insert(); err != nil {
+	if dto.Password, err = models.HashPassword(dto.Password); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError,
+			e.Internal("sign up", err))
+		return
+	}
+
+	if err := h.Users.Insert(r.Context(), &dto); err != nil {
 		e.ResponseWithError(w, r, http.StatusBadRequest, err)
 		return
 	}
+	h.sendUserTokenOrLog(r.Context(), dto, auth.PurposeVerifyEmail)
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// Users profile PUT and PATCH logic.
+// Changes own profile of token owner. PUT replaces all fields below,
+// PATCH changes provided ones only.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Expected body:
+// email : user email (4-64 symbols);
+// name : user name (2-30 symbols);
+// patronymic : user patronymic (2-30 symbols);
+// surname : user surname (2-30 symbols);
+// group_id : group id;
+// dep_id : department id.
+// Role can be changed only by admin, password - by password change.
+// New group is put into access token on next refresh.
+// Changed email is verified again, verification letter is sent to it.
+// Response:
+// Error message or updated user data without password.
+// Response codes:
+// 200, 400, 401, 404, 409, 422.
+func (h *Handler) UsersUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	stored, err := h.Users.GetById(r.Context(), principal(r).UserId)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	// Fields missing from PATCH body keep stored values
+	dto := stored
+	if r.Method != http.MethodPatch {
+		dto = models.User{RoleId: stored.RoleId}
+	}
+	if err = decodeBody(w, r, &dto); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	dto.Id, dto.Password, dto.Active = stored.Id, stored.Password, stored.Active
+	dto.EmailVerified = stored.EmailVerified
+
+	if dto.RoleId != stored.RoleId {
+		e.ResponseWithError(
+			w, r, http.StatusBadRequest, e.ErrRoleCantBeSet)
+		return
+	}
+
+	// Admin department not availiable for basic users
+	if dto.DepId == 1 && dto.DepId != stored.DepId &&
+		stored.RoleId != auth.RoleAdmin {
+		e.ResponseWithError(
+			w, r, http.StatusBadRequest, e.ErrCantSetThisDep)
+		return
+	}
+
+	if err = dto.ValidateProfile(); err != nil {
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if err = h.Users.Update(r.Context(), &dto); err != nil {
+		e.ResponseWithError(w, r, userWriteStatus(err), err)
+		return
+	}
+
+	// New email must be verified again
+	if dto.Email != stored.Email {
+		if err = h.Users.SetEmailVerified(
+			r.Context(), dto.Id, false); err != nil {
+			e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		dto.EmailVerified = false
+		h.sendUserTokenOrLog(r.Context(), dto, auth.PurposeVerifyEmail)
+	}
+	dto.Password = ""
+
+	jsonBytes, _ := json.Marshal(dto)
+	w.Write(jsonBytes)
+}
+
+// Users password change logic.
+// All sessions of token owner are revoked, including current one,
+// and new token pair is issued.
+// Expected header:
+// Authorization : Bearer <access token>.
+// Expected body:
+// current_password : password in use;
+// new_password : new password (8-50 symbols).
+// Response:
+// Error message or token pair:
+// access_token : token for access to private pages, lifetime - 15m;
+// refresh_token : token for refreshing access token, lifetime - 30 days.
+// Cookie:
+// refresh_token : <rt>.
+// Response codes:
+// 200, 400, 401, 403, 404, 422, 500.
+func (h *Handler) UsersPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var inp models.PasswordChangeInput
+	if err := decodeBody(w, r, &inp); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	user, err := h.Users.GetById(r.Context(), principal(r).UserId)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	if err = user.ComparePassword(inp.CurrentPassword); err != nil {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrWrongPassword)
+		return
+	}
+
+	if err = h.rehashPassword(
+		r.Context(), user.Id, inp.NewPassword); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Tokens issued with old password stop working
+	if err = h.Auth.ClearSessionsByUserId(r.Context(), user.Id); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	tokens, err := h.Auth.StoreSession(r.Context(),
+		user.Id, user.RoleId, user.GroupId, auth.GetClientInfo(r))
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	jsonBytes, _ := json.Marshal(tokens)
+	h.setRefreshTokenCookie(w, tokens.RefreshToken)
+	w.Write(jsonBytes)
+}
+
+// Stores hash of provided password.
+// Errors: -
+func (h *Handler) rehashPassword(ctx context.Context,
+	userId int, password string) error {
+	hash, err := models.HashPassword(password)
+	if err != nil {
+		return e.Internal("rehash password", err)
+	}
+
+	return h.Users.UpdatePassword(ctx, userId, hash)
+}
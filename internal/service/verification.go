@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"VEEEKTOR_api/internal/auth"
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+	"VEEEKTOR_api/pkg/mail"
+)
+
+// Letter of single use token, page is web client page
+// link leads to.
+type tokenLetter struct {
+	page     string
+	subject  string
+	text     string
+	lifeTime func() time.Duration
+}
+
+var tokenLetters = map[string]tokenLetter{
+	auth.PurposeVerifyEmail: {
+		page:     "/verify",
+		subject:  "VEEEKTOR: email confirmation",
+		text:     "To confirm your email follow the link",
+		lifeTime: func() time.Duration { return auth.VerifyTokenLifeTime },
+	},
+	auth.PurposeResetPassword: {
+		page:    "/reset-password",
+		subject: "VEEEKTOR: password reset",
+		text: "Password reset was requested for your account. " +
+			"If it was not you, ignore this letter. " +
+			"To set new password follow the link",
+		lifeTime: func() time.Duration { return auth.ResetTokenLifeTime },
+	},
+}
+
+// Issues token of purpose and mails it to user.
+// Errors: -
+func (h *Handler) sendUserToken(ctx context.Context,
+	usr models.User, purpose string) error {
+	letter := tokenLetters[purpose]
+	lifeTime := letter.lifeTime()
+	token, err := h.Auth.IssueUserToken(ctx, usr.Id, purpose, lifeTime)
+	if err != nil {
+		return err
+	}
+
+	// Without web client address token is sent as is
+	link := token
+	if h.LinkBaseURL != "" {
+		link = strings.TrimSuffix(h.LinkBaseURL, "/") + letter.page +
+			"?token=" + url.QueryEscape(token)
+	}
+
+	if err = h.Mailer.Send(ctx, mail.Message{
+		To:      usr.Email,
+		Subject: letter.subject,
+		Body: fmt.Sprintf("Hello, %s!\n\n%s (valid for %s):\n%s\n",
+			usr.Name, letter.text, shortDuration(lifeTime), link),
+	}); err != nil {
+		return e.Internal("send "+purpose+" letter", err)
+	}
+
+	return nil
+}
+
+// Letters sent in background are bounded by this timeout.
+const letterTimeout = time.Minute
+
+// Looks user of email up and mails token of purpose when wanted
+// reports true. Both run in background, so response time doesn't
+// tell whether email is registered.
+func (h *Handler) mailTokenInBackground(ctx context.Context,
+	email, purpose string, wanted func(models.User) bool) {
+	// Request context is cancelled when response is written
+	ctx, cancel := context.WithTimeout(
+		context.WithoutCancel(ctx), letterTimeout)
+	h.letters.Add(1)
+	go func() {
+		defer h.letters.Done()
+		defer cancel()
+
+		user, err := h.Users.GetByEmail(ctx, email)
+		if err != nil {
+			if !errors.Is(err, e.ErrUserNotFound) {
+				log.Printf("unable to find user to mail %s token: %v",
+					purpose, err)
+			}
+			return
+		}
+		if wanted(user) {
+			h.sendUserTokenOrLog(ctx, user, purpose)
+		}
+	}()
+}
+
+// Blocks until letters sent in background are sent.
+func (h *Handler) WaitLetters() {
+	h.letters.Wait()
+}
+
+// Sign up and profile changes are not affected by mail failures,
+// letter can be requested again.
+func (h *Handler) sendUserTokenOrLog(ctx context.Context,
+	usr models.User, purpose string) {
+	if err := h.sendUserToken(ctx, usr, purpose); err != nil {
+		log.Printf("unable to mail %s token to user %d: %v",
+			purpose, usr.Id, err)
+	}
+}
+
+// Email verification logic.
+// Token is taken from letter sent on sign up or email change,
+// it can be used once.
+// Expected body:
+// token : verification token.
+// Response: Error message or StatusOk.
+// Response codes:
+// 200, 400, 404, 422.
+func (h *Handler) UsersVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	var inp models.UserTokenInput
+	if err := decodeBody(w, r, &inp); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	userId, err := h.Auth.ConsumeUserToken(
+		r.Context(), auth.PurposeVerifyEmail, inp.Token)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = h.Users.SetEmailVerified(r.Context(), userId, true); err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
+		return
+	}
+}
+
+// Verification letter resend logic.
+// Response does not depend on existence of user, so it can't be used
+// to find registered emails. Previous letter link stops working.
+// Expected body:
+// email : user email.
+// Response: Error message or StatusOk.
+// Response codes:
+// 200, 400, 422.
+func (h *Handler) UsersVerifyResendHandler(w http.ResponseWriter, r *http.Request) {
+	var inp models.EmailInput
+	if err := decodeBody(w, r, &inp); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.mailTokenInBackground(r.Context(), inp.Email, auth.PurposeVerifyEmail,
+		func(user models.User) bool {
+			return user.Active && !user.EmailVerified
+		})
+}
+
+// Forgotten password logic.
+// Mails password reset link to active user. Response does not depend
+// on existence of user, so it can't be used to find registered emails.
+// Expected body:
+// email : user email.
+// Response: Error message or StatusOk.
+// Response codes:
+// 200, 400, 422.
+func (h *Handler) PasswordForgotHandler(w http.ResponseWriter, r *http.Request) {
+	var inp models.EmailInput
+	if err := decodeBody(w, r, &inp); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	h.mailTokenInBackground(r.Context(), inp.Email, auth.PurposeResetPassword,
+		func(user models.User) bool { return user.Active })
+}
+
+// Password reset logic.
+// Token is taken from letter sent by forgotten password request,
+// it can be used once. All sessions of user are revoked, email
+// is considered verified.
+// Expected body:
+// token : password reset token;
+// new_password : new password (8-50 symbols).
+// Response: Error message or StatusOk.
+// Response codes:
+// 200, 400, 403, 404, 422, 500.
+func (h *Handler) PasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var inp models.PasswordResetInput
+	if err := decodeBody(w, r, &inp); err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := inp.Validate(); err != nil {
+		e.ResponseWithError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	userId, err := h.Auth.ConsumeUserToken(
+		r.Context(), auth.PurposeResetPassword, inp.Token)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := h.Users.GetById(r.Context(), userId)
+	if err != nil {
+		e.ResponseWithError(w, r, http.StatusNotFound, err)
+		return
+	}
+	if !user.Active {
+		e.ResponseWithError(
+			w, r, http.StatusForbidden, e.ErrUserDeactivated)
+		return
+	}
+
+	if err = h.rehashPassword(
+		r.Context(), user.Id, inp.NewPassword); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Letter reached the owner of email
+	if err = h.Users.SetEmailVerified(r.Context(), user.Id, true); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = h.Auth.ClearSessionsByUserId(r.Context(), user.Id); err != nil {
+		e.ResponseWithError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+// Duration without zero units, e.g. 24h instead of 24h0m0s.
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+
+	return s
+}
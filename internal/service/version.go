@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"VEEEKTOR_api/internal/models"
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+// API versions served side by side. Handlers are shared,
+// response bodies are shaped by serializers of request version.
+const (
+	V1 = 1
+	V2 = 2
+)
+
+type versionKey struct{}
+
+// Puts API version of route group into request context.
+// Errors are answered with envelope since V2.
+func WithVersion(version int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), versionKey{}, version)
+			if version >= V2 {
+				ctx = e.WithEnvelope(ctx)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Requests outside of version groups are served as V1.
+func apiVersion(r *http.Request) int {
+	if version, ok := r.Context().Value(versionKey{}).(int); ok {
+		return version
+	}
+
+	return V1
+}
+
+// V2 reports modification time as RFC 3339 string.
+func serializeCourses(r *http.Request,
+	courses []models.CourseMultipleExportDTO) any {
+	if apiVersion(r) < V2 {
+		return courses
+	}
+
+	res := make([]models.CourseMultipleExportV2DTO, 0, len(courses))
+	for _, c := range courses {
+		res = append(res, c.V2())
+	}
+
+	return res
+}
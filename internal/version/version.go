@@ -0,0 +1,47 @@
+// Package version holds build information injected with ldflags:
+// -X VEEEKTOR_api/internal/version.Commit=<git commit>
+// -X VEEEKTOR_api/internal/version.BuildTime=<RFC 3339 time>
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	Commit    = ""
+	BuildTime = ""
+)
+
+type Info struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Falls back to VCS revision recorded by go build,
+// when commit is not injected.
+func Get() Info {
+	info := Info{
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" && info.Commit == "" {
+				info.Commit = s.Value
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+
+	return info
+}
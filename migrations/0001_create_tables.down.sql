@@ -1,8 +1,12 @@
-DROP TABLE IF EXISTS roles CASCADE;
-DROP TABLE IF EXISTS educational_envs CASCADE;
-DROP TABLE IF EXISTS departments CASCADE;
-DROP TABLE IF EXISTS groups CASCADE;
-DROP TABLE IF EXISTS users CASCADE;
-DROP TABLE IF EXISTS sessions CASCADE;
+DROP TABLE IF EXISTS nested_labs CASCADE;
+DROP TABLE IF EXISTS nested_tests CASCADE;
+DROP TABLE IF EXISTS locations CASCADE;
+DROP TABLE IF EXISTS nested_infos CASCADE;
+DROP TABLE IF EXISTS group_courses CASCADE;
 DROP TABLE IF EXISTS courses CASCADE;
-DROP TABLE IF EXISTS user_courses CASCADE;
+DROP TABLE IF EXISTS sessions CASCADE;
+DROP TABLE IF EXISTS users CASCADE;
+DROP TABLE IF EXISTS groups CASCADE;
+DROP TABLE IF EXISTS departments CASCADE;
+DROP TABLE IF EXISTS educational_envs CASCADE;
+DROP TABLE IF EXISTS roles CASCADE;
@@ -0,0 +1 @@
+TRUNCATE roles, locations RESTART IDENTITY CASCADE;
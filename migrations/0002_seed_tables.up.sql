@@ -0,0 +1,9 @@
+-- Reference rows the API relies on: role ids match auth.Role* constants,
+-- locations are chosen for tests and labs. Demo data is in dev/seed.sql.
+INSERT INTO roles (name) 
+VALUES 
+('student'), ('teacher'), ('admin');
+
+INSERT INTO locations (location) 
+VALUES 
+('At home'), ('In class');
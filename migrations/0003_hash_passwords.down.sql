@@ -0,0 +1,2 @@
+-- Hashes can not be reverted. Nothing to do:
+-- sign in still accepts both hashed and plaintext passwords.
@@ -0,0 +1,6 @@
+-- Hash plaintext passwords left from before bcrypt was introduced.
+-- pgcrypto bf hashes are compatible with golang.org/x/crypto/bcrypt.
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+UPDATE users SET password = crypt(password, gen_salt('bf', 12))
+WHERE password NOT LIKE '$2%';
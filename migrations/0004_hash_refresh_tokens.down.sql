@@ -0,0 +1,3 @@
+-- Digests can not be reverted to tokens, so sessions are dropped
+-- and users have to sign in again.
+DELETE FROM sessions;
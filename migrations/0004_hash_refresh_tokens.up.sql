@@ -0,0 +1,6 @@
+-- Refresh tokens are stored as hex encoded SHA-256 digest.
+-- Must be applied only once, digest has the same length as raw token.
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+UPDATE sessions
+SET refresh_token = encode(digest(refresh_token, 'sha256'), 'hex');
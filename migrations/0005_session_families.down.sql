@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS rotated_refresh_tokens;
+ALTER TABLE sessions DROP CONSTRAINT IF EXISTS sessions_family_id_key;
+ALTER TABLE sessions DROP COLUMN IF EXISTS family_id;
@@ -0,0 +1,16 @@
+-- Every session gets family id, kept through refresh token rotations.
+ALTER TABLE sessions ADD COLUMN family_id VARCHAR(64);
+UPDATE sessions SET family_id = encode(gen_random_bytes(16), 'hex');
+ALTER TABLE sessions ALTER COLUMN family_id SET NOT NULL;
+ALTER TABLE sessions ADD CONSTRAINT sessions_family_id_key UNIQUE (family_id);
+
+CREATE TABLE rotated_refresh_tokens (
+    id            SERIAL PRIMARY KEY,
+    family_id     VARCHAR(64) NOT NULL
+                  REFERENCES sessions(family_id) ON DELETE CASCADE,
+    refresh_token VARCHAR(300) NOT NULL,
+    rotated_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX rotated_refresh_tokens_refresh_token_idx
+    ON rotated_refresh_tokens (refresh_token);
@@ -0,0 +1,6 @@
+-- Client data shown in the list of user sessions (devices).
+ALTER TABLE sessions ADD COLUMN last_used_at TIMESTAMP WITH TIME ZONE 
+    NOT NULL DEFAULT now();
+ALTER TABLE sessions ADD COLUMN user_agent VARCHAR(512) NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN ip VARCHAR(64) NOT NULL DEFAULT '';
+UPDATE sessions SET last_used_at = created_at;
@@ -0,0 +1,2 @@
+-- Deactivated users can not sign in or refresh tokens.
+ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL DEFAULT true;
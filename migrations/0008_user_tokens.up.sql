@@ -0,0 +1,16 @@
+-- Existing accounts are treated as verified.
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT false;
+UPDATE users SET email_verified = true;
+
+-- Single use tokens of email verification and password reset,
+-- only digests are stored.
+CREATE TABLE user_tokens (
+    id         SERIAL PRIMARY KEY,
+    user_id    INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose    VARCHAR(32) NOT NULL,
+    digest     VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX user_tokens_user_id_purpose_idx ON user_tokens (user_id, purpose);
@@ -0,0 +1,18 @@
+-- Failed sign in attempts per account (email:<email>) and client (ip:<ip>).
+CREATE TABLE login_attempts (
+    key             VARCHAR(128) PRIMARY KEY,
+    failures        INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    blocked_until   TIMESTAMP WITH TIME ZONE
+);
+
+-- Audit of lockouts.
+CREATE TABLE login_lockouts (
+    id           SERIAL PRIMARY KEY,
+    key          VARCHAR(128) NOT NULL,
+    email        VARCHAR(64) NOT NULL,
+    ip           VARCHAR(64) NOT NULL,
+    failures     INT NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
@@ -0,0 +1,10 @@
+-- Token buckets of rate limiter shared by replicas.
+-- Full buckets are pruned, missing bucket is full.
+CREATE TABLE rate_limits (
+    key        VARCHAR(160) PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    full_at    TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX rate_limits_full_at_idx ON rate_limits (full_at);
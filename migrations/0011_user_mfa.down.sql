@@ -0,0 +1,3 @@
+ALTER TABLE roles DROP COLUMN IF EXISTS mfa_required;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
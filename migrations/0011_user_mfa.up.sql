@@ -0,0 +1,19 @@
+-- TOTP enrollments, not confirmed ones are disabled.
+CREATE TABLE user_mfa (
+    user_id    INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret     VARCHAR(64) NOT NULL,
+    enabled    BOOLEAN NOT NULL DEFAULT false,
+    -- Step of last accepted code, codes can't be used twice
+    last_step  BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Single use recovery codes, only digests are stored.
+CREATE TABLE mfa_recovery_codes (
+    id      SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    digest  VARCHAR(64) NOT NULL,
+    UNIQUE (user_id, digest)
+);
+
+ALTER TABLE roles ADD COLUMN mfa_required BOOLEAN NOT NULL DEFAULT false;
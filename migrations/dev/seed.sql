@@ -1,7 +1,6 @@
-INSERT INTO roles (name) 
-VALUES 
-('student'), ('teacher'), ('admin');
-
+-- Demo data for development, never applied by the API.
+-- Run on empty migrated database: make -C migrations seed
+-- Every user has password 88888888.
 INSERT INTO educational_envs (name) 
 VALUES 
 ('admin'), ('voenmeh');
@@ -60,10 +59,6 @@ VALUES
 (1, 1), (1, 2), (2, 3), (2, 4), (3, 5), 
 (3, 6), (4, 7), (4, 8), (5, 9), (5, 10);
 
-INSERT INTO locations (location) 
-VALUES 
-('At home'), ('In class');
-
 INSERT INTO nested_infos (course_id, name, markdown)
 VALUES 
 (1, 'Требования КП', '## Заголовок тербований КП'),
@@ -145,3 +140,6 @@ VALUES
 
 (10, '2024-10-01 08:00:00', '2024-05-30 00:00:00',
 'Лабораторная работа 1 предмета П1', '', '', 1, 3);
+
+-- Passwords are stored hashed like ones of signed up users
+UPDATE users SET password = crypt(password, gen_salt('bf', 12));
@@ -0,0 +1,276 @@
+// Package migrate applies versioned SQL migrations
+// and records them in schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Key of advisory lock held while migrations are applied,
+// so concurrently starting replicas run them one by one.
+const LockKey = 7_245_118_300
+
+var fileNameRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrNoMigrationsToRollback = errors.New("no migrations to rollback")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// Reads migrations from root of fsys.
+// Every version must have both up and down file.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, f := range files {
+		m := fileNameRe.FindStringSubmatch(f.Name())
+		if f.IsDir() || m == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(fsys, f.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w",
+				f.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s",
+				version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	var migrations []Migration
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up or down file",
+				mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Runs fn on single connection holding advisory lock.
+func (m *Migrator) withLock(ctx context.Context,
+	fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx,
+		`SELECT pg_advisory_lock($1)`, LockKey); err != nil {
+		return fmt.Errorf("failed to take migrations lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(),
+		`SELECT pg_advisory_unlock($1)`, LockKey)
+
+	if _, err = conn.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT PRIMARY KEY,
+		name       VARCHAR(256) NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func applied(ctx context.Context, conn *sql.Conn) (
+	map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx,
+		`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf(
+				"failed to read schema_migrations: %w", err)
+		}
+		versions[version] = appliedAt
+	}
+
+	return versions, rows.Err()
+}
+
+// Migration body and its schema_migrations record
+// are committed in one transaction.
+func run(ctx context.Context, conn *sql.Conn,
+	mig Migration, body, record string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, body); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, record,
+		mig.Version, mig.Name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Applies all pending migrations in version order.
+// Returns versions applied by this call.
+func (m *Migrator) Up(ctx context.Context) ([]int, error) {
+	var done []int
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := versions[mig.Version]; ok {
+				continue
+			}
+
+			if err = run(ctx, conn, mig, mig.Up,
+				`INSERT INTO schema_migrations (version, name)
+				VALUES ($1, $2)`); err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w",
+					mig.Version, mig.Name, err)
+			}
+			done = append(done, mig.Version)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Rolls back latest applied migration.
+// Errors: ErrNoMigrationsToRollback
+func (m *Migrator) Down(ctx context.Context) (int, error) {
+	var version int
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := versions[mig.Version]; !ok {
+				continue
+			}
+
+			if err = run(ctx, conn, mig, mig.Down,
+				`DELETE FROM schema_migrations 
+				WHERE version=$1 AND name=$2`); err != nil {
+				return fmt.Errorf("failed to rollback migration %d_%s: %w",
+					mig.Version, mig.Name, err)
+			}
+			version = mig.Version
+
+			return nil
+		}
+
+		return ErrNoMigrationsToRollback
+	})
+
+	return version, err
+}
+
+// Returns all known migrations with their state.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			appliedAt, ok := versions[mig.Version]
+			statuses = append(statuses, Status{
+				Migration: mig,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Latest known version, 0 if there are no migrations.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Latest applied version, 0 if none applied.
+// Lock is not taken, so it is cheap enough for readiness probe.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	var version sql.NullInt64
+	err := m.db.QueryRowContext(ctx,
+		`SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return int(version.Int64), nil
+}
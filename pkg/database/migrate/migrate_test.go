@@ -0,0 +1,291 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Database understood by fakeConn: schema_migrations rows and
+// bodies of executed migrations. Body containing FAIL fails.
+type fakeDB struct {
+	mu       sync.Mutex
+	versions map[int64]time.Time
+	executed []string
+}
+
+// Databases of fake driver by data source name.
+var fakeDBs sync.Map
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	db, _ := fakeDBs.LoadOrStore(name,
+		&fakeDB{versions: map[int64]time.Time{}})
+	return &fakeConn{db: db.(*fakeDB)}, nil
+}
+
+func init() {
+	sql.Register("migratefake", fakeDriver{})
+}
+
+type fakeConn struct {
+	db *fakeDB
+	// State restored on rollback, nil - no transaction
+	saved *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.saved = &fakeDB{versions: map[int64]time.Time{},
+		executed: append([]string(nil), c.db.executed...)}
+	for v, at := range c.db.versions {
+		c.saved.versions[v] = at
+	}
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.saved = nil
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if c.saved != nil {
+		c.db.versions, c.db.executed = c.saved.versions, c.saved.executed
+		c.saved = nil
+	}
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string,
+	args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	query = strings.Join(strings.Fields(query), " ")
+	switch {
+	case strings.HasPrefix(query, "SELECT pg_advisory"),
+		strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		c.db.versions[args[0].Value.(int64)] = time.Now()
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		delete(c.db.versions, args[0].Value.(int64))
+	case strings.Contains(query, "FAIL"):
+		return nil, errors.New("syntax error")
+	default:
+		c.db.executed = append(c.db.executed, query)
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string,
+	args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	rows := &fakeRows{}
+	switch query {
+	case `SELECT version, applied_at FROM schema_migrations`:
+		rows.columns = []string{"version", "applied_at"}
+		for v, at := range c.db.versions {
+			rows.values = append(rows.values, []driver.Value{v, at})
+		}
+	case `SELECT MAX(version) FROM schema_migrations`:
+		rows.columns = []string{"max"}
+		var max driver.Value
+		for v := range c.db.versions {
+			if max == nil || v > max.(int64) {
+				max = v
+			}
+		}
+		rows.values = [][]driver.Value{{max}}
+	default:
+		return nil, errors.New("unexpected query: " + query)
+	}
+
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var testMigrations = fstest.MapFS{
+	"0001_users.up.sql":     {Data: []byte("CREATE TABLE users ()")},
+	"0001_users.down.sql":   {Data: []byte("DROP TABLE users")},
+	"0002_courses.up.sql":   {Data: []byte("CREATE TABLE courses ()")},
+	"0002_courses.down.sql": {Data: []byte("DROP TABLE courses")},
+	"README.md":             {Data: []byte("not a migration")},
+}
+
+// Migrator over fresh fake database.
+func newTestMigrator(t *testing.T, fsys fstest.MapFS) (*Migrator, *fakeDB) {
+	t.Helper()
+	db, err := sql.Open("migratefake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := New(db, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _ := fakeDBs.LoadOrStore(t.Name(),
+		&fakeDB{versions: map[int64]time.Time{}})
+
+	return m, state.(*fakeDB)
+}
+
+func TestUp(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t, testMigrations)
+
+	if version, err := m.Version(ctx); err != nil || version != 0 {
+		t.Fatalf("version of empty database %d, error %v", version, err)
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(done, []int{1, 2}) {
+		t.Errorf("applied %v, want [1 2]", done)
+	}
+	if want := []string{"CREATE TABLE users ()",
+		"CREATE TABLE courses ()"}; !reflect.DeepEqual(db.executed, want) {
+		t.Errorf("executed %q, want %q", db.executed, want)
+	}
+
+	// Applied migrations are skipped
+	if done, err = m.Up(ctx); err != nil || len(done) != 0 {
+		t.Errorf("second up applied %v, error %v", done, err)
+	}
+	if version, err := m.Version(ctx); err != nil ||
+		version != 2 || m.Latest() != 2 {
+		t.Errorf("version %d, latest %d, error %v",
+			version, m.Latest(), err)
+	}
+}
+
+// Failed migration is not recorded, earlier ones stay applied.
+func TestUpFailure(t *testing.T) {
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"0003_broken.up.sql":   {Data: []byte("FAIL")},
+		"0003_broken.down.sql": {Data: []byte("SELECT 1")},
+	}
+	for name, f := range testMigrations {
+		fsys[name] = f
+	}
+	m, _ := newTestMigrator(t, fsys)
+
+	done, err := m.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "3_broken") {
+		t.Errorf("error %v, want failure of 3_broken", err)
+	}
+	if !reflect.DeepEqual(done, []int{1, 2}) {
+		t.Errorf("applied %v, want [1 2]", done)
+	}
+	if version, _ := m.Version(ctx); version != 2 {
+		t.Errorf("version %d, want 2", version)
+	}
+}
+
+func TestDown(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t, testMigrations)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []int{2, 1} {
+		version, err := m.Down(ctx)
+		if err != nil || version != want {
+			t.Fatalf("rolled back %d, error %v, want %d", version, err, want)
+		}
+	}
+	if _, err := m.Down(ctx); !errors.Is(err, ErrNoMigrationsToRollback) {
+		t.Errorf("down of empty database: %v", err)
+	}
+	if got := db.executed[2:]; !reflect.DeepEqual(got,
+		[]string{"DROP TABLE courses", "DROP TABLE users"}) {
+		t.Errorf("rollbacks executed %q", got)
+	}
+	if version, _ := m.Version(ctx); version != 0 {
+		t.Errorf("version %d after rollbacks, want 0", version)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMigrator(t, testMigrations)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Down(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 ||
+		statuses[0].Name != "users" || !statuses[0].Applied ||
+		statuses[0].AppliedAt.IsZero() ||
+		statuses[1].Name != "courses" || statuses[1].Applied {
+		t.Errorf("statuses %+v", statuses)
+	}
+}
+
+func TestNewRejectsIncompleteMigrations(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"no down file": {
+			"0001_users.up.sql": {Data: []byte("SELECT 1")},
+		},
+		"two names": {
+			"0001_users.up.sql":    {Data: []byte("SELECT 1")},
+			"0001_people.down.sql": {Data: []byte("SELECT 1")},
+		},
+	} {
+		if _, err := New(nil, fsys); err == nil {
+			t.Errorf("%s: migrations are accepted", name)
+		}
+	}
+}
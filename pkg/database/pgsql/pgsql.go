@@ -1,35 +1,43 @@
 package pgsql
 
 import (
+	"context"
 	"database/sql"
-	"log"
-	"os"
-	"os/signal"
-	"syscall"
+	"fmt"
+	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
-var DB *sql.DB
+// Zero values keep database/sql defaults.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
 
-func init() {
-	var err error
-	DB, err = sql.Open("pgx", os.Getenv("DATABASE_URL"))
+// Opens connection pool and checks it with ping bounded by ctx.
+// Closing pool is up to caller.
+func Open(ctx context.Context, databaseUrl string,
+	pool PoolConfig) (*sql.DB, error) {
+	db, err := sql.Open("pgx", databaseUrl)
 	if err != nil {
-		log.Fatal("Failed to connect to a DB: ", err)
+		return nil, fmt.Errorf("failed to connect to a DB: %w", err)
+	}
+
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	if pool.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
 	}
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
 
 	// Actual connection check
-	err = DB.Ping()
-	if err != nil {
-		log.Fatal("Failed to ping database: ", err)
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Close db connection on program termination
-	go func() {
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
-		<-quit
-		DB.Close()
-	}()
+	return db, nil
 }
@@ -0,0 +1,66 @@
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Keeps prepared statements by query text, so every query
+// is prepared once per pool instead of once per call.
+// database/sql re-prepares statement on other connections itself.
+type StmtCache struct {
+	db *sql.DB
+
+	mu    sync.RWMutex
+	stmts map[string]*sql.Stmt
+}
+
+func NewStmtCache(db *sql.DB) *StmtCache {
+	return &StmtCache{db: db, stmts: make(map[string]*sql.Stmt)}
+}
+
+// Returned statement is owned by cache and must not be closed.
+// Errors: -
+func (c *StmtCache) Prepare(ctx context.Context,
+	query string) (*sql.Stmt, error) {
+	c.mu.RLock()
+	stmt, ok := c.stmts[query]
+	c.mu.RUnlock()
+	if ok {
+		return stmt, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Statement could be prepared while lock was released
+	if stmt, ok = c.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = stmt
+
+	return stmt, nil
+}
+
+// Closes all statements, cache can still be used after.
+// Errors: -
+func (c *StmtCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var firstErr error
+	for query, stmt := range c.stmts {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(c.stmts, query)
+	}
+
+	return firstErr
+}
@@ -0,0 +1,85 @@
+package errors
+
+import "errors"
+
+// Stable machine-readable codes of sentinel errors.
+// Codes must never change, clients rely on them.
+var codes = map[error]string{
+	ErrOnlyPostAllowed:        "ONLY_POST_ALLOWED",
+	ErrOnlyGetAllowed:         "ONLY_GET_ALLOWED",
+	ErrUnableToUnmarshalBody:  "UNABLE_TO_UNMARSHAL_BODY",
+	ErrMethodNotAllowed:       "METHOD_NOT_ALLOWED",
+	ErrRouteNotFound:          "ROUTE_NOT_FOUND",
+	ErrInternalServerError:    "INTERNAL_SERVER_ERROR",
+	ErrRequestTimeout:         "REQUEST_TIMEOUT",
+	ErrRequestCanceled:        "REQUEST_CANCELED",
+	ErrRateLimited:            "RATE_LIMITED",
+	ErrUrlValueNotValid:       "URL_VALUE_NOT_VALID",
+	ErrFieldViolatesFK:        "FIELD_VIOLATES_FOREIGN_KEY",
+	ErrUrlValueMissing:        "URL_VALUE_MISSING",
+	ErrMissingFields:          "MISSING_FIELDS",
+	ErrCantPrepareDbStmt:      "CANT_PREPARE_DB_STATEMENT",
+	ErrUserNotFound:           "USER_NOT_FOUND",
+	ErrUserExist:              "USER_ALREADY_EXISTS",
+	ErrAccessDenied:           "ACCESS_DENIED",
+	ErrUserDeactivated:        "USER_DEACTIVATED",
+	ErrWrongPassword:          "WRONG_PASSWORD",
+	ErrEmailNotVerified:       "EMAIL_NOT_VERIFIED",
+	ErrInvalidCredentials:     "INVALID_CREDENTIALS",
+	ErrTooManyAttempts:        "TOO_MANY_ATTEMPTS",
+	ErrMFANotEnrolled:         "MFA_NOT_ENROLLED",
+	ErrMFAAlreadyEnabled:      "MFA_ALREADY_ENABLED",
+	ErrMFACodeNotValid:        "MFA_CODE_NOT_VALID",
+	ErrMFARequired:            "MFA_REQUIRED",
+	ErrOIDCDisabled:           "OIDC_DISABLED",
+	ErrOIDCStateNotValid:      "OIDC_STATE_NOT_VALID",
+	ErrOIDCLoginFailed:        "OIDC_LOGIN_FAILED",
+	ErrSessionNotExist:        "SESSION_NOT_FOUND",
+	ErrSessionsNotFound:       "SESSIONS_NOT_FOUND",
+	ErrSessionUnknown:         "SESSION_UNKNOWN",
+	ErrTokenExpired:           "TOKEN_EXPIRED",
+	ErrTokenNotProvided:       "TOKEN_NOT_PROVIDED",
+	ErrTokenNotValid:          "TOKEN_NOT_VALID",
+	ErrTokenReused:            "REFRESH_TOKEN_REUSED",
+	ErrRoleNotFound:           "ROLE_NOT_FOUND",
+	ErrRoleCantBeSet:          "ROLE_CANT_BE_SET",
+	ErrDepNotFound:            "DEPARTMENT_NOT_FOUND",
+	ErrDepsNotFound:           "DEPARTMENTS_NOT_FOUND",
+	ErrCantSetThisDep:         "DEPARTMENT_CANT_BE_SET",
+	ErrEdEnvNotFound:          "EDUCATIONAL_ENV_NOT_FOUND",
+	ErrEdEnvsNotFound:         "EDUCATIONAL_ENVS_NOT_FOUND",
+	ErrGroupNotExist:          "GROUP_DOES_NOT_EXIST",
+	ErrGroupNotFound:          "GROUP_NOT_FOUND",
+	ErrGroupsNotFound:         "GROUPS_NOT_FOUND",
+	ErrGroupLinkedToCourse:    "GROUP_LINKED_TO_COURSE",
+	ErrGroupNotLinkedToCourse: "GROUP_NOT_LINKED_TO_COURSE",
+	ErrCourseIdNull:           "COURSE_ID_MISSING",
+	ErrCourseNotFound:         "COURSE_NOT_FOUND",
+	ErrCoursesNotFound:        "COURSES_NOT_FOUND",
+	ErrTermNotValid:           "TERM_NOT_VALID",
+	ErrTeacherNotFound:        "TEACHER_NOT_FOUND",
+	ErrCourseNameNotValid:     "COURSE_NAME_NOT_VALID",
+	ErrUserNotBelongToCourse:  "USER_NOT_BELONG_TO_COURSE",
+	ErrNestedInfoNotFound:     "NESTED_INFO_NOT_FOUND",
+	ErrNestedInfosNotFound:    "NESTED_INFOS_NOT_FOUND",
+	ErrNestedLabNotFound:      "NESTED_LAB_NOT_FOUND",
+	ErrNestedLabsNotFound:     "NESTED_LABS_NOT_FOUND",
+	ErrNestedTestNotFound:     "NESTED_TEST_NOT_FOUND",
+	ErrNestedTestsNotFound:    "NESTED_TESTS_NOT_FOUND",
+	ErrTimeLimitTooShort:      "TIME_LIMIT_TOO_SHORT",
+	TimeLimitNotValid:         "TIME_LIMIT_NOT_VALID",
+	ErrLocationNotFound:       "LOCATION_NOT_FOUND",
+	ErrValidationFailed:       "VALIDATION_FAILED",
+}
+
+// Returns code of sentinel error err wraps.
+func Code(err error) (string, bool) {
+	for err != nil {
+		if code, ok := codes[err]; ok {
+			return code, true
+		}
+		err = errors.Unwrap(err)
+	}
+
+	return "", false
+}
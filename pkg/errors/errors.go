@@ -1,9 +1,12 @@
 package errors
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
-	"fmt"
+	"log"
 	"net/http"
+	"strings"
 )
 
 var (
@@ -16,6 +19,8 @@ var (
 		"unable to unmarshal body")
 	ErrMethodNotAllowed = errors.New(
 		"method not allowed")
+	ErrRouteNotFound = errors.New(
+		"route not found")
 	ErrInternalServerError = errors.New(
 		"internal server error")
 	ErrUrlValueNotValid = errors.New(
@@ -28,6 +33,12 @@ var (
 		"required fields are missing")
 	ErrCantPrepareDbStmt = errors.New(
 		"cant prepare db statement")
+	ErrRequestTimeout = errors.New(
+		"request timed out")
+	ErrRequestCanceled = errors.New(
+		"request canceled")
+	ErrRateLimited = errors.New(
+		"too many requests, try again later")
 	// Users
 	ErrUserNotFound = errors.New(
 		"user not found")
@@ -35,15 +46,47 @@ var (
 		"user with this email already exists")
 	ErrAccessDenied = errors.New(
 		"permission denied")
+	ErrUserDeactivated = errors.New(
+		"user account is deactivated")
+	ErrWrongPassword = errors.New(
+		"current password is wrong")
+	ErrEmailNotVerified = errors.New(
+		"email is not verified")
+	ErrInvalidCredentials = errors.New(
+		"invalid email or password")
+	ErrTooManyAttempts = errors.New(
+		"too many failed sign in attempts, try again later")
+	// Two-factor authentication
+	ErrMFANotEnrolled = errors.New(
+		"two-factor authentication is not enrolled")
+	ErrMFAAlreadyEnabled = errors.New(
+		"two-factor authentication is already enabled")
+	ErrMFACodeNotValid = errors.New(
+		"two-factor code is not valid")
+	ErrMFARequired = errors.New(
+		"two-factor authentication is required for role of user")
+	// Single sign-on
+	ErrOIDCDisabled = errors.New(
+		"single sign-on is not configured")
+	ErrOIDCStateNotValid = errors.New(
+		"single sign-on state is missing or not valid")
+	ErrOIDCLoginFailed = errors.New(
+		"single sign-on failed")
 	// Sessions
 	ErrSessionNotExist = errors.New(
 		"session for this token doesn't exist")
+	ErrSessionsNotFound = errors.New(
+		"sessions not found")
+	ErrSessionUnknown = errors.New(
+		"access token has no session, refresh it")
 	ErrTokenExpired = errors.New(
 		"token expired")
 	ErrTokenNotProvided = errors.New(
 		"token not provided")
 	ErrTokenNotValid = errors.New(
 		"provided token not valid")
+	ErrTokenReused = errors.New(
+		"refresh token reuse detected, session revoked")
 	// Roles
 	ErrRoleNotFound = errors.New(
 		"role with this id not found")
@@ -109,10 +152,220 @@ var (
 	// Locations
 	ErrLocationNotFound = errors.New(
 		"location not found")
+	// Validation
+	ErrValidationFailed = errors.New(
+		"validation failed")
 )
 
+// Unexpected error (database failure, etc.) with name of failed operation.
+// Such errors match ErrInternalServerError and never reach clients.
+type InternalError struct {
+	Op  string
+	Err error
+}
+
+func (ie *InternalError) Error() string {
+	return ie.Op + ": " + ie.Err.Error()
+}
+
+func (ie *InternalError) Unwrap() error {
+	return ie.Err
+}
+
+func (ie *InternalError) Is(target error) bool {
+	return target == ErrInternalServerError
+}
+
+// Errors: -
+func Internal(op string, err error) error {
+	return &InternalError{Op: op, Err: err}
+}
+
+// Header carrying request id, set by request id middleware
+// on response before handlers are called.
+const RequestIdHeader = "X-Request-Id"
+
+// Field level problem of request body or query.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// APIError is body of every error response.
+// Code is stable and can be used by clients to localize message.
+type APIError struct {
+	Code      string       `json:"code"`
+	Status    int          `json:"status"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestId string       `json:"request_id,omitempty"`
+}
+
+func (ae *APIError) Error() string {
+	return ae.Message
+}
+
+// All field problems found in request, matches ErrValidationFailed.
+// Answered with 422 and problems listed in details.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (ve *ValidationError) Error() string {
+	msgs := make([]string, len(ve.Fields))
+	for i, f := range ve.Fields {
+		msgs[i] = f.Message
+	}
+
+	return ErrValidationFailed.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+func (ve *ValidationError) Is(target error) bool {
+	return target == ErrValidationFailed
+}
+
+func (ve *ValidationError) Add(field, rule, message string) {
+	ve.Fields = append(ve.Fields,
+		FieldError{Field: field, Rule: rule, Message: message})
+}
+
+// Reports whether field already has a problem,
+// so dependent checks (e.g. foreign key) can be skipped.
+func (ve *ValidationError) Has(field string) bool {
+	for _, f := range ve.Fields {
+		if f.Field == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Appends problems of other validation error.
+// Other errors are returned as is.
+func (ve *ValidationError) Merge(err error) error {
+	var other *ValidationError
+	if errors.As(err, &other) {
+		ve.Fields = append(ve.Fields, other.Fields...)
+		return nil
+	}
+
+	return err
+}
+
+// Nil when no problems found.
+func (ve *ValidationError) Err() error {
+	if len(ve.Fields) == 0 {
+		return nil
+	}
+
+	return ve
+}
+
+type envelope struct {
+	Error *APIError `json:"error"`
+}
+
+// Body of errors before envelope was introduced.
+type legacyBody struct {
+	Error string `json:"Error"`
+}
+
+type envelopeKey struct{}
+
+// Marks request answered with error envelope. Requests without
+// the mark (v1 and unversioned routes) get legacy body
+// {"Error" : "<message>"}, so their clients keep working.
+func WithEnvelope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, true)
+}
+
+func hasEnvelope(ctx context.Context) bool {
+	marked, _ := ctx.Value(envelopeKey{}).(bool)
+	return marked
+}
+
+// Codes of errors without own code are derived from status.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "BAD_REQUEST",
+	http.StatusUnauthorized:          "UNAUTHORIZED",
+	http.StatusForbidden:             "FORBIDDEN",
+	http.StatusNotFound:              "NOT_FOUND",
+	http.StatusMethodNotAllowed:      "METHOD_NOT_ALLOWED",
+	http.StatusConflict:              "CONFLICT",
+	http.StatusUnprocessableEntity:   "UNPROCESSABLE_ENTITY",
+	http.StatusTooManyRequests:       "TOO_MANY_REQUESTS",
+	http.StatusInternalServerError:   "INTERNAL_SERVER_ERROR",
+	http.StatusServiceUnavailable:    "SERVICE_UNAVAILABLE",
+	http.StatusRequestEntityTooLarge: "REQUEST_ENTITY_TOO_LARGE",
+}
+
+// Builds API error for err answered with status.
+func NewAPIError(status int, err error) *APIError {
+	var ae *APIError
+	if errors.As(err, &ae) {
+		res := *ae
+		res.Status = status
+		return &res
+	}
+
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return &APIError{
+			Code:    codes[ErrValidationFailed],
+			Status:  status,
+			Message: ErrValidationFailed.Error(),
+			Details: ve.Fields,
+		}
+	}
+
+	code, ok := Code(err)
+	if !ok {
+		if code, ok = statusCodes[status]; !ok {
+			code = strings.ToUpper(strings.ReplaceAll(
+				http.StatusText(status), " ", "_"))
+		}
+	}
+
+	return &APIError{
+		Code:    code,
+		Status:  status,
+		Message: err.Error(),
+	}
+}
+
+// Internal errors are logged and answered with 500 regardless of errCode.
+// Validation errors are answered with 422.
+// Body is envelope or legacy one, see WithEnvelope.
 func ResponseWithError(w http.ResponseWriter, r *http.Request,
 	errCode int, err error) {
+	// Context errors are checked first, as they come wrapped in internal
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		errCode = http.StatusServiceUnavailable
+		err = ErrRequestTimeout
+	} else if errors.Is(err, context.Canceled) {
+		// Client is gone, response is written for logs only
+		errCode = http.StatusServiceUnavailable
+		err = ErrRequestCanceled
+	} else if errors.Is(err, ErrInternalServerError) {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		errCode = http.StatusInternalServerError
+		err = ErrInternalServerError
+	} else if errors.Is(err, ErrValidationFailed) {
+		errCode = http.StatusUnprocessableEntity
+	}
+
+	var jsonBytes []byte
+	if hasEnvelope(r.Context()) {
+		apiErr := NewAPIError(errCode, err)
+		apiErr.RequestId = w.Header().Get(RequestIdHeader)
+		jsonBytes, _ = json.Marshal(envelope{Error: apiErr})
+	} else {
+		jsonBytes, _ = json.Marshal(legacyBody{Error: err.Error()})
+	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(errCode)
-	w.Write([]byte(fmt.Sprintf(`{"Error" : "%s"}`, err.Error())))
+	w.Write(jsonBytes)
 }
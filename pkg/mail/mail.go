@@ -0,0 +1,157 @@
+// Package mail sends plain text letters. SMTPMailer is used in
+// production, FileMailer keeps letters in file or log for local
+// runs and tests.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	// Cancelling ctx aborts sending.
+	// Errors: -
+	Send(ctx context.Context, msg Message) error
+}
+
+// Letters in RFC 5322 format, body is UTF-8 plain text.
+func (msg Message) Bytes(from string) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " +
+		mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
+
+type SMTPMailer struct {
+	addr string
+	host string
+	from string
+	// nil - server does not require authentication
+	auth smtp.Auth
+}
+
+// Empty username disables authentication.
+func NewSMTPMailer(host string, port int,
+	username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		host: host,
+		from: from,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m
+}
+
+// STARTTLS is used when server supports it.
+// Errors: -
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("mail: dial %s: %w", m.addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mail: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(nil); err != nil {
+			return fmt.Errorf("mail: starttls: %w", err)
+		}
+	}
+	if m.auth != nil {
+		if err = c.Auth(m.auth); err != nil {
+			return fmt.Errorf("mail: auth: %w", err)
+		}
+	}
+
+	if err = c.Mail(m.from); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if err = c.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if _, err = w.Write(msg.Bytes(m.from)); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+
+	return c.Quit()
+}
+
+// Appends letters to file, letters are separated by blank line.
+// Empty path writes letters to log.
+type FileMailer struct {
+	mu   sync.Mutex
+	path string
+	from string
+}
+
+func NewFileMailer(path, from string) *FileMailer {
+	return &FileMailer{path: path, from: from}
+}
+
+// Errors: -
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	letter := msg.Bytes(m.from)
+	if m.path == "" {
+		log.Printf("mail to %s:\n%s", msg.To, letter)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path,
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	defer f.Close()
+
+	if _, err = f.Write(append(letter, "\r\n\r\n"...)); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,363 @@
+// Package oidc implements relying party side of OpenID Connect
+// authorization code flow with PKCE. Provider endpoints are taken
+// from discovery document, ID tokens are verified with provider keys
+// (RS256).
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrTokenNotValid = errors.New("oidc: id token is not valid")
+	ErrExchange      = errors.New("oidc: code exchange failed")
+)
+
+type Config struct {
+	// Base URL of provider, discovery document is served under it
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Callback of relying party registered at provider
+	RedirectURL string
+	Scopes      []string
+}
+
+// Claims of verified ID token used to map provider users.
+type Claims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	MiddleName    string `json:"middle_name"`
+	Nonce         string `json:"nonce"`
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// Keys are reloaded for unknown key no sooner than this after previous
+// load, so tokens with made up key ids can't flood provider.
+const KeysRefetchInterval = time.Minute
+
+// Discovery document and keys are loaded on first use, so provider
+// being down does not prevent start. Keys are reloaded when token
+// is signed with unknown key.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu   sync.Mutex
+	meta *discovery
+	keys map[string]*rsa.PublicKey
+	// Time of last keys load
+	keysLoadedAt time.Time
+}
+
+func NewProvider(cfg Config) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *Provider) Issuer() string {
+	return p.cfg.Issuer
+}
+
+// Random URL safe string for state, nonce and PKCE verifier.
+// Errors: -
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// S256 code challenge of PKCE verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// URL user is redirected to for sign in at provider.
+// Errors: discovery failures.
+func (p *Provider) AuthCodeURL(ctx context.Context,
+	state, nonce, verifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.cfg.ClientID)
+	v.Set("redirect_uri", p.cfg.RedirectURL)
+	v.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", Challenge(verifier))
+	v.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + v.Encode(), nil
+}
+
+// Exchanges authorization code and returns verified claims
+// of ID token.
+// Errors: ErrExchange, ErrTokenNotValid, discovery failures.
+func (p *Provider) Exchange(ctx context.Context,
+	code, verifier, nonce string) (Claims, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", p.cfg.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, fmt.Errorf("oidc: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID),
+			url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var token struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	status, err := p.do(req, &token)
+	if err != nil {
+		return Claims{}, err
+	}
+	if status != http.StatusOK || token.IDToken == "" {
+		return Claims{}, fmt.Errorf("%w: status %d %s",
+			ErrExchange, status, token.Error)
+	}
+
+	return p.Verify(ctx, token.IDToken, nonce)
+}
+
+// Checks signature, issuer, audience, expiration and nonce.
+// Errors: ErrTokenNotValid, discovery failures.
+func (p *Provider) Verify(ctx context.Context,
+	rawIDToken, nonce string) (Claims, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	token, err := jwt.Parse(rawIDToken, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, ErrTokenNotValid
+		}
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, meta, kid)
+	})
+	if err != nil || !token.Valid {
+		return Claims{}, fmt.Errorf("%w: %v", ErrTokenNotValid, err)
+	}
+
+	mc := token.Claims.(jwt.MapClaims)
+	if !mc.VerifyIssuer(meta.Issuer, true) ||
+		!mc.VerifyAudience(p.cfg.ClientID, true) {
+		return Claims{}, fmt.Errorf("%w: issuer or audience",
+			ErrTokenNotValid)
+	}
+	if _, ok := mc["exp"]; !ok {
+		return Claims{}, fmt.Errorf("%w: no expiration", ErrTokenNotValid)
+	}
+
+	// Claims are decoded again into struct
+	raw, _ := json.Marshal(mc)
+	var claims Claims
+	if err = json.Unmarshal(raw, &claims); err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrTokenNotValid, err)
+	}
+	if claims.Subject == "" || claims.Nonce != nonce {
+		return Claims{}, fmt.Errorf("%w: subject or nonce",
+			ErrTokenNotValid)
+	}
+
+	return claims, nil
+}
+
+// Errors: discovery failures.
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(p.cfg.Issuer, "/")+
+			"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+
+	var meta discovery
+	status, err := p.do(req, &meta)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: discovery: status %d", status)
+	}
+	if meta.Issuer != strings.TrimSuffix(p.cfg.Issuer, "/") &&
+		meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc: discovery: issuer %q does not match",
+			meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" ||
+		meta.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery: endpoints are missing")
+	}
+
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// Key of token, keys are reloaded once when kid is unknown
+// and KeysRefetchInterval has passed since previous load.
+// Errors: ErrTokenNotValid, key loading failures.
+func (p *Provider) key(ctx context.Context,
+	meta *discovery, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysLoadedAt) < KeysRefetchInterval {
+		return nil, ErrTokenNotValid
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		meta.JWKSURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	status, err := p.do(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: jwks: status %d", status)
+	}
+
+	p.keysLoadedAt = time.Now()
+	p.keys = make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		if key, err := rsaKey(k); err == nil {
+			p.keys[k.Kid] = key
+		}
+	}
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, ErrTokenNotValid
+}
+
+// Token without kid is accepted when provider has single key.
+// Caller must hold the lock.
+func (p *Provider) lookup(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+
+	return key, ok
+}
+
+func rsaKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// Decodes JSON body, returns status of response.
+// Errors: transport and decoding failures.
+func (p *Provider) do(req *http.Request, dst any) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("oidc: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err = json.NewDecoder(resp.Body).Decode(dst); err != nil &&
+		resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("oidc: %s: %w", req.URL.Path, err)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,83 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Tokens with unknown key id don't reload keys more often
+// than KeysRefetchInterval.
+func TestKeysRefetchLimited(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var loads atomic.Int32
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("GET /.well-known/openid-configuration",
+		func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(discovery{Issuer: srv.URL,
+				AuthorizationEndpoint: srv.URL + "/authorize",
+				TokenEndpoint:         srv.URL + "/token",
+				JWKSURI:               srv.URL + "/jwks"})
+		})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		loads.Add(1)
+		json.NewEncoder(w).Encode(map[string][]jwk{"keys": {{
+			Kid: "known", Kty: "RSA",
+			N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E: base64.RawURLEncoding.EncodeToString(
+				big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+
+	p := NewProvider(Config{Issuer: srv.URL, ClientID: "client"})
+	token := func(kid string) string {
+		t.Helper()
+		jt := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": srv.URL, "aud": "client", "sub": "s-1",
+			"nonce": "n", "exp": time.Now().Add(time.Minute).Unix()})
+		jt.Header["kid"] = kid
+		raw, err := jt.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	ctx := context.Background()
+	if _, err = p.Verify(ctx, token("known"), "n"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err = p.Verify(ctx, token("made-up"),
+			"n"); !errors.Is(err, ErrTokenNotValid) {
+			t.Errorf("unknown key: %v, want ErrTokenNotValid", err)
+		}
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("keys loaded %d times, want 1", n)
+	}
+
+	// Interval has passed, unknown key reloads keys once more
+	p.keysLoadedAt = time.Now().Add(-KeysRefetchInterval)
+	p.Verify(ctx, token("made-up"), "n")
+	if n := loads.Load(); n != 2 {
+		t.Errorf("keys loaded %d times after interval, want 2", n)
+	}
+}
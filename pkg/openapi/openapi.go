@@ -0,0 +1,248 @@
+// Package openapi builds OpenAPI 3 documents. Schemas are derived
+// from Go types: field names are taken from json tags and
+// constraints from binding tags (see pkg/validate).
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"VEEEKTOR_api/pkg/validate"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Operations by lower case method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Pattern    string             `json:"pattern,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	// Values of map
+	AdditionalProperties *Schema  `json:"additionalProperties,omitempty"`
+	Items                *Schema  `json:"items,omitempty"`
+	Required             []string `json:"required,omitempty"`
+	MinLength            *int64   `json:"minLength,omitempty"`
+	MaxLength            *int64   `json:"maxLength,omitempty"`
+	Minimum              *int64   `json:"minimum,omitempty"`
+	Maximum              *int64   `json:"maximum,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+func New(title, version string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas:         map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{},
+		},
+	}
+}
+
+func (d *Document) AddOperation(method, path string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = PathItem{}
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// Reports whether method of path is described.
+func (d *Document) Has(method, path string) bool {
+	_, ok := d.Paths[path][strings.ToLower(method)]
+	return ok
+}
+
+// JSON schema of v. Named struct types are put into components
+// and referenced, so every DTO is described once.
+func (d *Document) SchemaOf(v any) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+// Same as SchemaOf, component is registered under name.
+func (d *Document) NamedSchemaOf(name string, v any) *Schema {
+	if _, ok := d.Components.Schemas[name]; !ok {
+		// Placeholder stops recursion of self referencing types
+		s := &Schema{}
+		d.Components.Schemas[name] = s
+		*s = *d.structSchema(reflect.TypeOf(v))
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		return d.NamedSchemaOf(t.Name(), reflect.Zero(t).Interface())
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return d.structSchema(t)
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object",
+			AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	}
+
+	return &Schema{}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if !sf.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		prop := d.schemaOf(sf.Type)
+		if tag, ok := sf.Tag.Lookup(validate.TagName); ok {
+			if applyRules(prop, strings.Split(tag, ",")) {
+				s.Required = append(s.Required, name)
+			}
+		}
+		s.Properties[name] = prop
+	}
+
+	return s
+}
+
+// Reports whether field is required.
+func applyRules(s *Schema, rules []string) bool {
+	required := false
+	for _, rule := range rules {
+		rule, param, _ := strings.Cut(rule, "=")
+		bound, _ := strconv.ParseInt(param, 10, 64)
+		switch rule {
+		case "required":
+			required = true
+		case "min":
+			if s.Type == "string" {
+				s.MinLength = &bound
+			} else {
+				s.Minimum = &bound
+			}
+		case "max":
+			if s.Type == "string" {
+				s.MaxLength = &bound
+			} else {
+				s.Maximum = &bound
+			}
+		case "email":
+			s.Format = "email"
+		case "datetime":
+			s.Pattern = layoutPattern(param)
+		}
+	}
+
+	return required
+}
+
+// Time layouts of binding tags use digits only, e.g. 15:04:05.
+func layoutPattern(layout string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range layout {
+		if r >= '0' && r <= '9' {
+			b.WriteString(`\d`)
+		} else {
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+
+	return b.String()
+}
@@ -0,0 +1,113 @@
+// Package ratelimit limits requests with token buckets. Bucket of key
+// holds up to Limit tokens and is refilled by Limit tokens per Period,
+// every request takes one token. Buckets are kept by Store, MemoryStore
+// serves single instance.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type Policy struct {
+	// Prefix of bucket keys, routes with the same name share buckets
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+// State of bucket, zero bucket is full.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Time until next token, zero when request is allowed
+	RetryAfter time.Duration
+	// Time until bucket is full
+	Reset time.Duration
+}
+
+type Store interface {
+	// Takes token from bucket of key, concurrent calls with the
+	// same key must not lose tokens.
+	// Errors: -
+	Take(ctx context.Context, key string, p Policy) (Result, error)
+}
+
+// Tokens per second.
+func (p Policy) rate() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+func (p Policy) seconds(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens/p.rate()*1e3)) * time.Millisecond
+}
+
+// Refills bucket at now and takes token when there is one.
+func (p Policy) Take(b Bucket, now time.Time) (Bucket, Result) {
+	limit := float64(p.Limit)
+	tokens := limit
+	if !b.UpdatedAt.IsZero() {
+		elapsed := max(now.Sub(b.UpdatedAt).Seconds(), 0)
+		tokens = min(limit, b.Tokens+elapsed*p.rate())
+	}
+
+	res := Result{Limit: p.Limit}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = p.seconds(1 - tokens)
+	}
+	res.Remaining = int(tokens)
+	res.Reset = p.seconds(limit - tokens)
+
+	return Bucket{Tokens: tokens, UpdatedAt: now}, res
+}
+
+type memoryBucket struct {
+	Bucket
+	// Bucket is full after this time and can be forgotten
+	fullAt time.Time
+}
+
+// Keeps buckets in process, full buckets are swept once a minute.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]memoryBucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]memoryBucket)}
+}
+
+// Errors: -
+func (s *MemoryStore) Take(ctx context.Context,
+	key string, p Policy) (Result, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, b := range s.buckets {
+			if now.After(b.fullAt) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, res := p.Take(s.buckets[key].Bucket, now)
+	s.buckets[key] = memoryBucket{Bucket: b, fullAt: now.Add(res.Reset)}
+
+	return res, nil
+}
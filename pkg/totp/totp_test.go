@@ -0,0 +1,63 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// SHA-1 vectors of RFC 6238 appendix B, last six digits of
+// eight digit codes. Secret is ASCII "12345678901234567890".
+func TestCode(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+
+	for _, tc := range []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	} {
+		code, err := Code(secret, Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tc.code {
+			t.Errorf("time %d: code %s, want %s", tc.unix, code, tc.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1234567890, 0)
+
+	for _, tc := range []struct {
+		name  string
+		shift int64
+		valid bool
+	}{
+		{"previous step", -1, true},
+		{"current step", 0, true},
+		{"next step", 1, true},
+		{"outside window", 2, false},
+	} {
+		code, _ := Code(secret, Step(now)+tc.shift)
+		step, ok, err := Validate(secret, code, now)
+		if err != nil || ok != tc.valid ||
+			(ok && step != Step(now)+tc.shift) {
+			t.Errorf("%s: step %d, valid %t, error %v",
+				tc.name, step, ok, err)
+		}
+	}
+
+	if _, _, err := Validate("not base32!", "123456", now); err == nil {
+		t.Error("broken secret is accepted")
+	}
+}
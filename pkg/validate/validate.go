@@ -0,0 +1,138 @@
+// Package validate checks struct fields by binding tags
+// and collects all problems at once.
+//
+// Supported rules:
+// required - value is not zero;
+// min=n, max=n - string length (in symbols) or number value;
+// email - string is plain email address;
+// datetime=layout - string matches time layout (e.g. 15:04:05).
+// Empty not required strings skip format rules.
+// Field name in problems is taken from json tag.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	e "VEEEKTOR_api/pkg/errors"
+)
+
+const TagName = "binding"
+
+// v must be struct or pointer to struct.
+// Returned error is never nil, use Err to get nil on success.
+func Struct(v any) *e.ValidationError {
+	ve := &e.ValidationError{}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: %T is not a struct", v))
+	}
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag, ok := sf.Tag.Lookup(TagName)
+		if !ok || !sf.IsExported() {
+			continue
+		}
+
+		checkField(ve, fieldName(sf), rv.Field(i), strings.Split(tag, ","))
+	}
+
+	return ve
+}
+
+func fieldName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+
+	return name
+}
+
+func checkField(ve *e.ValidationError, name string,
+	fv reflect.Value, rules []string) {
+	required := false
+	for _, rule := range rules {
+		if rule == "required" {
+			required = true
+		}
+	}
+
+	if fv.IsZero() {
+		if required {
+			ve.Add(name, "required", name+" is required")
+		}
+		// Length and format of empty optional string are not checked
+		if required || fv.Kind() == reflect.String {
+			return
+		}
+	}
+
+	for _, rule := range rules {
+		rule, param, _ := strings.Cut(rule, "=")
+		switch rule {
+		case "required", "":
+		case "min", "max":
+			checkBound(ve, name, fv, rule, param)
+		case "email":
+			addr, err := mail.ParseAddress(fv.String())
+			if err != nil || addr.Address != fv.String() {
+				ve.Add(name, rule, name+" is not a valid email address")
+			}
+		case "datetime":
+			if _, err := time.Parse(param, fv.String()); err != nil {
+				ve.Add(name, rule, fmt.Sprintf(
+					"%s must match format %s", name, param))
+			}
+		default:
+			panic("validate: unknown rule " + rule)
+		}
+	}
+}
+
+func checkBound(ve *e.ValidationError, name string,
+	fv reflect.Value, rule, param string) {
+	bound, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		panic("validate: bad " + rule + " parameter " + param)
+	}
+
+	var value int64
+	var msg string
+	switch fv.Kind() {
+	case reflect.String:
+		value = int64(utf8.RuneCountInString(fv.String()))
+		msg = "%s must contain at least %d symbols"
+		if rule == "max" {
+			msg = "%s must contain no more than %d symbols"
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16,
+		reflect.Int32, reflect.Int64:
+		value = fv.Int()
+		msg = "%s must be at least %d"
+		if rule == "max" {
+			msg = "%s must be no more than %d"
+		}
+	case reflect.Slice, reflect.Map:
+		value = int64(fv.Len())
+		msg = "%s must contain at least %d items"
+		if rule == "max" {
+			msg = "%s must contain no more than %d items"
+		}
+	default:
+		panic("validate: " + rule + " is not supported for " +
+			fv.Kind().String())
+	}
+
+	if (rule == "min" && value < bound) || (rule == "max" && value > bound) {
+		ve.Add(name, rule, fmt.Sprintf(msg, name, bound))
+	}
+}